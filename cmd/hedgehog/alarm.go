@@ -0,0 +1,186 @@
+package main
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/player"
+)
+
+// How often to look at the clock while waiting for an alarm. Sleeping
+// straight through would miss the alarm if the machine is suspended
+// partway, since timers stop counting while it's asleep.
+const alarmCheck = 30 * time.Second
+
+type AlarmCmd struct {
+	At     string        `kong:"required,name='at',env='SONIC_ALARM_AT',help='when to go off, like 07:00 or 7:30am'"`
+	Days   string        `kong:"optional,name='days',env='SONIC_ALARM_DAYS',help='go off on these days, like mon-fri, weekdays, weekends, daily or sat,sun (default: once)'"`
+	RampUp time.Duration `kong:"optional,default='5m',name='ramp-up',env='SONIC_ALARM_RAMP_UP',help='bring the volume up from nothing over this long (0 starts at full volume)'"`
+	Snooze time.Duration `kong:"optional,default='9m',name='snooze',env='SONIC_ALARM_SNOOZE',help='how long the snooze key (enter) pauses for'"`
+
+	PlayCmd `kong:"embed"`
+}
+
+func (cmd AlarmCmd) Run(cli *CLI) error {
+	schedule, err := parseSchedule(cmd.At, cmd.Days)
+	if err != nil {
+		return err
+	}
+
+	// Everything is worked out now, so a password prompt or a bad flag
+	// turns up tonight rather than at seven in the morning
+	config, err := cmd.PlayCmd.config(cli)
+	if err != nil {
+		return err
+	}
+	config.RampUp = cmd.RampUp
+	config.Snooze = cmd.Snooze
+
+	for {
+		next := schedule.next(time.Now())
+		fmt.Printf("Alarm set for %s\n", next.Format("Mon Jan 2 15:04"))
+		waitUntil(next)
+
+		if err := player.Start(config); err != nil {
+			return err
+		}
+		if !schedule.recurring() {
+			return nil
+		}
+	}
+}
+
+// waitUntil blocks until the wall clock reaches when
+func waitUntil(when time.Time) {
+	for {
+		// Round strips the monotonic reading, which stands still while
+		// the machine is suspended
+		left := when.Sub(time.Now().Round(0))
+		if left <= 0 {
+			return
+		}
+		if left > alarmCheck {
+			left = alarmCheck
+		}
+		time.Sleep(left)
+	}
+}
+
+// schedule is a time of day, and which days of the week it applies to. With
+// no days, it happens once.
+type schedule struct {
+	hour, minute int
+	days         [7]bool
+}
+
+var dayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// parseSchedule understands times like 07:00, 7:30am and 7am, and days as a
+// comma separated list of day names (sun through sat), ranges of them like
+// mon-fri, or weekdays, weekends and daily
+func parseSchedule(at string, days string) (schedule, error) {
+	var sched schedule
+
+	clock := strings.ToLower(strings.ReplaceAll(at, " ", ""))
+	var (
+		when time.Time
+		err  error
+	)
+	for _, layout := range []string{"15:04", "3:04pm", "3pm"} {
+		if when, err = time.Parse(layout, clock); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return sched, fmt.Errorf("can't make sense of '%s' as a time, try 07:00 or 7:30am", at)
+	}
+	sched.hour, sched.minute = when.Hour(), when.Minute()
+
+	for _, part := range splitNonEmpty(strings.ToLower(days), ",") {
+		switch part {
+		case "daily", "everyday":
+			sched.days = [7]bool{true, true, true, true, true, true, true}
+			continue
+		case "weekdays":
+			part = "mon-fri"
+		case "weekends":
+			part = "sat-sun"
+		}
+
+		from, to, isRange := strings.Cut(part, "-")
+		if !isRange {
+			to = from
+		}
+		first, err := parseDay(from)
+		if err != nil {
+			return sched, err
+		}
+		last, err := parseDay(to)
+		if err != nil {
+			return sched, err
+		}
+
+		// Ranges can wrap around the weekend, like fri-mon
+		for day := first; ; day = (day + 1) % 7 {
+			sched.days[day] = true
+			if day == last {
+				break
+			}
+		}
+	}
+
+	return sched, nil
+}
+
+func parseDay(name string) (time.Weekday, error) {
+	name = strings.TrimSpace(name)
+	if len(name) >= 3 {
+		// Anything from "tue" to "tuesday", but not "tuesdays" or "tuna"
+		if day, ok := dayNames[name[:3]]; ok && strings.HasPrefix(strings.ToLower(day.String()), name) {
+			return day, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown day '%s' (have: sun, mon, tue, wed, thu, fri, sat)", name)
+}
+
+func (sched schedule) recurring() bool {
+	for _, on := range sched.days {
+		if on {
+			return true
+		}
+	}
+	return false
+}
+
+// next finds the first time the schedule comes around after now
+func (sched schedule) next(now time.Time) time.Time {
+	year, month, day := now.Date()
+	for offset := 0; offset <= 7; offset++ {
+		when := time.Date(year, month, day+offset, sched.hour, sched.minute, 0, 0, now.Location())
+		// A time in the hour the clocks skip in spring comes back from
+		// before the jump. Move it past the jump, so 02:30 goes off at 03:30.
+		if skipped := (sched.hour*60 + sched.minute - (when.Hour()*60 + when.Minute()) + 24*60) % (24 * 60); skipped != 0 {
+			when = when.Add(time.Duration(skipped) * time.Minute)
+		}
+		if !when.After(now) {
+			continue
+		}
+		if !sched.recurring() || sched.days[when.Weekday()] {
+			return when
+		}
+	}
+	// Unreachable, since every day of the week comes up in eight days
+	return now
+}
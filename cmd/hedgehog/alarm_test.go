@@ -0,0 +1,169 @@
+package main
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+// week lists the days a schedule is on, sun first, like "-mtwtf-"
+func (sched schedule) week() string {
+	letters := "smtwtfs"
+	out := []byte("-------")
+	for day, on := range sched.days {
+		if on {
+			out[day] = letters[day]
+		}
+	}
+	return string(out)
+}
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		at, days     string
+		hour, minute int
+		week         string
+	}{
+		{"07:00", "", 7, 0, "-------"},
+		{"7:00", "", 7, 0, "-------"},
+		{"23:59", "", 23, 59, "-------"},
+		{"00:00", "", 0, 0, "-------"},
+		{"7:30am", "", 7, 30, "-------"},
+		{"7:30 PM", "", 19, 30, "-------"},
+		{"7am", "", 7, 0, "-------"},
+		{"12am", "", 0, 0, "-------"},
+		{"12pm", "", 12, 0, "-------"},
+
+		{"7am", "daily", 7, 0, "smtwtfs"},
+		{"7am", "everyday", 7, 0, "smtwtfs"},
+		{"7am", "weekdays", 7, 0, "-mtwtf-"},
+		{"7am", "weekends", 7, 0, "s-----s"},
+		{"7am", "mon-fri", 7, 0, "-mtwtf-"},
+		{"7am", "Mon-Fri", 7, 0, "-mtwtf-"},
+		{"7am", "sun-sat", 7, 0, "smtwtfs"},
+		{"7am", "sat-sun", 7, 0, "s-----s"},
+		{"7am", "wed", 7, 0, "---w---"},
+		{"7am", "wed-wed", 7, 0, "---w---"},
+		{"7am", "sat,sun", 7, 0, "s-----s"},
+		{"7am", "mon, wed,,fri", 7, 0, "-m-w-f-"},
+		{"7am", "monday,tues,thurs", 7, 0, "-mt-t--"},
+		{"7am", "mon-tue,thu-fri", 7, 0, "-mt-tf-"},
+		{"7am", "mon,weekends", 7, 0, "sm----s"},
+
+		// Ranges wrap around the end of the week
+		{"7am", "fri-mon", 7, 0, "sm---fs"},
+		{"7am", "sat-tue", 7, 0, "smt---s"},
+		{"7am", "tue-mon", 7, 0, "smtwtfs"},
+	}
+
+	for _, test := range tests {
+		sched, err := parseSchedule(test.at, test.days)
+		if err != nil {
+			t.Errorf("%s %s: %s", test.at, test.days, err)
+			continue
+		}
+		if sched.hour != test.hour || sched.minute != test.minute {
+			t.Errorf("%s is %02d:%02d, want %02d:%02d", test.at, sched.hour, sched.minute, test.hour, test.minute)
+		}
+		if week := sched.week(); week != test.week {
+			t.Errorf("%s is %s, want %s", test.days, week, test.week)
+		}
+		if sched.recurring() != (test.days != "") {
+			t.Errorf("%s recurring is %v", test.days, sched.recurring())
+		}
+	}
+}
+
+func TestParseScheduleRejects(t *testing.T) {
+	tests := []struct{ at, days string }{
+		{"", ""},
+		{"seven", ""},
+		{"25:00", ""},
+		{"7:60", ""},
+		{"13pm", ""},
+		{"7", ""},
+		{"7am", "funday"},
+		{"7am", "mo"},
+		{"7am", "monkey"},
+		{"7am", "mondays"},
+		{"7am", "mon-"},
+		{"7am", "-fri"},
+		{"7am", "mon-fun"},
+		{"7am", "weekday"},
+	}
+	for _, test := range tests {
+		if sched, err := parseSchedule(test.at, test.days); err == nil {
+			t.Errorf("'%s' '%s' parsed as %02d:%02d %s", test.at, test.days, sched.hour, sched.minute, sched.week())
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2024-03-10 and 2024-11-03 are the days New York's clocks change
+	tests := []struct {
+		name     string
+		at, days string
+		now      string
+		want     string
+	}{
+		{"once, later today", "7am", "", "2024-05-15 06:00", "2024-05-15 07:00 EDT"},
+		{"once, gone today", "7am", "", "2024-05-15 08:00", "2024-05-16 07:00 EDT"},
+		{"once, right now", "7am", "", "2024-05-15 07:00", "2024-05-16 07:00 EDT"},
+		{"once, across months", "7am", "", "2024-05-31 08:00", "2024-06-01 07:00 EDT"},
+		{"once, across years", "7am", "", "2024-12-31 08:00", "2025-01-01 07:00 EST"},
+
+		// 2024-05-15 is a Wednesday
+		{"weekdays, midweek", "7am", "weekdays", "2024-05-15 08:00", "2024-05-16 07:00 EDT"},
+		{"weekdays, friday night", "7am", "weekdays", "2024-05-17 22:00", "2024-05-20 07:00 EDT"},
+		{"weekends, midweek", "9am", "weekends", "2024-05-15 08:00", "2024-05-18 09:00 EDT"},
+		{"one day, gone today", "7am", "wed", "2024-05-15 08:00", "2024-05-22 07:00 EDT"},
+		{"one day, later today", "7am", "wed", "2024-05-15 06:59", "2024-05-15 07:00 EDT"},
+		{"wrapping, saturday", "7am", "fri-mon", "2024-05-18 08:00", "2024-05-19 07:00 EDT"},
+		{"wrapping, monday", "7am", "fri-mon", "2024-05-20 08:00", "2024-05-24 07:00 EDT"},
+		{"wrapping, tuesday", "7am", "fri-mon", "2024-05-21 08:00", "2024-05-24 07:00 EDT"},
+
+		// Daylight saving time
+		{"spring forward", "7am", "daily", "2024-03-09 08:00", "2024-03-10 07:00 EDT"},
+		{"fall back", "7am", "daily", "2024-11-02 08:00", "2024-11-03 07:00 EST"},
+		{"in the skipped hour", "2:30am", "", "2024-03-10 00:00", "2024-03-10 03:30 EDT"},
+		{"skipped hour, daily", "2:30am", "daily", "2024-03-09 03:00", "2024-03-10 03:30 EDT"},
+		{"after the skipped hour", "2:30am", "daily", "2024-03-10 04:00", "2024-03-11 02:30 EDT"},
+		{"in the repeated hour", "1:30am", "", "2024-11-03 00:00", "2024-11-03 01:30 EDT"},
+		{"start of the skipped hour", "2am", "", "2024-03-10 00:00", "2024-03-10 03:00 EDT"},
+	}
+
+	for _, test := range tests {
+		sched, err := parseSchedule(test.at, test.days)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		now, err := time.ParseInLocation("2006-01-02 15:04", test.now, newYork)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		got := sched.next(now)
+		if !got.After(now) {
+			t.Errorf("%s: %s isn't after %s", test.name, got, now)
+		}
+		if formatted := got.Format("2006-01-02 15:04 MST"); formatted != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, formatted, test.want)
+		}
+	}
+
+	// Once the repeated hour has gone by the first time, an alarm in it
+	// doesn't go off again the second time around
+	sched, _ := parseSchedule("1:30am", "")
+	firstPass, _ := time.ParseInLocation("2006-01-02 15:04", "2024-11-03 01:45", newYork)
+	secondPass := firstPass.Add(time.Hour)
+	if got := sched.next(secondPass).Format("2006-01-02 15:04 MST"); got != "2024-11-04 01:30 EST" {
+		t.Errorf("repeated hour, second time around: got %s", got)
+	}
+}
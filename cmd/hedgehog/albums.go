@@ -0,0 +1,76 @@
+package main
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/sonic"
+)
+
+type AlbumsCmd struct {
+	Type  string `kong:"optional,default='newest',name='type',help='which albums: random, newest, highest, frequent, recent, alphabeticalByName, alphabeticalByArtist, byYear, byGenre, starred'"`
+	Count int    `kong:"optional,default=20,name='count',help='how many albums to list'"`
+	Years string `kong:"optional,name='years',help='for byYear, a year or a range like 1990-1999 (1999-1990 lists the newest first)'"`
+	Genre string `kong:"optional,name='genre',help='for byGenre, which genre'"`
+}
+
+func (cmd AlbumsCmd) Run(cli *CLI) error {
+	list, err := albumList(cmd.Type, cmd.Years, cmd.Genre)
+	if err != nil {
+		return err
+	}
+
+	client, err := cli.client()
+	if err != nil {
+		return err
+	}
+
+	albums, err := client.AlbumListPager(list).All(cmd.Count)
+	if err != nil {
+		return err
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, album := range albums {
+		name := album.Name
+		if album.Year > 0 {
+			name = fmt.Sprintf("%s (%d)", name, album.Year)
+		}
+		length := time.Duration(album.Duration) * time.Second
+		fmt.Fprintf(out, "%s\t%s\t%d songs\t%s\t%s\n", album.Artist, name, album.SongCount, length, album.Genre)
+	}
+	return out.Flush()
+}
+
+// albumList builds an album list from flags. years is a single year or a
+// range, like 1990-1999.
+func albumList(listType string, years string, genre string) (sonic.AlbumList, error) {
+	list := sonic.AlbumList{Type: listType, Genre: genre}
+	if canonical, ok := sonic.AlbumListType(listType); ok {
+		list.Type = canonical
+	}
+
+	if years != "" {
+		from, to, isRange := strings.Cut(years, "-")
+		if !isRange {
+			to = from
+		}
+
+		var err error
+		if list.FromYear, err = strconv.Atoi(strings.TrimSpace(from)); err != nil {
+			return list, fmt.Errorf("bad year in '%s'", years)
+		}
+		if list.ToYear, err = strconv.Atoi(strings.TrimSpace(to)); err != nil {
+			return list, fmt.Errorf("bad year in '%s'", years)
+		}
+	}
+
+	return list, list.Validate()
+}
@@ -0,0 +1,61 @@
+package main
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alecthomas/kong"
+)
+
+// HooksFlag maps event names to the shell commands to run for them. On the
+// command line it's given once per command, like
+// --hook 'track-start=notify-send "$HEDGEHOG_TITLE"'. In the config file
+// it's a table:
+//
+//	[hook]
+//	track-start = "echo $HEDGEHOG_TITLE > ~/.now-playing"
+//	exit = ["rm ~/.now-playing", "curl -X POST http://lights/off"]
+type HooksFlag map[string][]string
+
+func (flag *HooksFlag) Decode(ctx *kong.DecodeContext) error {
+	token := ctx.Scan.Pop()
+	if *flag == nil {
+		*flag = make(HooksFlag)
+	}
+
+	switch value := token.Value.(type) {
+	case string:
+		event, command, ok := strings.Cut(value, "=")
+		if !ok || strings.TrimSpace(command) == "" {
+			return fmt.Errorf("expected event=command but got '%s'", value)
+		}
+		event = strings.TrimSpace(event)
+		(*flag)[event] = append((*flag)[event], command)
+
+	case map[string]interface{}:
+		for event, raw := range value {
+			switch commands := raw.(type) {
+			case string:
+				(*flag)[event] = append((*flag)[event], commands)
+			case []interface{}:
+				for _, command := range commands {
+					str, ok := command.(string)
+					if !ok {
+						return fmt.Errorf("%s: commands must be strings, got %v", event, command)
+					}
+					(*flag)[event] = append((*flag)[event], str)
+				}
+			default:
+				return fmt.Errorf("%s: expected a command or a list of commands, got %v", event, raw)
+			}
+		}
+
+	default:
+		return fmt.Errorf("invalid hooks %v", token.Value)
+	}
+
+	return nil
+}
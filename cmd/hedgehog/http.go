@@ -0,0 +1,72 @@
+package main
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/sonic"
+)
+
+type HTTPFlags struct {
+	Timeout    time.Duration `kong:"optional,default='30s',name='timeout',env='SONIC_TIMEOUT',help='give up on the server after this long without an answer'"`
+	CABundle   string        `kong:"optional,name='ca-bundle',env='SONIC_CA_BUNDLE',help='PEM file of extra certificates to trust, for self signed servers'"`
+	ClientCert string        `kong:"optional,name='client-cert',env='SONIC_CLIENT_CERT',help='PEM client certificate, for servers that ask for one'"`
+	ClientKey  string        `kong:"optional,name='client-key',env='SONIC_CLIENT_KEY',help='PEM key for --client-cert'"`
+	Insecure   bool          `kong:"optional,name='insecure',env='SONIC_INSECURE',help='do not verify the server certificate'"`
+	Proxy      string        `kong:"optional,name='proxy',env='SONIC_PROXY',help='http, https or socks5 proxy url (default: HTTPS_PROXY and friends)'"`
+	Headers    []string      `kong:"optional,sep='none',name='header',env='SONIC_HEADER',help='extra header for every request, like \"Authorization: Bearer abc\" (repeatable)'"`
+}
+
+func (flags HTTPFlags) config() (sonic.HTTPConfig, error) {
+	headers, err := sonic.ParseHeaders(flags.Headers)
+	if err != nil {
+		return sonic.HTTPConfig{}, err
+	}
+	return sonic.HTTPConfig{
+		Timeout:    flags.Timeout,
+		CABundle:   flags.CABundle,
+		ClientCert: flags.ClientCert,
+		ClientKey:  flags.ClientKey,
+		Insecure:   flags.Insecure,
+		Proxy:      flags.Proxy,
+		Headers:    headers,
+	}, nil
+}
+
+// needServer checks for the flags that say which server to talk to. They're
+// optional at the top level since status, remote and stats never talk to
+// the server.
+func (cli *CLI) needServer() error {
+	var missing []string
+	if cli.URL == "" {
+		missing = append(missing, "--url=STRING")
+	}
+	if cli.User == "" {
+		missing = append(missing, "--user=STRING")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing flags: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// newClient sets up a client for the server, without talking to it yet
+func (cli *CLI) newClient(password string) (sonic.Sonic, error) {
+	if err := cli.needServer(); err != nil {
+		return sonic.Sonic{}, err
+	}
+	client := sonic.New(sonic.Auth{User: cli.User, Password: password}, cli.URL)
+
+	config, err := cli.HTTPFlags.config()
+	if err != nil {
+		return sonic.Sonic{}, err
+	}
+	if err := client.Configure(config); err != nil {
+		return sonic.Sonic{}, err
+	}
+	return client, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alecthomas/kong"
+)
+
+// KeysFlag maps action names to the key sequences that trigger them. On the
+// command line it looks like "next=n,right;pause=space". In the config file
+// it's a table:
+//
+//	[keys]
+//	next = ["n", "right"]
+//	pause = "space"
+//	quit = "ctrl-x q"
+type KeysFlag map[string][]string
+
+func (flag *KeysFlag) Decode(ctx *kong.DecodeContext) error {
+	token := ctx.Scan.Pop()
+	if *flag == nil {
+		*flag = make(KeysFlag)
+	}
+
+	switch value := token.Value.(type) {
+	case string:
+		for _, pair := range strings.Split(value, ";") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+			action, keys, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("expected action=keys but got '%s'", pair)
+			}
+			(*flag)[strings.TrimSpace(action)] = splitNonEmpty(keys, ",")
+		}
+
+	case map[string]interface{}:
+		for action, raw := range value {
+			switch keys := raw.(type) {
+			case string:
+				(*flag)[action] = []string{keys}
+			case []interface{}:
+				list := make([]string, 0, len(keys))
+				for _, key := range keys {
+					str, ok := key.(string)
+					if !ok {
+						return fmt.Errorf("%s: keys must be strings, got %v", action, key)
+					}
+					list = append(list, str)
+				}
+				(*flag)[action] = list
+			default:
+				return fmt.Errorf("%s: expected a key or a list of keys, got %v", action, raw)
+			}
+		}
+
+	default:
+		return fmt.Errorf("invalid key bindings %v", token.Value)
+	}
+
+	return nil
+}
+
+func splitNonEmpty(raw string, sep string) []string {
+	out := make([]string, 0)
+	for _, part := range strings.Split(raw, sep) {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -0,0 +1,31 @@
+package main
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"io"
+	"path/filepath"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/config"
+	"git.sr.ht/~sungo/hedgehog/pkg/logging"
+)
+
+type LogFlags struct {
+	LogLevel string `kong:"optional,default='info',enum='debug,info,warn,error',name='log-level',env='SONIC_LOG_LEVEL',help='how much goes in the log file: debug, info, warn or error'"`
+	LogFile  string `kong:"optional,name='log-file',env='SONIC_LOG_FILE',help='where to write the log (default: $XDG_STATE_HOME/hedgehog/hedgehog.log)'"`
+}
+
+// start points logging at the log file. Nothing is ever logged to the
+// terminal.
+func (flags LogFlags) start() (io.Closer, error) {
+	path := flags.LogFile
+	if path == "" {
+		dir, err := config.StateDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(dir, "hedgehog.log")
+	}
+	return logging.Setup(path, flags.LogLevel)
+}
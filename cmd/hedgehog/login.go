@@ -0,0 +1,125 @@
+package main
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/term"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/keyring"
+)
+
+type LoginCmd struct{}
+
+func (cmd LoginCmd) Run(cli *CLI) error {
+	if err := cli.needServer(); err != nil {
+		return err
+	}
+
+	password := cli.Password
+	if password == "" && cli.PasswordCommand != "" {
+		var err error
+		if password, err = runPasswordCommand(cli.PasswordCommand); err != nil {
+			return err
+		}
+	}
+	if password == "" {
+		var err error
+		if password, err = promptPassword(fmt.Sprintf("Password for %s at %s: ", cli.User, cli.URL)); err != nil {
+			return err
+		}
+	}
+
+	client, err := cli.newClient(password)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Checking credentials...")
+	if err := client.Probe(); err != nil {
+		return fmt.Errorf("login failed: %w", err)
+	}
+	fmt.Printf("Connected to %s\n", client.Server)
+	if client.OpenSubsonic {
+		fmt.Printf("Server supports OpenSubsonic with %d extensions\n", len(client.Extensions))
+	}
+
+	ring, err := keyring.Open()
+	if err != nil {
+		return err
+	}
+	defer ring.Close()
+
+	if err := ring.Set(cli.URL, cli.User, password); err != nil {
+		return err
+	}
+
+	fmt.Printf("Saved password for %s at %s in the keyring\n", cli.User, cli.URL)
+	return nil
+}
+
+// password works out the subsonic password, trying the --password flag,
+// then --password-command, then the keyring
+func (cli *CLI) password() (string, error) {
+	if err := cli.needServer(); err != nil {
+		return "", err
+	}
+	if cli.Password != "" {
+		return cli.Password, nil
+	}
+
+	if cli.PasswordCommand != "" {
+		return runPasswordCommand(cli.PasswordCommand)
+	}
+
+	ring, err := keyring.Open()
+	if err != nil {
+		return "", fmt.Errorf("no --password or --password-command given and the keyring is unavailable: %w", err)
+	}
+	defer ring.Close()
+
+	password, err := ring.Get(cli.URL, cli.User)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", fmt.Errorf("no password for %s at %s. Run 'hedgehog login' or pass --password-command", cli.User, cli.URL)
+	}
+	return password, err
+}
+
+func runPasswordCommand(command string) (string, error) {
+	var stderr bytes.Buffer
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("password command failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	if !scanner.Scan() || scanner.Text() == "" {
+		return "", errors.New("password command printed nothing")
+	}
+	return scanner.Text(), nil
+}
+
+func promptPassword(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	defer fmt.Fprintln(os.Stderr)
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		return strings.TrimRight(line, "\r\n"), err
+	}
+
+	password, err := term.ReadPassword(int(os.Stdin.Fd()))
+	return string(password), err
+}
@@ -4,39 +4,182 @@ package main
 // Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
 
 import (
+	"errors"
+	"log/slog"
+	"time"
+
 	"github.com/alecthomas/kong"
 
+	"git.sr.ht/~sungo/hedgehog/pkg/config"
 	"git.sr.ht/~sungo/hedgehog/pkg/player"
+	"git.sr.ht/~sungo/hedgehog/pkg/sonic"
 )
 
 type (
-	Cmd struct {
-		User           string `kong:"required,name='user',env='SONIC_USER',help='subsonic user name'"`
-		Password       string `kong:"required,name='password',env='SONIC_PASSWORD',help='subsonic password (sent in the url unencrypted)'"`
-		URL            string `kong:"required,name='url',env='SONIC_URL',help='url to the server (like https://music.wat)'"`
-		PlaylistName   string `kong:"required,name='playlist',env='SONIC_PLAYLIST',help='which playlist to play'"`
-		Shuffle        bool   `kong:"optional,negatable,name='shuffle',env='SONIC_SHUFFLE',help='shuffle the track order'"`
-		Repeat         bool   `kong:"optional,negatable,default=true,name='repeat',env='SONIC_REPEAT',help='when we run out of stuff to play, start over (with --shuffle, the list is reshuffled)'"`
-		ReloadOnRepeat bool   `kong:"optional,negatable,default=true,name'reload-on-repeat',env='SONIC_RELOAD_REPEAT',help='when we run out of stuff to play, automatically refresh the playlist'"`
-		Notifications  bool   `kong:"optional,negatable,default=true,name='notifications',env='SONIC_NOTIFICATIONS',help='activate notifications on song change'"`
+	CLI struct {
+		Config          kong.ConfigFlag `kong:"optional,name='config',env='SONIC_CONFIG',help='path to an alternate config file'"`
+		Profile         string          `kong:"optional,name='profile',env='SONIC_PROFILE',help='which profile to use from the config file'"`
+		User            string          `kong:"optional,name='user',env='SONIC_USER',help='subsonic user name'"`
+		Password        string          `kong:"optional,name='password',env='SONIC_PASSWORD',help='subsonic password (sent in the url unencrypted). Prefer --password-command or hedgehog login'"`
+		PasswordCommand string          `kong:"optional,name='password-command',env='SONIC_PASSWORD_COMMAND',help='command whose first line of output is the subsonic password (like: pass show music)'"`
+		URL             string          `kong:"optional,name='url',env='SONIC_URL',help='url to the server (like https://music.wat)'"`
+		Socket          string          `kong:"optional,name='socket',env='SONIC_SOCKET',help='where a playing hedgehog answers hedgehog status and remote (default: $XDG_RUNTIME_DIR/hedgehog/hedgehog.sock)'"`
+
+		HTTPFlags `kong:"embed"`
+		LogFlags  `kong:"embed"`
+
+		Play       PlayCmd       `kong:"cmd,default='withargs',help='play a playlist (the default command)'"`
+		Login      LoginCmd      `kong:"cmd,help='check credentials against the server and save the password in the system keyring'"`
+		LastFMAuth LastFMAuthCmd `kong:"cmd,name='lastfm-auth',help='authorize hedgehog to scrobble to last.fm'"`
+		Sync       SyncCmd       `kong:"cmd,help='download playlists for offline play'"`
+		Playlist   PlaylistCmd   `kong:"cmd,help='export and import playlists'"`
+		Podcasts   PodcastsCmd   `kong:"cmd,help='list podcast channels and episodes'"`
+		Albums     AlbumsCmd     `kong:"cmd,help='list albums, like the newest or most played'"`
+		Alarm      AlarmCmd      `kong:"cmd,help='wait until a set time, then play (like an alarm clock)'"`
+		Status     StatusCmd     `kong:"cmd,help='print what the running hedgehog is playing, for status bars'"`
+		Remote     RemoteCmd     `kong:"cmd,help='tell the running hedgehog to pause, skip and so on'"`
+		Stats      StatsCmd      `kong:"cmd,help='top artists, albums and tracks, skip rates and listening time, from the play history'"`
+	}
+
+	PlayCmd struct {
+		PlaylistName   string        `kong:"optional,name='playlist',env='SONIC_PLAYLIST',help='which playlist to play'"`
+		Shuffle        bool          `kong:"optional,negatable,name='shuffle',env='SONIC_SHUFFLE',help='shuffle the track order'"`
+		Repeat         bool          `kong:"optional,negatable,default=true,name='repeat',env='SONIC_REPEAT',help='when we run out of stuff to play, start over (with --shuffle, the list is reshuffled)'"`
+		ReloadOnRepeat bool          `kong:"optional,negatable,default=true,name'reload-on-repeat',env='SONIC_RELOAD_REPEAT',help='when we run out of stuff to play, automatically refresh the playlist'"`
+		Notifications  bool          `kong:"optional,negatable,default=true,name='notifications',env='SONIC_NOTIFICATIONS',help='activate notifications on song change'"`
+		NotifySummary  string        `kong:"optional,name='notify-summary',env='SONIC_NOTIFY_SUMMARY',help='go text/template for the notification title (default: {{.Title}}). Fields are the same as hedgehog status --format'"`
+		NotifyBody     string        `kong:"optional,name='notify-body',env='SONIC_NOTIFY_BODY',help='go text/template for the notification text (default: the artist, then album and details)'"`
+		MaxBitRate     int           `kong:"optional,default=0,name='max-bitrate',env='SONIC_MAX_BITRATE',help='ask the server to transcode tracks down to this bitrate in kbps (0 for the original file)'"`
+		Crossfade      int           `kong:"optional,default=0,name='crossfade',env='SONIC_CROSSFADE',help='seconds to overlap the end of one track with the start of the next (0 disables, skipped between tracks of the same album)'"`
+		Keys           KeysFlag      `kong:"optional,name='keys',env='SONIC_KEYS',help='override key bindings, like next=n,right;pause=space (actions: quit, mute, previous, next, star, reload, pause, lyrics, similar, sleep, wake, stop, snooze)'"`
+		Library        string        `kong:"optional,name='library',env='SONIC_LIBRARY',help='where synced playlists are kept (default: $XDG_DATA_HOME/hedgehog/library)'"`
+		Offline        bool          `kong:"optional,name='offline',env='SONIC_OFFLINE',help='play a synced playlist without talking to the server. Stars and scrobbles are sent once it is reachable'"`
+		Lyrics         bool          `kong:"optional,negatable,name='lyrics',env='SONIC_LYRICS',help='show lyrics as songs play (toggle with l)'"`
+		LyricsDir      string        `kong:"optional,name='lyrics-dir',env='SONIC_LYRICS_DIR',help='a local copy of the music folder, to find .lrc files in'"`
+		BookmarkOver   time.Duration `kong:"optional,default='20m',name='bookmark-over',env='SONIC_BOOKMARK_OVER',help='remember the position in tracks at least this long and resume them there (0 disables)'"`
+		Radio          string        `kong:"optional,name='radio',env='SONIC_RADIO',help='play an internet radio station from the server, by name, instead of a playlist'"`
+		Podcast        string        `kong:"optional,name='podcast',env='SONIC_PODCAST',help='play a podcast channel, by title, instead of a playlist'"`
+		NewestPodcasts int           `kong:"optional,default=0,name='newest-podcasts',env='SONIC_NEWEST_PODCASTS',help='play this many of the newest podcast episodes, across every channel, instead of a playlist'"`
+		Endless        bool          `kong:"optional,negatable,name='endless',env='SONIC_ENDLESS',help='when the playlist runs out, keep going with songs like the last one (or press s any time)'"`
+		Albums         string        `kong:"optional,name='albums',env='SONIC_ALBUMS',help='play whole albums from a list instead of a playlist, like newest (see hedgehog albums --help for the lists)'"`
+		AlbumCount     int           `kong:"optional,default=10,name='album-count',env='SONIC_ALBUM_COUNT',help='how many albums to play with --albums'"`
+		AlbumYears     string        `kong:"optional,name='album-years',env='SONIC_ALBUM_YEARS',help='for --albums byYear, a year or a range like 1990-1999'"`
+		AlbumGenre     string        `kong:"optional,name='album-genre',env='SONIC_ALBUM_GENRE',help='for --albums byGenre, which genre'"`
+		Sleep          time.Duration `kong:"optional,default='0',name='sleep',env='SONIC_SLEEP',help='stop playing after this long, like 45m, fading out over the last minute (z adds 15m, Z cancels)'"`
+		StopAfter      int           `kong:"optional,default=0,name='stop-after',env='SONIC_STOP_AFTER',help='stop after playing this many tracks (x stops after the current one)'"`
+		History        bool          `kong:"optional,negatable,default=true,name='history',env='SONIC_HISTORY',help='keep a local history of every track played, for hedgehog stats'"`
+
+		Hook        HooksFlag     `kong:"optional,name='hook',env='SONIC_HOOK',help='run a shell command when something happens, like track-start=CMD (events: track-start, track-end, scrobble, star, pause, exit). Repeatable'"`
+		HookTimeout time.Duration `kong:"optional,default='10s',name='hook-timeout',env='SONIC_HOOK_TIMEOUT',help='kill hook commands that run longer than this'"`
+
+		Web      string `kong:"optional,name='web',env='SONIC_WEB',help='serve a remote control page and API on this address, like :8080 or 127.0.0.1:8080'"`
+		WebToken string `kong:"optional,name='web-token',env='SONIC_WEB_TOKEN',help='token the web remote asks for (default: a random one, kept between runs)'"`
+
+		ScrobbleFlags `kong:"embed"`
 	}
 )
 
 func main() {
-	ctx := kong.Parse(&Cmd{})
-	err := ctx.Run()
+	var cli CLI
+	ctx := kong.Parse(&cli,
+		kong.Configuration(config.Loader, config.Path()),
+	)
+
+	logFile, err := cli.LogFlags.start()
+	ctx.FatalIfErrorf(err)
+	defer logFile.Close()
+	slog.Info("starting", "command", ctx.Command())
+
+	err = ctx.Run(&cli)
 	ctx.FatalIfErrorf(err)
 }
 
-func (cmd Cmd) Run() error {
-	return player.Start(player.Config{
-		User:           cmd.User,
-		Password:       cmd.Password,
-		URL:            cmd.URL,
+func (cmd PlayCmd) Run(cli *CLI) error {
+	config, err := cmd.config(cli)
+	if err != nil {
+		return err
+	}
+	return player.Start(config)
+}
+
+// config gathers everything the player needs from the flags
+func (cmd PlayCmd) config(cli *CLI) (player.Config, error) {
+	if cmd.PlaylistName == "" && cmd.Radio == "" && cmd.Podcast == "" && cmd.NewestPodcasts <= 0 && cmd.Albums == "" {
+		return player.Config{}, errors.New("missing flags: --playlist=STRING (or --radio, --podcast, --newest-podcasts, --albums)")
+	}
+
+	if err := cli.needServer(); err != nil {
+		return player.Config{}, err
+	}
+
+	var albums sonic.AlbumList
+	if cmd.Albums != "" {
+		var err error
+		if albums, err = albumList(cmd.Albums, cmd.AlbumYears, cmd.AlbumGenre); err != nil {
+			return player.Config{}, err
+		}
+	}
+
+	password, err := cli.password()
+	if err != nil && !cmd.Offline {
+		return player.Config{}, err
+	}
+
+	stateDir, err := config.StateDir()
+	if err != nil {
+		return player.Config{}, err
+	}
+
+	libDir, err := libraryDir(cmd.Library)
+	if err != nil {
+		return player.Config{}, err
+	}
+
+	httpConfig, err := cli.HTTPFlags.config()
+	if err != nil {
+		return player.Config{}, err
+	}
+
+	socket, err := cli.socket()
+	if err != nil {
+		return player.Config{}, err
+	}
+
+	return player.Config{
+		User:           cli.User,
+		Password:       password,
+		URL:            cli.URL,
+		HTTP:           httpConfig,
 		PlaylistName:   cmd.PlaylistName,
 		Shuffle:        cmd.Shuffle,
 		Repeat:         cmd.Repeat,
 		ReloadOnRepeat: cmd.ReloadOnRepeat,
 		Notifications:  cmd.Notifications,
-	})
+		NotifySummary:  cmd.NotifySummary,
+		NotifyBody:     cmd.NotifyBody,
+		MaxBitRate:     cmd.MaxBitRate,
+		Crossfade:      time.Duration(cmd.Crossfade) * time.Second,
+		Keys:           cmd.Keys,
+		Scrobble:       cmd.ScrobbleFlags.config(),
+		StateDir:       stateDir,
+		LibraryDir:     libDir,
+		Offline:        cmd.Offline,
+		Lyrics:         cmd.Lyrics,
+		LyricsDir:      cmd.LyricsDir,
+		BookmarkOver:   cmd.BookmarkOver,
+		Radio:          cmd.Radio,
+		Podcast:        cmd.Podcast,
+		NewestPodcasts: cmd.NewestPodcasts,
+		Albums:         albums,
+		AlbumCount:     cmd.AlbumCount,
+		Endless:        cmd.Endless,
+		Sleep:          cmd.Sleep,
+		StopAfter:      cmd.StopAfter,
+		History:        cmd.History,
+		Socket:         socket,
+		Hooks:          cmd.Hook,
+		HookTimeout:    cmd.HookTimeout,
+		Web:            cmd.Web,
+		WebToken:       cmd.WebToken,
+	}, nil
 }
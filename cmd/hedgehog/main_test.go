@@ -0,0 +1,82 @@
+package main
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"testing"
+
+	"github.com/alecthomas/kong"
+)
+
+func parse(t *testing.T, args ...string) (*CLI, *kong.Context, error) {
+	t.Helper()
+	for _, env := range []string{"SONIC_USER", "SONIC_URL", "SONIC_CONFIG", "SONIC_PROFILE"} {
+		t.Setenv(env, "")
+	}
+
+	var cli CLI
+	parser, err := kong.New(&cli, kong.Exit(func(int) { t.Fatal("kong tried to exit") }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := parser.Parse(args)
+	return &cli, ctx, err
+}
+
+// Commands that only talk to a running hedgehog, or read the local play
+// history, don't need to know about the server
+func TestLocalCommandsNeedNoServer(t *testing.T) {
+	tests := []struct {
+		args    []string
+		command string
+	}{
+		{[]string{"status"}, "status"},
+		{[]string{"status", "--output", "json"}, "status"},
+		{[]string{"remote", "pause"}, "remote <command>"},
+		{[]string{"stats"}, "stats"},
+		{[]string{"stats", "--since", "7d", "--by", "week"}, "stats"},
+	}
+	for _, test := range tests {
+		_, ctx, err := parse(t, test.args...)
+		if err != nil {
+			t.Errorf("%v: %s", test.args, err)
+			continue
+		}
+		if ctx.Command() != test.command {
+			t.Errorf("%v runs %s, want %s", test.args, ctx.Command(), test.command)
+		}
+	}
+}
+
+func TestServerCommandsNeedServer(t *testing.T) {
+	cli, _, err := parse(t, "play", "--playlist", "starred")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cli.Play.config(cli); err == nil || err.Error() != "missing flags: --url=STRING, --user=STRING" {
+		t.Errorf("play without a server: %v", err)
+	}
+	if _, err := cli.client(); err == nil {
+		t.Error("made a client without a server")
+	}
+
+	cli, _, err = parse(t, "--user", "sungo", "play", "--playlist", "starred")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.needServer(); err == nil || err.Error() != "missing flags: --url=STRING" {
+		t.Errorf("play without a url: %v", err)
+	}
+}
+
+func TestStatsNeedsNoServer(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	cli, ctx, err := parse(t, "stats", "--output", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.Run(cli); err != nil {
+		t.Fatal(err)
+	}
+}
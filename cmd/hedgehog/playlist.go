@@ -0,0 +1,205 @@
+package main
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/library"
+	"git.sr.ht/~sungo/hedgehog/pkg/playlistio"
+	"git.sr.ht/~sungo/hedgehog/pkg/sonic"
+)
+
+type (
+	PlaylistCmd struct {
+		Export PlaylistExportCmd `kong:"cmd,help='write a server playlist out as m3u8, xspf or json'"`
+		Import PlaylistImportCmd `kong:"cmd,help='create or update a server playlist from an m3u8, xspf or json file'"`
+	}
+
+	PlaylistExportCmd struct {
+		Name       string `kong:"arg,required,name='name',help='playlist to export'"`
+		Format     string `kong:"optional,default='m3u8',enum='m3u8,xspf,json',name='format',help='output format (m3u8, xspf, json)'"`
+		Output     string `kong:"optional,short='o',name='output',help='file to write (default: stdout)'"`
+		Local      bool   `kong:"optional,name='local',help='point entries at synced files in the library instead of paths on the server'"`
+		StreamURLs bool   `kong:"optional,name='stream-urls',help='point entries at stream urls, which carry a login token, so keep the file private'"`
+		Library    string `kong:"optional,name='library',env='SONIC_LIBRARY',help='where synced playlists are kept (default: $XDG_DATA_HOME/hedgehog/library)'"`
+	}
+
+	PlaylistImportCmd struct {
+		File    string `kong:"arg,required,type='existingfile',name='file',help='playlist file to import'"`
+		Format  string `kong:"optional,default='auto',enum='auto,m3u8,xspf,json',name='format',help='input format (auto guesses from the file name)'"`
+		Name    string `kong:"optional,name='name',help='server playlist to create or update (default: the name in the file, or the file name)'"`
+		Library string `kong:"optional,name='library',env='SONIC_LIBRARY',help='where synced playlists are kept (default: $XDG_DATA_HOME/hedgehog/library)'"`
+	}
+)
+
+func (cli *CLI) client() (sonic.Sonic, error) {
+	password, err := cli.password()
+	if err != nil {
+		return sonic.Sonic{}, err
+	}
+
+	client, err := cli.newClient(password)
+	if err != nil {
+		return sonic.Sonic{}, err
+	}
+	if err := client.Probe(); err != nil {
+		return sonic.Sonic{}, err
+	}
+	return client, nil
+}
+
+func (cmd PlaylistExportCmd) Run(cli *CLI) error {
+	client, err := cli.client()
+	if err != nil {
+		return err
+	}
+
+	playlist, err := client.GetPlaylistByName(cmd.Name)
+	if err != nil {
+		return err
+	}
+
+	var lib *library.Library
+	if cmd.Local {
+		dir, err := libraryDir(cmd.Library)
+		if err != nil {
+			return err
+		}
+		if lib, err = library.Open(dir); err != nil {
+			return err
+		}
+	}
+
+	if cmd.StreamURLs {
+		fmt.Fprintln(os.Stderr, "Stream urls log in as you. Anyone with the file can play (and do anything else) as you until the password changes.")
+	}
+
+	doc := playlistio.Document{Name: playlist.Name}
+	missing := 0
+	for _, song := range playlist.Songs {
+		// The server's path is enough for import to find the song again,
+		// and gives nothing away
+		location := song.Path
+		if cmd.StreamURLs {
+			if location, err = client.StreamURL(song); err != nil {
+				return err
+			}
+		}
+		if lib != nil {
+			if path, ok := lib.File(song.ID); ok {
+				location = path
+			} else {
+				missing++
+			}
+		}
+		doc.Entries = append(doc.Entries, playlistio.EntryFor(song, location))
+	}
+
+	var out io.Writer = os.Stdout
+	if cmd.Output != "" {
+		file, err := os.Create(cmd.Output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	if err := playlistio.Encode(out, cmd.Format, doc); err != nil {
+		return err
+	}
+
+	if missing > 0 {
+		fmt.Fprintf(os.Stderr, "%d songs aren't synced and were written as they are on the server\n", missing)
+	}
+	return nil
+}
+
+func (cmd PlaylistImportCmd) Run(cli *CLI) error {
+	client, err := cli.client()
+	if err != nil {
+		return err
+	}
+
+	format := cmd.Format
+	if format == "auto" {
+		if format, err = playlistio.FormatFor(cmd.File); err != nil {
+			return err
+		}
+	}
+
+	file, err := os.Open(cmd.File)
+	if err != nil {
+		return err
+	}
+	doc, err := playlistio.Decode(file, format)
+	file.Close()
+	if err != nil {
+		return err
+	}
+
+	name := cmd.Name
+	if name == "" {
+		name = doc.Name
+	}
+	if name == "" {
+		base := filepath.Base(cmd.File)
+		name = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+
+	matcher := playlistio.Matcher{Client: &client}
+	if dir, err := libraryDir(cmd.Library); err == nil {
+		if lib, err := library.Open(dir); err == nil {
+			matcher.Library = lib
+		}
+	}
+
+	fmt.Printf("Matching %d entries...\n", len(doc.Entries))
+	var (
+		songIDs   = make([]string, 0, len(doc.Entries))
+		unmatched = make([]string, 0)
+	)
+	for idx, entry := range doc.Entries {
+		song, ok := matcher.Match(entry)
+		if !ok {
+			unmatched = append(unmatched, fmt.Sprintf("%4d: %s", idx+1, entry))
+			continue
+		}
+		songIDs = append(songIDs, song.ID)
+	}
+
+	if len(songIDs) == 0 {
+		return fmt.Errorf("nothing in %s matched a song on the server", cmd.File)
+	}
+
+	var existingID string
+	if playlists, err := client.GetPlaylists(); err == nil {
+		for _, listing := range playlists {
+			if listing.Name == name {
+				existingID = listing.ID
+				break
+			}
+		}
+	}
+
+	if _, err := client.CreatePlaylist(existingID, name, songIDs); err != nil {
+		return err
+	}
+
+	verb := "Created"
+	if existingID != "" {
+		verb = "Updated"
+	}
+	fmt.Printf("=> %s '%s' with %d of %d entries\n", verb, name, len(songIDs), len(doc.Entries))
+
+	if len(unmatched) > 0 {
+		fmt.Printf("\nNo match for:\n%s\n", strings.Join(unmatched, "\n"))
+	}
+	return nil
+}
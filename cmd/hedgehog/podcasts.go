@@ -0,0 +1,143 @@
+package main
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/sonic"
+)
+
+type (
+	PodcastsCmd struct {
+		Channels PodcastChannelsCmd `kong:"cmd,default='withargs',help='list podcast channels, or the episodes in one (the default)'"`
+		New      PodcastNewCmd      `kong:"cmd,help='list the newest episodes across every channel'"`
+		Download PodcastDownloadCmd `kong:"cmd,help='ask the server to download episodes so they can be played'"`
+	}
+
+	PodcastChannelsCmd struct {
+		Channel string `kong:"arg,optional,name='channel',help='list the episodes in this channel'"`
+	}
+
+	PodcastNewCmd struct {
+		Count int `kong:"optional,default=20,name='count',help='how many episodes to list'"`
+	}
+
+	PodcastDownloadCmd struct {
+		Episodes []string `kong:"arg,required,name='episode',help='ids of episodes to download, as shown by the channels and new commands'"`
+	}
+)
+
+func (cmd PodcastChannelsCmd) Run(cli *CLI) error {
+	client, err := cli.client()
+	if err != nil {
+		return err
+	}
+
+	channels, err := client.GetPodcasts(true)
+	if err != nil {
+		return err
+	}
+
+	if cmd.Channel == "" {
+		out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, channel := range channels {
+			downloaded := 0
+			for _, episode := range channel.Episodes {
+				if episode.Downloaded() {
+					downloaded++
+				}
+			}
+
+			fmt.Fprintf(out, "%s\t%d episodes\t%d downloaded", channel.Title, len(channel.Episodes), downloaded)
+			if channel.ErrorMessage != "" {
+				fmt.Fprintf(out, "\t(%s: %s)", channel.Status, channel.ErrorMessage)
+			}
+			fmt.Fprintln(out)
+		}
+		return out.Flush()
+	}
+
+	for _, channel := range channels {
+		if channel.Title == cmd.Channel {
+			titles := map[string]string{channel.ID: channel.Title}
+			return printEpisodes(client, channel.Episodes, titles)
+		}
+	}
+	return fmt.Errorf("unable to find podcast '%s'", cmd.Channel)
+}
+
+func (cmd PodcastNewCmd) Run(cli *CLI) error {
+	client, err := cli.client()
+	if err != nil {
+		return err
+	}
+
+	episodes, err := client.GetNewestPodcasts(cmd.Count)
+	if err != nil {
+		return err
+	}
+
+	channels, err := client.GetPodcasts(false)
+	if err != nil {
+		return err
+	}
+	titles := make(map[string]string, len(channels))
+	for _, channel := range channels {
+		titles[channel.ID] = channel.Title
+	}
+
+	return printEpisodes(client, episodes, titles)
+}
+
+func (cmd PodcastDownloadCmd) Run(cli *CLI) error {
+	client, err := cli.client()
+	if err != nil {
+		return err
+	}
+
+	for _, id := range cmd.Episodes {
+		if err := client.DownloadPodcastEpisode(id); err != nil {
+			return fmt.Errorf("episode %s: %w", id, err)
+		}
+		fmt.Printf("=> Episode %s queued for download on the server\n", id)
+	}
+	return nil
+}
+
+// printEpisodes writes out one episode per line, noting the ones that are
+// part way through
+func printEpisodes(client sonic.Sonic, episodes sonic.Episodes, titles map[string]string) error {
+	bookmarks, err := client.GetBookmarks()
+	if err != nil {
+		bookmarks = map[string]sonic.Bookmark{}
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(out, "ID\tPUBLISHED\tSTATUS\tEPISODE\t")
+	for _, episode := range episodes {
+		published := "-"
+		if at := episode.Published(); !at.IsZero() {
+			published = at.Local().Format("2006-01-02")
+		}
+
+		progress := ""
+		if bookmark, ok := bookmarks[episode.StreamID]; ok && episode.StreamID != "" && bookmark.Position > 0 {
+			progress = fmt.Sprintf("[%s in]", bookmark.Offset().Truncate(time.Second))
+		}
+
+		fmt.Fprintf(out, "%s\t%s\t%s\t%s : %s\t%s\n",
+			episode.ID,
+			published,
+			episode.Status,
+			titles[episode.ChannelID],
+			episode.Title,
+			progress,
+		)
+	}
+	return out.Flush()
+}
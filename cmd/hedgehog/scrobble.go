@@ -0,0 +1,77 @@
+package main
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/scrobble"
+)
+
+type (
+	ScrobbleFlags struct {
+		Scrobble          []string `kong:"optional,default='subsonic',name='scrobble',env='SONIC_SCROBBLE',help='where to send scrobbles, any of: subsonic, listenbrainz, lastfm'"`
+		ScrobbleThreshold float64  `kong:"optional,default=50,name='scrobble-threshold',env='SONIC_SCROBBLE_THRESHOLD',help='percent of a track that has to play before it is scrobbled (capped at 4 minutes)'"`
+		ListenBrainzToken string   `kong:"optional,name='listenbrainz-token',env='LISTENBRAINZ_TOKEN',help='listenbrainz user token'"`
+		ListenBrainzURL   string   `kong:"optional,name='listenbrainz-url',env='LISTENBRAINZ_URL',help='listenbrainz api url, for self hosted instances'"`
+		LastFMAPIKey      string   `kong:"optional,name='lastfm-api-key',env='LASTFM_API_KEY',help='last.fm api key'"`
+		LastFMSecret      string   `kong:"optional,name='lastfm-secret',env='LASTFM_SECRET',help='last.fm shared secret'"`
+		LastFMSessionKey  string   `kong:"optional,name='lastfm-session-key',env='LASTFM_SESSION_KEY',help='last.fm session key, from hedgehog lastfm-auth'"`
+		LastFMURL         string   `kong:"optional,name='lastfm-url',env='LASTFM_URL',help='last.fm compatible api url'"`
+	}
+
+	LastFMAuthCmd struct {
+		LastFMAPIKey string `kong:"required,name='lastfm-api-key',env='LASTFM_API_KEY',help='last.fm api key'"`
+		LastFMSecret string `kong:"required,name='lastfm-secret',env='LASTFM_SECRET',help='last.fm shared secret'"`
+		LastFMURL    string `kong:"optional,name='lastfm-url',env='LASTFM_URL',help='last.fm compatible api url'"`
+	}
+)
+
+func (flags ScrobbleFlags) config() scrobble.Config {
+	return scrobble.Config{
+		Services:  flags.Scrobble,
+		Threshold: flags.ScrobbleThreshold,
+		ListenBrainz: scrobble.ListenBrainz{
+			Token: flags.ListenBrainzToken,
+			URL:   flags.ListenBrainzURL,
+		},
+		LastFM: scrobble.LastFM{
+			APIKey:     flags.LastFMAPIKey,
+			Secret:     flags.LastFMSecret,
+			SessionKey: flags.LastFMSessionKey,
+			URL:        flags.LastFMURL,
+		},
+	}
+}
+
+func (cmd LastFMAuthCmd) Run() error {
+	lastfm := scrobble.LastFM{
+		APIKey: cmd.LastFMAPIKey,
+		Secret: cmd.LastFMSecret,
+		URL:    cmd.LastFMURL,
+	}
+
+	token, authURL, err := lastfm.Authorize()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Allow hedgehog to scrobble by visiting:")
+	fmt.Println()
+	fmt.Println("   ", authURL)
+	fmt.Println()
+	fmt.Print("Then press enter...")
+	bufio.NewReader(os.Stdin).ReadString('\n')
+
+	user, key, err := lastfm.Session(token)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Authorized as %s. Add this to your config file:\n\n", user)
+	fmt.Printf("lastfm-session-key = %q\n", key)
+	return nil
+}
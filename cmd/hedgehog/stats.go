@@ -0,0 +1,163 @@
+package main
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/config"
+	"git.sr.ht/~sungo/hedgehog/pkg/history"
+)
+
+const dateFormat = "2006-01-02"
+
+type StatsCmd struct {
+	Since  string `kong:"optional,name='since',help='only count plays since a date like 2026-01-31, or for the last while, like 7d, 4w or 12h'"`
+	Until  string `kong:"optional,name='until',help='only count plays up to and including a date like 2026-02-28'"`
+	Top    int    `kong:"optional,default=10,name='top',help='how many artists, albums and tracks to list'"`
+	By     string `kong:"optional,name='by',help='break listening time down by day, week or month'"`
+	Output string `kong:"optional,default='text',enum='text,json',name='output',help='text or json'"`
+}
+
+func (cmd StatsCmd) Run(cli *CLI) error {
+	now := time.Now()
+	from, err := parseSince(cmd.Since, now)
+	if err != nil {
+		return err
+	}
+	until, err := parseUntil(cmd.Until)
+	if err != nil {
+		return err
+	}
+
+	switch cmd.By {
+	case "", history.Day, history.Week, history.Month:
+	default:
+		return fmt.Errorf("--by should be day, week or month, not '%s'", cmd.By)
+	}
+
+	stateDir, err := config.StateDir()
+	if err != nil {
+		return err
+	}
+	plays, err := history.Open(stateDir)
+	if err != nil {
+		return err
+	}
+
+	played, err := history.Read(plays.Path(), from, until)
+	if err != nil {
+		return err
+	}
+	summary := history.Summarize(played, cmd.Top, cmd.By)
+
+	if cmd.Output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(summary)
+	}
+
+	if summary.Total.Plays == 0 {
+		fmt.Println("Nothing played in that time")
+		return nil
+	}
+
+	fmt.Printf("%s from %s to %s, %s listened, %s skipped\n",
+		plural(summary.Total.Plays, "play"),
+		summary.First.Local().Format(dateFormat),
+		summary.Last.Local().Format(dateFormat),
+		listening(summary.Total.Listened),
+		percent(summary.Total.SkipRate()),
+	)
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	section := func(title string, tallies []history.Tally, ranked bool) {
+		if len(tallies) == 0 {
+			return
+		}
+		fmt.Fprintf(out, "\n%s\n", title)
+		for idx, tally := range tallies {
+			rank := ""
+			if ranked {
+				rank = strconv.Itoa(idx + 1)
+			}
+			fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%s skipped\n",
+				rank,
+				tally.Name,
+				plural(tally.Plays, "play"),
+				listening(tally.Listened),
+				percent(tally.SkipRate()),
+			)
+		}
+	}
+	section("Top artists", summary.Artists, true)
+	section("Top albums", summary.Albums, true)
+	section("Top tracks", summary.Tracks, true)
+	section(fmt.Sprintf("By %s", cmd.By), summary.Periods, false)
+	return out.Flush()
+}
+
+// parseSince takes a date, or a while back from now like 7d or 4w
+func parseSince(raw string, now time.Time) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if day, err := time.ParseInLocation(dateFormat, raw, time.Local); err == nil {
+		return day, nil
+	}
+
+	units := map[string]time.Duration{
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+	}
+	for suffix, unit := range units {
+		if count, err := strconv.Atoi(strings.TrimSuffix(raw, suffix)); err == nil && strings.HasSuffix(raw, suffix) && count > 0 {
+			return now.Add(-time.Duration(count) * unit), nil
+		}
+	}
+	if back, err := time.ParseDuration(raw); err == nil && back > 0 {
+		return now.Add(-back), nil
+	}
+	return time.Time{}, fmt.Errorf("--since should be a date like 2026-01-31 or a while back like 7d, not '%s'", raw)
+}
+
+// parseUntil takes a date, counting the whole of that day
+func parseUntil(raw string) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	day, err := time.ParseInLocation(dateFormat, raw, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("--until should be a date like 2026-02-28, not '%s'", raw)
+	}
+	return day.AddDate(0, 0, 1), nil
+}
+
+// listening is a number of seconds to the minute, like 3h 12m
+func listening(secs float64) string {
+	minutes := int((secs + 30) / 60)
+	if minutes < 60 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return fmt.Sprintf("%dh %dm", minutes/60, minutes%60)
+}
+
+func percent(rate float64) string {
+	return fmt.Sprintf("%.0f%%", rate*100)
+}
+
+func plural(count int, noun string) string {
+	if count == 1 {
+		return fmt.Sprintf("1 %s", noun)
+	}
+	return fmt.Sprintf("%d %ss", count, noun)
+}
@@ -0,0 +1,205 @@
+package main
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/config"
+	"git.sr.ht/~sungo/hedgehog/pkg/control"
+	"git.sr.ht/~sungo/hedgehog/pkg/keymap"
+)
+
+// How long --follow waits before looking for a hedgehog again, when none is
+// running
+const followRetry = 5 * time.Second
+
+// i3barClicks maps i3bar mouse buttons to player actions
+var i3barClicks = map[int]keymap.Action{
+	1: keymap.Pause,
+	2: keymap.Star,
+	3: keymap.Next,
+}
+
+type (
+	StatusCmd struct {
+		Format string `kong:"optional,default='{{.Artist}} - {{.Title}}',name='format',env='SONIC_STATUS_FORMAT',help='go text/template for the status line. Fields: Status, Title, Artist, Album, Year, Details, Starred, Live, Playlist, Elapsed, Length, Percent'"`
+		Follow bool   `kong:"optional,name='follow',short='f',help='keep going, printing a line every time something changes'"`
+		Output string `kong:"optional,default='text',enum='text,json,i3bar,waybar',name='output',env='SONIC_STATUS_OUTPUT',help='text, json, i3bar (implies --follow, handles clicks) or waybar (a custom module with return-type json)'"`
+	}
+
+	RemoteCmd struct {
+		Command string `kong:"arg,help='what to do: pause, next, previous, star, mute, reload, similar, sleep, wake, stop, snooze or quit'"`
+	}
+)
+
+// socket is the control socket of the running hedgehog
+func (cli *CLI) socket() (string, error) {
+	if cli.Socket != "" {
+		return cli.Socket, nil
+	}
+	dir, err := config.RuntimeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "hedgehog.sock"), nil
+}
+
+func (cmd StatusCmd) Run(cli *CLI) error {
+	tmpl, err := template.New("status").Option("missingkey=zero").Parse(cmd.Format)
+	if err != nil {
+		return fmt.Errorf("bad --format: %w", err)
+	}
+
+	socket, err := cli.socket()
+	if err != nil {
+		return err
+	}
+
+	out := statusWriter{w: bufio.NewWriter(os.Stdout), tmpl: tmpl, output: cmd.Output}
+
+	if cmd.Output == "i3bar" {
+		if err := out.i3barHeader(); err != nil {
+			return err
+		}
+		go i3barClickEvents(os.Stdin, socket)
+	} else if !cmd.Follow {
+		state, err := control.Status(socket)
+		if err != nil {
+			return err
+		}
+		return out.write(state)
+	}
+
+	for {
+		err := control.Follow(socket, out.write)
+		if err != nil && !errors.Is(err, control.ErrNotRunning) {
+			return err
+		}
+		if err := out.write(control.State{Status: control.Stopped}); err != nil {
+			return err
+		}
+		time.Sleep(followRetry)
+	}
+}
+
+func (cmd RemoteCmd) Run(cli *CLI) error {
+	socket, err := cli.socket()
+	if err != nil {
+		return err
+	}
+	return control.Send(socket, cmd.Command)
+}
+
+// statusWriter prints states in one of the output formats, skipping any
+// that would look the same as the last one
+type statusWriter struct {
+	w      *bufio.Writer
+	tmpl   *template.Template
+	output string
+	last   string
+}
+
+func (out *statusWriter) write(state control.State) error {
+	line, err := out.line(state)
+	if err != nil {
+		return err
+	}
+	if line == out.last {
+		return nil
+	}
+	out.last = line
+
+	if _, err := fmt.Fprintln(out.w, line); err != nil {
+		return err
+	}
+	return out.w.Flush()
+}
+
+func (out *statusWriter) line(state control.State) (string, error) {
+	if out.output == "json" {
+		data, err := json.Marshal(state)
+		return string(data), err
+	}
+
+	text := ""
+	if state.Status != control.Stopped {
+		var buf strings.Builder
+		if err := out.tmpl.Execute(&buf, state); err != nil {
+			return "", err
+		}
+		text = strings.ReplaceAll(buf.String(), "\n", " ")
+	}
+
+	var data []byte
+	var err error
+	switch out.output {
+	case "i3bar":
+		block := map[string]interface{}{
+			"name":      "hedgehog",
+			"full_text": text,
+		}
+		if state.Status == control.Paused {
+			block["color"] = "#888888"
+		}
+		data, err = json.Marshal([]interface{}{block})
+		// Every update is an element of one never ending array
+		return string(data) + ",", err
+
+	case "waybar":
+		tooltip := ""
+		if state.Status != control.Stopped {
+			tooltip = fmt.Sprintf("%s\n%s\n%s", state.Title, state.Artist, state.Album)
+		}
+		data, err = json.Marshal(map[string]interface{}{
+			"text":       text,
+			"tooltip":    tooltip,
+			"alt":        state.Status,
+			"class":      state.Status,
+			"percentage": state.Percent(),
+		})
+		return string(data), err
+	}
+	return text, nil
+}
+
+func (out *statusWriter) i3barHeader() error {
+	if _, err := fmt.Fprintln(out.w, `{"version":1,"click_events":true}`); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(out.w, "["); err != nil {
+		return err
+	}
+	return out.w.Flush()
+}
+
+// i3barClickEvents reads the click events i3bar sends on stdin, an endless
+// JSON array of objects, and passes them on to the player
+func i3barClickEvents(in io.Reader, socket string) {
+	decoder := json.NewDecoder(in)
+	if _, err := decoder.Token(); err != nil {
+		return
+	}
+	for decoder.More() {
+		var click struct {
+			Name   string `json:"name"`
+			Button int    `json:"button"`
+		}
+		if err := decoder.Decode(&click); err != nil {
+			return
+		}
+		if action, ok := i3barClicks[click.Button]; ok && click.Name == "hedgehog" {
+			control.Send(socket, string(action))
+		}
+	}
+}
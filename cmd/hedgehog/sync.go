@@ -0,0 +1,88 @@
+package main
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"fmt"
+	"path/filepath"
+
+	progressbar "github.com/schollz/progressbar/v3"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/config"
+	"git.sr.ht/~sungo/hedgehog/pkg/library"
+	"git.sr.ht/~sungo/hedgehog/pkg/sonic"
+)
+
+type SyncCmd struct {
+	Playlists []string `kong:"arg,required,name='playlist',help='playlists to download for offline play'"`
+	Library   string   `kong:"optional,name='library',env='SONIC_LIBRARY',help='where synced playlists are kept (default: $XDG_DATA_HOME/hedgehog/library)'"`
+}
+
+func libraryDir(flag string) (string, error) {
+	if flag != "" {
+		return flag, nil
+	}
+
+	dataDir, err := config.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, "library"), nil
+}
+
+func (cmd SyncCmd) Run(cli *CLI) error {
+	password, err := cli.password()
+	if err != nil {
+		return err
+	}
+
+	dir, err := libraryDir(cmd.Library)
+	if err != nil {
+		return err
+	}
+
+	lib, err := library.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	client, err := cli.newClient(password)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range cmd.Playlists {
+		fmt.Printf("Fetching playlist '%s'\n", name)
+		playlist, err := client.GetPlaylistByName(name)
+		if err != nil {
+			return err
+		}
+
+		bar := progressbar.NewOptions(len(playlist.Songs),
+			progressbar.OptionFullWidth(),
+			progressbar.OptionShowCount(),
+			progressbar.OptionClearOnFinish(),
+		)
+
+		downloaded := 0
+		failed, err := lib.Sync(&client, playlist, func(done int, total int, song sonic.Song, skipped bool) {
+			if !skipped {
+				downloaded++
+			}
+			bar.Describe(fmt.Sprintf("%s : %s", song.ArtistName(), song.Title))
+			bar.Set(done)
+		})
+		bar.Finish()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("=> '%s': %d songs, %d new, in %s\n", name, len(playlist.Songs)-failed, downloaded-failed, dir)
+		if failed > 0 {
+			fmt.Printf("=> %d songs failed to download and were left out, sync again to retry them (see the log for why)\n", failed)
+		}
+	}
+
+	return nil
+}
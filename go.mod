@@ -7,18 +7,18 @@ require (
 	github.com/blang/mpv v0.0.0-20160810175505-d56d7352e068
 	github.com/dghubble/sling v1.4.1
 	github.com/eiannone/keyboard v0.0.0-20220611211555-0d226195f203
+	github.com/gen2brain/beeep v0.0.0-20230907135156-1a38885a97fc
+	github.com/godbus/dbus/v5 v5.1.0
 	github.com/schollz/progressbar/v3 v3.14.1
+	golang.org/x/term v0.14.0
 )
 
 require (
-	github.com/gen2brain/beeep v0.0.0-20230907135156-1a38885a97fc // indirect
 	github.com/go-toast/toast v0.0.0-20190211030409-01e6764cf0a4 // indirect
-	github.com/godbus/dbus/v5 v5.1.0 // indirect
 	github.com/google/go-querystring v1.1.0 // indirect
 	github.com/mitchellh/colorstring v0.0.0-20190213212951-d06e56a500db // indirect
 	github.com/nu7hatch/gouuid v0.0.0-20131221200532-179d4d0c4d8d // indirect
 	github.com/rivo/uniseg v0.4.4 // indirect
 	github.com/tadvi/systray v0.0.0-20190226123456-11a2b8fa57af // indirect
 	golang.org/x/sys v0.14.0 // indirect
-	golang.org/x/term v0.14.0 // indirect
 )
@@ -0,0 +1,275 @@
+package config
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+// A config file holds shared settings at the top level and named profiles
+// under [profiles.<name>]. Keys are the long flag names.
+//
+//	profile = "home"   # used when --profile isn't given
+//	shuffle = true
+//
+//	[profiles.home]
+//	url = "https://music.home"
+//	user = "sungo"
+//	playlist = "starred"
+//
+//	[profiles.office]
+//	url = "https://music.office"
+//	user = "sungo"
+//	playlist = "work"
+//	max-bitrate = 192
+//
+// Precedence, highest first: command line flags, SONIC_* environment
+// variables, the selected profile, the top level of the file, and finally
+// the flag defaults.
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+
+	"github.com/alecthomas/kong"
+)
+
+const (
+	ProfileFlag = "profile"
+	profilesKey = "profiles"
+)
+
+type File struct {
+	Table
+}
+
+// Every file handed to kong, so a profile defined in any of them (say, the
+// default file and one passed with --config) counts as known
+var (
+	loaded     []File
+	loadedLock sync.Mutex
+)
+
+// Path is the default location of the config file,
+// $XDG_CONFIG_HOME/hedgehog/config.toml on most unixes
+func Path() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "hedgehog", "config.toml")
+}
+
+// StateDir is where hedgehog keeps data that should survive restarts but
+// isn't configuration, $XDG_STATE_HOME/hedgehog or ~/.local/state/hedgehog
+func StateDir() (string, error) {
+	return xdgDir("XDG_STATE_HOME", ".local", "state")
+}
+
+// DataDir is where hedgehog keeps user data like the offline library,
+// $XDG_DATA_HOME/hedgehog or ~/.local/share/hedgehog
+func DataDir() (string, error) {
+	return xdgDir("XDG_DATA_HOME", ".local", "share")
+}
+
+// RuntimeDir is for things that only matter while hedgehog is running, like
+// its control socket, $XDG_RUNTIME_DIR/hedgehog. Without XDG_RUNTIME_DIR,
+// it's a directory of our own under the system temp dir, which anyone could
+// have made first, so it has to belong to us and nobody else can get in.
+func RuntimeDir() (string, error) {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "hedgehog"), nil
+	}
+
+	dir := filepath.Join(os.TempDir(), fmt.Sprintf("hedgehog-%d", os.Getuid()))
+	if err := os.Mkdir(dir, 0o700); err != nil && !os.IsExist(err) {
+		return "", err
+	}
+	if err := checkPrivate(dir); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// checkPrivate makes sure dir is a real directory, ours, and closed to
+// everyone else
+func checkPrivate(dir string) error {
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s isn't a directory", dir)
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && int(stat.Uid) != os.Getuid() {
+		return fmt.Errorf("%s belongs to someone else (uid %d)", dir, stat.Uid)
+	}
+	if perm := info.Mode().Perm(); perm != 0o700 {
+		return fmt.Errorf("%s should have mode 0700, not %04o", dir, perm)
+	}
+	return nil
+}
+
+func xdgDir(env string, fallback ...string) (string, error) {
+	if dir := os.Getenv(env); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "hedgehog"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(append(append([]string{home}, fallback...), "hedgehog")...), nil
+}
+
+func Parse(r io.Reader) (File, error) {
+	table, err := parseTOML(r)
+	if err != nil {
+		return File{}, err
+	}
+	return File{table}, nil
+}
+
+// DefaultProfile is the profile named at the top of the file, if any
+func (file File) DefaultProfile() string {
+	return file.String(ProfileFlag)
+}
+
+// Profile returns the settings for the named profile
+func (file File) Profile(name string) (Table, error) {
+	profile := file.Table.Table(profilesKey).Table(name)
+	if profile == nil {
+		return nil, fmt.Errorf("unknown profile '%s' (have: %v)", name, file.Profiles())
+	}
+	return profile, nil
+}
+
+func (file File) Profiles() []string {
+	names := make([]string, 0)
+	for name := range file.Table.Table(profilesKey) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup finds the value for a key, preferring the given profile over the
+// top level of the file
+func (file File) Lookup(profile string, key string) (interface{}, error) {
+	if profile != "" {
+		settings, err := file.Profile(profile)
+		if err != nil {
+			return nil, err
+		}
+		if value, ok := settings[key]; ok {
+			return value, nil
+		}
+	}
+
+	if key == profilesKey {
+		return nil, nil
+	}
+	return file.Table[key], nil
+}
+
+// Loader is a kong.ConfigurationLoader for hedgehog config files
+func Loader(r io.Reader) (kong.Resolver, error) {
+	file, err := Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	loadedLock.Lock()
+	loaded = append(loaded, file)
+	loadedLock.Unlock()
+
+	return resolver{file}, nil
+}
+
+type resolver struct {
+	file File
+}
+
+// Validate runs after kong has applied every value, so the active profile
+// is known by now and we can complain about typos in it
+func (res resolver) Validate(app *kong.Application) error {
+	for _, flag := range app.Flags {
+		if flag.Name != ProfileFlag || !flag.Target.IsValid() {
+			continue
+		}
+		if name := flag.Target.String(); name != "" {
+			return knownProfile(name)
+		}
+	}
+	return nil
+}
+
+func (res resolver) Resolve(ctx *kong.Context, parent *kong.Path, flag *kong.Flag) (interface{}, error) {
+	// Environment variables beat the config file
+	for _, env := range flag.Envs {
+		if _, ok := os.LookupEnv(env); ok {
+			return nil, nil
+		}
+	}
+
+	if flag.Name == ProfileFlag {
+		if name := res.file.DefaultProfile(); name != "" {
+			return name, nil
+		}
+		return nil, nil
+	}
+
+	value, err := res.file.Lookup(res.profile(ctx), flag.Name)
+	if err != nil {
+		// Unknown profiles are reported by Validate
+		return nil, nil
+	}
+	return plain(value), nil
+}
+
+// plain turns tables back into the map[string]interface{} that kong's map
+// decoder recognizes
+func plain(value interface{}) interface{} {
+	table, ok := value.(Table)
+	if !ok {
+		return value
+	}
+
+	out := make(map[string]interface{}, len(table))
+	for key, sub := range table {
+		out[key] = plain(sub)
+	}
+	return out
+}
+
+func knownProfile(name string) error {
+	loadedLock.Lock()
+	defer loadedLock.Unlock()
+
+	var err error
+	for _, file := range loaded {
+		if _, err = file.Profile(name); err == nil {
+			return nil
+		}
+	}
+	if err == nil {
+		err = fmt.Errorf("unknown profile '%s' (no config file found)", name)
+	}
+	return err
+}
+
+// profile figures out which profile is active. The --profile flag (or its
+// env var) wins, followed by the default named in the file.
+func (res resolver) profile(ctx *kong.Context) string {
+	for _, flag := range ctx.Flags() {
+		if flag.Name != ProfileFlag {
+			continue
+		}
+		if name, ok := ctx.FlagValue(flag).(string); ok && name != "" {
+			return name
+		}
+	}
+	return res.file.DefaultProfile()
+}
@@ -0,0 +1,418 @@
+package config
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+// This is a small reader for the part of TOML that a hedgehog config needs,
+// not a full TOML parser. Files are read a line at a time, and each line has
+// to be one of:
+//
+//   - blank, or a # comment
+//   - a [table] header made of bare or quoted keys joined with dots
+//   - key = value, where the key may be dotted the same way
+//
+// Values are "basic" or 'literal' strings, decimal, hex, octal and binary
+// integers, floats (inf and nan included), true and false, and arrays of
+// those. Strings and arrays have to close on the line they open on. A table
+// is defined once, by a header or by dotted keys but not both.
+//
+// Anything else is an error naming its line. That covers multi-line strings
+// and arrays, inline tables, [[arrays of tables]], dates and times, invalid
+// UTF-8 and control characters other than tab.
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	bareKey      = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	decimalInt   = regexp.MustCompile(`^[+-]?(0|[1-9](_?[0-9])*)$`)
+	prefixInt    = regexp.MustCompile(`^0(x[0-9a-fA-F](_?[0-9a-fA-F])*|o[0-7](_?[0-7])*|b[01](_?[01])*)$`)
+	decimalFloat = regexp.MustCompile(`^[+-]?(0|[1-9](_?[0-9])*)(\.[0-9](_?[0-9])*)?([eE][+-]?[0-9](_?[0-9])*)?$`)
+)
+
+type Table map[string]interface{}
+
+func parseTOML(r io.Reader) (Table, error) {
+	root := make(Table)
+	current := root
+	var currentPath []string
+	// How each table was defined, by header or by dotted key
+	defined := make(map[string]string)
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		if err := checkLine(scanner.Text()); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") || strings.HasPrefix(line, "[[") {
+				return nil, fmt.Errorf("line %d: unsupported table header %q", lineNo, line)
+			}
+			path, err := splitKey(strings.TrimSpace(line[1 : len(line)-1]))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			switch defined[tablePath(path)] {
+			case "header":
+				return nil, fmt.Errorf("line %d: table [%s] is defined twice", lineNo, strings.Join(path, "."))
+			case "dotted":
+				return nil, fmt.Errorf("line %d: table [%s] is already defined by dotted keys", lineNo, strings.Join(path, "."))
+			}
+			defined[tablePath(path)] = "header"
+			current, err = root.descend(path)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			currentPath = path
+			continue
+		}
+
+		eq := indexOutsideQuotes(line, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNo)
+		}
+
+		path, err := splitKey(strings.TrimSpace(line[:eq]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		value, err := parseValue(strings.TrimSpace(line[eq+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+
+		for idx := range path[:len(path)-1] {
+			full := append(append([]string{}, currentPath...), path[:idx+1]...)
+			if defined[tablePath(full)] == "header" {
+				return nil, fmt.Errorf("line %d: table [%s] is already defined by a header", lineNo, strings.Join(full, "."))
+			}
+			defined[tablePath(full)] = "dotted"
+		}
+		table, err := current.descend(path[:len(path)-1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		key := path[len(path)-1]
+		if _, ok := table[key]; ok {
+			return nil, fmt.Errorf("line %d: duplicate key %q", lineNo, key)
+		}
+		table[key] = value
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("line %d: %w", lineNo+1, err)
+	}
+	return root, nil
+}
+
+func tablePath(path []string) string {
+	return strings.Join(path, "\x00")
+}
+
+// checkLine turns away what TOML doesn't allow anywhere in a file, even in
+// comments
+func checkLine(line string) error {
+	if !utf8.ValidString(line) {
+		return fmt.Errorf("invalid UTF-8")
+	}
+	for _, char := range line {
+		if (char < 0x20 && char != '\t') || char == 0x7f {
+			return fmt.Errorf("control character %U", char)
+		}
+	}
+	return nil
+}
+
+func (table Table) descend(path []string) (Table, error) {
+	current := table
+	for _, part := range path {
+		next, ok := current[part]
+		if !ok {
+			created := make(Table)
+			current[part] = created
+			current = created
+			continue
+		}
+		sub, ok := next.(Table)
+		if !ok {
+			return nil, fmt.Errorf("%q is a value, not a table", part)
+		}
+		current = sub
+	}
+	return current, nil
+}
+
+// Table returns the named sub-table, or nil if it doesn't exist
+func (table Table) Table(name string) Table {
+	sub, _ := table[name].(Table)
+	return sub
+}
+
+// String returns the named string value, or "" if it isn't a string
+func (table Table) String(name string) string {
+	str, _ := table[name].(string)
+	return str
+}
+
+func stripComment(line string) string {
+	if idx := indexOutsideQuotes(line, '#'); idx >= 0 {
+		return line[:idx]
+	}
+	return line
+}
+
+func indexOutsideQuotes(line string, target byte) int {
+	var quote byte
+	for idx := 0; idx < len(line); idx++ {
+		char := line[idx]
+		switch {
+		case quote != 0:
+			if char == '\\' && quote == '"' {
+				idx++
+			} else if char == quote {
+				quote = 0
+			}
+		case char == '"' || char == '\'':
+			quote = char
+		case char == target:
+			return idx
+		}
+	}
+	return -1
+}
+
+func splitKey(key string) ([]string, error) {
+	if key == "" {
+		return nil, fmt.Errorf("empty key")
+	}
+
+	var parts []string
+	for key != "" {
+		var part string
+		switch key[0] {
+		case '"', '\'':
+			var err error
+			if part, key, err = readString(key); err != nil {
+				return nil, fmt.Errorf("key: %w", err)
+			}
+			key = strings.TrimSpace(key)
+		default:
+			end := strings.IndexByte(key, '.')
+			if end < 0 {
+				end = len(key)
+			}
+			part = strings.TrimSpace(key[:end])
+			key = key[end:]
+			if !bareKey.MatchString(part) {
+				return nil, fmt.Errorf("invalid key %q", part)
+			}
+		}
+
+		parts = append(parts, part)
+		if key == "" {
+			break
+		}
+		if key[0] != '.' {
+			return nil, fmt.Errorf("expected '.' in key")
+		}
+		key = strings.TrimSpace(key[1:])
+		if key == "" {
+			return nil, fmt.Errorf("trailing '.' in key")
+		}
+	}
+	return parts, nil
+}
+
+func parseValue(raw string) (interface{}, error) {
+	switch {
+	case raw == "":
+		return nil, fmt.Errorf("missing value")
+
+	case raw == "true":
+		return true, nil
+
+	case raw == "false":
+		return false, nil
+
+	case raw[0] == '"' || raw[0] == '\'':
+		if strings.HasPrefix(raw, `"""`) || strings.HasPrefix(raw, "'''") {
+			return nil, fmt.Errorf("multi-line strings are not supported")
+		}
+		str, rest, err := readString(raw)
+		if err != nil {
+			return nil, err
+		}
+		if rest = strings.TrimSpace(rest); rest != "" {
+			return nil, fmt.Errorf("unexpected %s after a string", rest)
+		}
+		return str, nil
+
+	case strings.HasPrefix(raw, "["):
+		if !strings.HasSuffix(raw, "]") {
+			return nil, fmt.Errorf("arrays must fit on one line")
+		}
+		return parseArray(strings.TrimSpace(raw[1 : len(raw)-1]))
+
+	case strings.HasPrefix(raw, "{"):
+		return nil, fmt.Errorf("inline tables are not supported")
+	}
+
+	clean := strings.ReplaceAll(raw, "_", "")
+	switch {
+	case decimalInt.MatchString(raw):
+		return strconv.ParseInt(clean, 10, 64)
+	case prefixInt.MatchString(raw):
+		return strconv.ParseInt(clean, 0, 64)
+	case decimalFloat.MatchString(raw):
+		return strconv.ParseFloat(clean, 64)
+	case raw == "inf" || raw == "+inf":
+		return math.Inf(1), nil
+	case raw == "-inf":
+		return math.Inf(-1), nil
+	case raw == "nan" || raw == "+nan" || raw == "-nan":
+		return math.NaN(), nil
+	}
+	return nil, fmt.Errorf("unable to parse value %s", raw)
+}
+
+// readString reads a quoted string from the start of raw, returning it and
+// whatever comes after the closing quote. "Basic" strings take TOML's
+// escapes, 'literal' ones are taken as they are.
+func readString(raw string) (string, string, error) {
+	quote := raw[0]
+	var out strings.Builder
+	for idx := 1; idx < len(raw); idx++ {
+		char := raw[idx]
+		switch {
+		case char == quote:
+			return out.String(), raw[idx+1:], nil
+
+		case (char < 0x20 && char != '\t') || char == 0x7f:
+			return "", "", fmt.Errorf("control character in string")
+
+		case char == '\\' && quote == '"':
+			idx++
+			if idx == len(raw) {
+				return "", "", fmt.Errorf("unterminated string")
+			}
+			switch escape := raw[idx]; escape {
+			case 'b':
+				out.WriteByte('\b')
+			case 't':
+				out.WriteByte('\t')
+			case 'n':
+				out.WriteByte('\n')
+			case 'f':
+				out.WriteByte('\f')
+			case 'r':
+				out.WriteByte('\r')
+			case '"', '\\':
+				out.WriteByte(escape)
+			case 'u', 'U':
+				size := 4
+				if escape == 'U' {
+					size = 8
+				}
+				if idx+size >= len(raw) {
+					return "", "", fmt.Errorf("short \\%c escape", escape)
+				}
+				code, err := strconv.ParseUint(raw[idx+1:idx+1+size], 16, 32)
+				if err != nil || !utf8.ValidRune(rune(code)) {
+					return "", "", fmt.Errorf("invalid \\%c escape %s", escape, raw[idx+1:idx+1+size])
+				}
+				out.WriteRune(rune(code))
+				idx += size
+			default:
+				return "", "", fmt.Errorf("invalid escape \\%c", escape)
+			}
+
+		default:
+			out.WriteByte(char)
+		}
+	}
+	return "", "", fmt.Errorf("unterminated string")
+}
+
+func parseArray(raw string) ([]interface{}, error) {
+	items, err := splitArray(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, 0, len(items))
+	for idx, item := range items {
+		if item == "" {
+			// Only a trailing comma leaves an empty item behind
+			if idx == len(items)-1 && idx > 0 {
+				break
+			}
+			return nil, fmt.Errorf("missing value in array")
+		}
+		value, err := parseValue(item)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// splitArray splits the inside of an array on the commas that aren't in a
+// string or a nested array
+func splitArray(raw string) ([]string, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var (
+		items []string
+		quote byte
+		depth int
+		start int
+	)
+	for idx := 0; idx < len(raw); idx++ {
+		char := raw[idx]
+		switch {
+		case quote != 0:
+			if char == '\\' && quote == '"' {
+				idx++
+			} else if char == quote {
+				quote = 0
+			}
+		case char == '"' || char == '\'':
+			quote = char
+		case char == '[' || char == '{':
+			depth++
+		case char == ']' || char == '}':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unbalanced %c in array", char)
+			}
+		case char == ',' && depth == 0:
+			items = append(items, strings.TrimSpace(raw[start:idx]))
+			start = idx + 1
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated string in array")
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced brackets in array")
+	}
+	return append(items, strings.TrimSpace(raw[start:])), nil
+}
@@ -0,0 +1,284 @@
+package config
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want interface{}
+	}{
+		// Quoting
+		{`"plain"`, "plain"},
+		{`""`, ""},
+		{`"tab\there"`, "tab\there"},
+		{`"line\nbreak\r"`, "line\nbreak\r"},
+		{`"\b\f"`, "\b\f"},
+		{`"say \"hi\""`, `say "hi"`},
+		{`"C:\\music"`, `C:\music`},
+		{`"caf\u00e9"`, "café"},
+		{`"\U0001F994"`, "🦔"},
+		{`"it's"`, "it's"},
+		{`'C:\music\n'`, `C:\music\n`},
+		{`'say "hi"'`, `say "hi"`},
+		{`''`, ""},
+		{`"a # not a comment"`, "a # not a comment"},
+		{`"a" `, "a"},
+
+		// Numbers and booleans
+		{`true`, true},
+		{`false`, false},
+		{`0`, int64(0)},
+		{`192`, int64(192)},
+		{`+17`, int64(17)},
+		{`-3`, int64(-3)},
+		{`1_000`, int64(1000)},
+		{`0xdead_BEEF`, int64(0xdeadbeef)},
+		{`0o755`, int64(0755)},
+		{`0b1010`, int64(10)},
+		{`1.5`, 1.5},
+		{`-0.25`, -0.25},
+		{`5e+2`, 500.0},
+		{`1_0.2_5E-1`, 1.025},
+		{`inf`, math.Inf(1)},
+		{`-inf`, math.Inf(-1)},
+
+		// Arrays
+		{`[]`, []interface{}{}},
+		{`[ 1, 2, 3 ]`, []interface{}{int64(1), int64(2), int64(3)}},
+		{`["a", 'b', ]`, []interface{}{"a", "b"}},
+		{`["a, b", "c]"]`, []interface{}{"a, b", "c]"}},
+		{`["say \"a, b\"", 'x']`, []interface{}{`say "a, b"`, "x"}},
+		{`[1, "two", 3.0, true]`, []interface{}{int64(1), "two", 3.0, true}},
+		{`[[1, 2], ["a"], []]`, []interface{}{
+			[]interface{}{int64(1), int64(2)},
+			[]interface{}{"a"},
+			[]interface{}{},
+		}},
+	}
+
+	for _, test := range tests {
+		got, err := parseValue(test.raw)
+		if err != nil {
+			t.Errorf("%s: %s", test.raw, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s is %#v, want %#v", test.raw, got, test.want)
+		}
+	}
+
+	if got, err := parseValue("nan"); err != nil || !math.IsNaN(got.(float64)) {
+		t.Errorf("nan is %v, %v", got, err)
+	}
+}
+
+func TestParseValueRejects(t *testing.T) {
+	tests := []string{
+		``,
+		`plain`,
+		`"unterminated`,
+		`'unterminated`,
+		`"a" "b"`,
+		`'a' 'b'`,
+		`'it's'`,
+		`"\x41"`,
+		`"\101"`,
+		`"\a"`,
+		`"\'"`,
+		`"\u12"`,
+		`"\uD800"`,
+		`"\U00110000"`,
+		"\"bell\a\"",
+		`"""multi"""`,
+		`'''multi'''`,
+		`{ a = 1 }`,
+		`[1, 2`,
+		`[1,,2]`,
+		`[,]`,
+		`[1, [2]`,
+		`["a]`,
+		`[{ a = 1 }]`,
+		`017`,
+		`+0x1`,
+		`1__000`,
+		`_1`,
+		`1_`,
+		`0x`,
+		`0o8`,
+		`1.`,
+		`.5`,
+		`1e`,
+		`Inf`,
+		`infinity`,
+		`0x1p-2`,
+		`TRUE`,
+		`1979-05-27`,
+	}
+
+	for _, raw := range tests {
+		if got, err := parseValue(raw); err == nil {
+			t.Errorf("%s parsed as %#v", raw, got)
+		}
+	}
+}
+
+func TestSplitKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want []string
+	}{
+		{`url`, []string{"url"}},
+		{`max-bitrate`, []string{"max-bitrate"}},
+		{`a_b-9`, []string{"a_b-9"}},
+		{`profiles.home`, []string{"profiles", "home"}},
+		{`profiles . home`, []string{"profiles", "home"}},
+		{`"with space"`, []string{"with space"}},
+		{`"a.b".c`, []string{"a.b", "c"}},
+		{`'lit\n'.x`, []string{`lit\n`, "x"}},
+		{`"say \"hi\""`, []string{`say "hi"`}},
+		{`""`, []string{""}},
+	}
+	for _, test := range tests {
+		got, err := splitKey(test.key)
+		if err != nil {
+			t.Errorf("%s: %s", test.key, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s is %q, want %q", test.key, got, test.want)
+		}
+	}
+
+	for _, key := range []string{``, `a b`, `a.`, `.a`, `a..b`, `café`, `"a"b`, `"open`} {
+		if got, err := splitKey(key); err == nil {
+			t.Errorf("%s split into %q", key, got)
+		}
+	}
+}
+
+func TestParseTOML(t *testing.T) {
+	input := `
+# Comments, blank lines and trailing comments are ignored
+profile = "home" # the default
+
+shuffle = true
+tags = ["a # b", "c"]  # still a comment
+colors.fg = "white"
+
+[profiles.home]
+url = "https://music.home/#/ok"
+"max-bitrate" = 320
+
+[ profiles . "the office" ]
+url = 'https://music.office'
+notify.body = "{{.Title}}"
+
+[profiles.home.extra]
+deep = 1
+`
+	got, err := parseTOML(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Table{
+		"profile": "home",
+		"shuffle": true,
+		"tags":    []interface{}{"a # b", "c"},
+		"colors":  Table{"fg": "white"},
+		"profiles": Table{
+			"home": Table{
+				"url":         "https://music.home/#/ok",
+				"max-bitrate": int64(320),
+				"extra":       Table{"deep": int64(1)},
+			},
+			"the office": Table{
+				"url":    "https://music.office",
+				"notify": Table{"body": "{{.Title}}"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v\nwant %#v", got, want)
+	}
+}
+
+func TestParseTOMLRejects(t *testing.T) {
+	tests := map[string]string{
+		"duplicate key":       "a = 1\na = 2",
+		"duplicate quoted":    "a = 1\n\"a\" = 2",
+		"duplicate table":     "[a]\nx = 1\n[a]\ny = 2",
+		"value then table":    "a = 1\n[a]",
+		"value then dotted":   "a = 1\na.b = 2",
+		"table then value":    "[a.b]\n[a]\nb = 1",
+		"array of tables":     "[[a]]",
+		"open header":         "[a",
+		"empty header":        "[]",
+		"no equals":           "just words",
+		"no key":              "= 1",
+		"no value":            "a =",
+		"multi-line array":    "a = [\n1,\n]",
+		"multi-line string":   "a = \"\"\"\nhi\n\"\"\"",
+		"inline table":        "a = { b = 1 }",
+		"bad escape":          `a = "\q"`,
+		"junk after value":    "a = 1 2",
+		"junk after header":   "[a] b",
+		"junk after string":   `a = "b" c`,
+		"unquoted string":     "a = hello",
+		"leading zero":        "a = 08",
+		"space in bare key":   "a b = 1",
+		"unicode in bare key": "ключ = 1",
+		"header after dotted": "a.b = 1\n[a]",
+		"dotted then header":  "[x]\ny.z = 1\n[x.y]",
+		"dotted into header":  "[a.b]\n[a]\nb.c = 1",
+		"control in comment":  "a = 1 # \x01",
+		"stray carriage":      "a = 1\rb = 2",
+		"invalid utf-8":       "a = \"\xff\"",
+		"overlong line":       "a = \"" + strings.Repeat("x", 70000) + "\"",
+	}
+
+	for name, input := range tests {
+		if got, err := parseTOML(strings.NewReader(input)); err == nil {
+			t.Errorf("%s: parsed as %#v", name, got)
+		}
+	}
+}
+
+func TestParseTOMLTables(t *testing.T) {
+	// Sub-tables of a dotted table can still get their own header
+	input := "[fruit]\napple.color = \"red\"\n[fruit.apple.texture]\nsmooth = true\n"
+	got, err := parseTOML(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Table{"fruit": Table{"apple": Table{
+		"color":   "red",
+		"texture": Table{"smooth": true},
+	}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v\nwant %#v", got, want)
+	}
+}
+
+func TestParseTOMLLineNumbers(t *testing.T) {
+	tests := map[string]string{
+		"a = 1\n\n# fine\nb = {}": "line 4: ",
+		"[a]\nx = 1\n[b]\n[a]":    "line 4: ",
+		"a = 1\nb = \"\x00\"":     "line 2: ",
+		"a = 1\nb = 2\nc = \"" + strings.Repeat("x", 70000) + "\"": "line 3: ",
+	}
+	for input, want := range tests {
+		_, err := parseTOML(strings.NewReader(input))
+		if err == nil || !strings.HasPrefix(err.Error(), want) {
+			t.Errorf("%.20q: got %v, want %s...", input, err, want)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package control
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+// A playing hedgehog listens on a unix socket so other commands can ask
+// what's on and push its buttons. Each connection sends one request, a
+// line of JSON like {"command":"status"}, and gets lines of JSON back.
+//
+//	status   the current State
+//	follow   the current State, then another every time something happens
+//	<action> any key binding action, like pause or next
+//
+// Everything else is answered with an error.
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrNotRunning means nothing is listening on the socket
+var ErrNotRunning = errors.New("hedgehog isn't running")
+
+type (
+	request struct {
+		Command string `json:"command"`
+	}
+
+	response struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error,omitempty"`
+		State *State `json:"state,omitempty"`
+	}
+)
+
+// Server answers requests on the socket
+type Server struct {
+	path     string
+	listener net.Listener
+	hub      *Hub
+	do       func(command string) error
+
+	lock  sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+// Listen starts answering on the socket at path. Commands that aren't
+// status or follow are handed to do.
+func Listen(path string, hub *Hub, do func(command string) error) (*Server, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode().Type() != os.ModeSocket {
+			return nil, fmt.Errorf("%s is already there and isn't a socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("another hedgehog is already listening on %s", path)
+		}
+		// Left over from a hedgehog that didn't get to clean up
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	server := &Server{
+		path:     path,
+		listener: listener,
+		hub:      hub,
+		do:       do,
+		conns:    make(map[net.Conn]struct{}),
+	}
+	go server.accept()
+	return server, nil
+}
+
+func (server *Server) accept() {
+	for {
+		conn, err := server.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				slog.Warn("control socket stopped", "err", err)
+			}
+			return
+		}
+
+		server.lock.Lock()
+		server.conns[conn] = struct{}{}
+		server.lock.Unlock()
+
+		go func() {
+			defer func() {
+				server.lock.Lock()
+				delete(server.conns, conn)
+				server.lock.Unlock()
+				conn.Close()
+			}()
+			server.handle(conn)
+		}()
+	}
+}
+
+func (server *Server) handle(conn net.Conn) {
+	var req request
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		return
+	}
+	if err := json.Unmarshal(line, &req); err != nil {
+		json.NewEncoder(conn).Encode(response{Error: "bad request: " + err.Error()})
+		return
+	}
+	slog.Debug("control request", "command", req.Command)
+
+	encoder := json.NewEncoder(conn)
+	switch req.Command {
+	case "status":
+		state := server.hub.State()
+		encoder.Encode(response{OK: true, State: &state})
+
+	case "follow":
+		events, stop := server.hub.Subscribe()
+		defer stop()
+
+		state := server.hub.State()
+		if err := encoder.Encode(response{OK: true, State: &state}); err != nil {
+			return
+		}
+		for event := range events {
+			if err := encoder.Encode(response{OK: true, State: &event.State}); err != nil {
+				return
+			}
+			if event.Name == Exit {
+				return
+			}
+		}
+
+	default:
+		if err := server.do(req.Command); err != nil {
+			encoder.Encode(response{Error: err.Error()})
+			return
+		}
+		encoder.Encode(response{OK: true})
+	}
+}
+
+// Close stops listening, hangs up on everyone and removes the socket
+func (server *Server) Close() error {
+	err := server.listener.Close()
+
+	server.lock.Lock()
+	for conn := range server.conns {
+		conn.Close()
+	}
+	server.lock.Unlock()
+
+	os.Remove(server.path)
+	return err
+}
+
+// call sends a request to the hedgehog listening at path and hands each
+// response to each until it returns false or the connection closes
+func call(path string, command string, each func(response) bool) error {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return ErrNotRunning
+	}
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(request{Command: command}); err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(conn)
+	for {
+		var resp response
+		if err := decoder.Decode(&resp); err != nil {
+			return nil
+		}
+		if resp.Error != "" {
+			return errors.New(resp.Error)
+		}
+		if !each(resp) {
+			return nil
+		}
+	}
+}
+
+// Status asks the hedgehog listening at path what it's up to
+func Status(path string) (State, error) {
+	var state State
+	found := false
+	err := call(path, "status", func(resp response) bool {
+		if resp.State != nil {
+			state, found = *resp.State, true
+		}
+		return false
+	})
+	if err == nil && !found {
+		err = ErrNotRunning
+	}
+	return state, err
+}
+
+// Follow hands each a state now and every time something happens, until
+// the player stops or each returns an error
+func Follow(path string, each func(State) error) error {
+	var failed error
+	err := call(path, "follow", func(resp response) bool {
+		if resp.State == nil {
+			return true
+		}
+		failed = each(*resp.State)
+		return failed == nil
+	})
+	if failed != nil {
+		return failed
+	}
+	return err
+}
+
+// Send asks the hedgehog listening at path to do something, like pause
+func Send(path string, command string) error {
+	return call(path, command, func(response) bool { return false })
+}
@@ -0,0 +1,195 @@
+package control
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"fmt"
+	"sync"
+)
+
+const (
+	Playing = "playing"
+	Paused  = "paused"
+	Stopped = "stopped"
+)
+
+// Things that happen to the player, as handed to subscribers
+const (
+	TrackStart = "track-start"
+	TrackEnd   = "track-end"
+	Scrobble   = "scrobble"
+	Pause      = "pause"
+	Star       = "star"
+	Exit       = "exit"
+)
+
+// State is what the player is up to. Times are in seconds.
+type State struct {
+	Status   string  `json:"status"`
+	ID       string  `json:"id,omitempty"`
+	Title    string  `json:"title,omitempty"`
+	Artist   string  `json:"artist,omitempty"`
+	Album    string  `json:"album,omitempty"`
+	Year     int     `json:"year,omitempty"`
+	Starred  bool    `json:"starred"`
+	Live     bool    `json:"live,omitempty"`
+	Position float64 `json:"position"`
+	Duration float64 `json:"duration"`
+	Playlist string  `json:"playlist,omitempty"`
+
+	// Details is the extra the server knows about the track, like the
+	// genre and bpm. CoverArt is the server's id for its artwork.
+	Details  string `json:"details,omitempty"`
+	CoverArt string `json:"coverArt,omitempty"`
+}
+
+// Elapsed is the position as m:ss
+func (state State) Elapsed() string {
+	return clock(state.Position)
+}
+
+// Length is the duration as m:ss
+func (state State) Length() string {
+	return clock(state.Duration)
+}
+
+// Percent is how far into the track we are
+func (state State) Percent() int {
+	if state.Duration <= 0 {
+		return 0
+	}
+	return int(state.Position / state.Duration * 100)
+}
+
+func clock(secs float64) string {
+	total := int(secs)
+	if total >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", total/3600, total/60%60, total%60)
+	}
+	return fmt.Sprintf("%d:%02d", total/60, total%60)
+}
+
+// Event is something that happened, and the state right after
+type Event struct {
+	Name  string `json:"event"`
+	State State  `json:"state"`
+}
+
+// Hub keeps the current state and tells subscribers when something happens
+type Hub struct {
+	lock        sync.Mutex
+	state       State
+	subscribers map[*subscriber]struct{}
+}
+
+// subscriber queues events for one reader, so a slow one gets everything
+// late rather than holding up the player or missing things
+type subscriber struct {
+	out  chan Event
+	wake chan struct{}
+	done chan struct{}
+
+	lock    sync.Mutex
+	pending []Event
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		state:       State{Status: Stopped},
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+func (hub *Hub) State() State {
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+	return hub.state
+}
+
+// Update changes the state without telling anyone, for things like the
+// position that change all the time
+func (hub *Hub) Update(change func(*State)) {
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+	change(&hub.state)
+}
+
+// Publish changes the state and tells every subscriber. It doesn't wait
+// for them to read it.
+func (hub *Hub) Publish(name string, change func(*State)) {
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+
+	if change != nil {
+		change(&hub.state)
+	}
+	event := Event{Name: name, State: hub.state}
+	for sub := range hub.subscribers {
+		sub.push(event)
+	}
+}
+
+// Subscribe returns a channel of events, and a func to stop them
+func (hub *Hub) Subscribe() (<-chan Event, func()) {
+	sub := &subscriber{
+		out:  make(chan Event),
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+	go sub.run()
+
+	hub.lock.Lock()
+	hub.subscribers[sub] = struct{}{}
+	hub.lock.Unlock()
+
+	var once sync.Once
+	return sub.out, func() {
+		once.Do(func() {
+			hub.lock.Lock()
+			delete(hub.subscribers, sub)
+			hub.lock.Unlock()
+			close(sub.done)
+		})
+	}
+}
+
+func (sub *subscriber) push(event Event) {
+	sub.lock.Lock()
+	sub.pending = append(sub.pending, event)
+	sub.lock.Unlock()
+
+	select {
+	case sub.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run hands queued events to the reader, in order, until it unsubscribes
+func (sub *subscriber) run() {
+	for {
+		select {
+		case <-sub.wake:
+		case <-sub.done:
+			return
+		}
+
+		for {
+			sub.lock.Lock()
+			if len(sub.pending) == 0 {
+				sub.pending = nil
+				sub.lock.Unlock()
+				break
+			}
+			event := sub.pending[0]
+			sub.pending = sub.pending[1:]
+			sub.lock.Unlock()
+
+			select {
+			case sub.out <- event:
+			case <-sub.done:
+				return
+			}
+		}
+	}
+}
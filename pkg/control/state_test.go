@@ -0,0 +1,60 @@
+package control
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"testing"
+	"time"
+)
+
+// A subscriber that isn't reading yet still gets every event, in order
+func TestSlowSubscriber(t *testing.T) {
+	hub := NewHub()
+	events, stop := hub.Subscribe()
+	defer stop()
+
+	const count = 500
+	for idx := 0; idx < count; idx++ {
+		year := idx
+		hub.Publish(TrackStart, func(state *State) { state.Year = year })
+	}
+
+	for idx := 0; idx < count; idx++ {
+		select {
+		case event := <-events:
+			if event.Name != TrackStart || event.State.Year != idx {
+				t.Fatalf("event %d is %+v", idx, event)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("stuck after %d events", idx)
+		}
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	hub := NewHub()
+	events, stop := hub.Subscribe()
+	stop()
+	stop()
+
+	// Nobody's reading, and that's fine
+	done := make(chan struct{})
+	go func() {
+		for idx := 0; idx < 100; idx++ {
+			hub.Publish(Pause, nil)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("publishing blocked")
+	}
+
+	select {
+	case event := <-events:
+		t.Errorf("got %+v after stopping", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,168 @@
+package history
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+// The play history is every track hedgehog has played, one JSON object per
+// line, appended as each track ends. It's kept locally, so it covers
+// offline plays and tracks that never made it to a scrobble, and it's plain
+// enough to grep or feed to jq.
+
+import (
+	"bufio"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/control"
+)
+
+const FileName = "history.jsonl"
+
+// Ending this close to the end of a track counts as listening to it all.
+// Anything earlier was skipped.
+const skipTail = 10 * time.Second
+
+// Play is one track, played once. Times are in seconds.
+type Play struct {
+	ID       string    `json:"id"`
+	Title    string    `json:"title"`
+	Artist   string    `json:"artist"`
+	Album    string    `json:"album,omitempty"`
+	Playlist string    `json:"playlist,omitempty"`
+	Started  time.Time `json:"started"`
+	Length   float64   `json:"length"`
+	Listened float64   `json:"listened"`
+	Percent  int       `json:"percent"`
+	Skipped  bool      `json:"skipped"`
+	Starred  bool      `json:"starred"`
+}
+
+type Log struct {
+	path string
+	lock sync.Mutex
+}
+
+// Open finds the history in dir, creating dir if need be
+func Open(dir string) (*Log, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &Log{path: filepath.Join(dir, FileName)}, nil
+}
+
+func (log *Log) Path() string {
+	return log.path
+}
+
+// Add appends a play to the history
+func (log *Log) Add(play Play) error {
+	line, err := json.Marshal(play)
+	if err != nil {
+		return err
+	}
+
+	log.lock.Lock()
+	defer log.lock.Unlock()
+
+	file, err := os.OpenFile(log.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Watch adds a play to the history for every track that ends, until the
+// player exits. Live radio has no end, so it's left out.
+func (log *Log) Watch(hub *control.Hub) {
+	events, stop := hub.Subscribe()
+	go func() {
+		defer stop()
+
+		var started time.Time
+		for event := range events {
+			switch event.Name {
+			case control.TrackStart:
+				// Radio title changes are TrackStarts too, which don't matter
+				// since radio isn't kept
+				started = time.Now()
+			case control.TrackEnd:
+				if event.State.Live || event.State.ID == "" {
+					continue
+				}
+				if err := log.Add(played(event.State, started)); err != nil {
+					slog.Warn("saving play history failed", "song", event.State.ID, "err", err)
+				}
+			case control.Exit:
+				return
+			}
+		}
+	}()
+}
+
+// played describes a track that just ended
+func played(state control.State, started time.Time) Play {
+	remaining := state.Duration - state.Position
+	return Play{
+		ID:       state.ID,
+		Title:    state.Title,
+		Artist:   state.Artist,
+		Album:    state.Album,
+		Playlist: state.Playlist,
+		Started:  started.Round(time.Second),
+		Length:   state.Duration,
+		Listened: state.Position,
+		Percent:  state.Percent(),
+		Skipped:  state.Duration > 0 && remaining > skipTail.Seconds(),
+		Starred:  state.Starred,
+	}
+}
+
+// Read returns the plays that started in [from, until). A zero time leaves
+// that end open. Lines that don't make sense, like one cut short by a
+// crash, are skipped.
+func Read(path string, from time.Time, until time.Time) ([]Play, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var (
+		plays []Play
+		bad   int
+	)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var play Play
+		if err := json.Unmarshal(scanner.Bytes(), &play); err != nil {
+			bad++
+			continue
+		}
+		if !from.IsZero() && play.Started.Before(from) {
+			continue
+		}
+		if !until.IsZero() && !play.Started.Before(until) {
+			continue
+		}
+		plays = append(plays, play)
+	}
+	if bad > 0 {
+		slog.Warn("skipped broken lines in the play history", "path", path, "lines", bad)
+	}
+	return plays, scanner.Err()
+}
@@ -0,0 +1,107 @@
+package history
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/control"
+)
+
+func TestPlayedSkips(t *testing.T) {
+	tests := []struct {
+		name     string
+		position float64
+		duration float64
+		skipped  bool
+	}{
+		{"played to the end", 240, 240, false},
+		{"stopped in the last few seconds", 235, 240, false},
+		{"stopped right at the tail", 230, 240, false},
+		{"stopped just before the tail", 229.5, 240, true},
+		{"stopped halfway", 120, 240, true},
+		{"skipped straight away", 0, 240, true},
+		{"short track, played", 5, 8, false},
+		{"no length known", 30, 0, false},
+	}
+
+	started := time.Date(2024, time.May, 15, 7, 0, 0, 400_000_000, time.UTC)
+	for _, test := range tests {
+		state := control.State{ID: "s1", Title: "One", Artist: "Alpha", Position: test.position, Duration: test.duration}
+		play := played(state, started)
+		if play.Skipped != test.skipped {
+			t.Errorf("%s: skipped is %v, want %v", test.name, play.Skipped, test.skipped)
+		}
+		if play.Listened != test.position || play.Length != test.duration {
+			t.Errorf("%s: listened %v of %v", test.name, play.Listened, play.Length)
+		}
+		if !play.Started.Equal(started.Round(time.Second)) {
+			t.Errorf("%s: started %s", test.name, play.Started)
+		}
+	}
+}
+
+func TestRead(t *testing.T) {
+	log, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	day := func(n int) time.Time {
+		return time.Date(2024, time.May, n, 12, 0, 0, 0, time.UTC)
+	}
+	var plays []Play
+	for n := 1; n <= 4; n++ {
+		play := Play{ID: "s1", Title: "One", Artist: "Alpha", Started: day(n), Listened: 100}
+		plays = append(plays, play)
+		if err := log.Add(play); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A line cut short by a crash, and a blank one
+	file, err := os.OpenFile(log.Path(), os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"id":"s2","title":"Tw` + "\n\n")
+	file.Close()
+
+	tests := []struct {
+		name        string
+		from, until time.Time
+		want        []Play
+	}{
+		{"everything", time.Time{}, time.Time{}, plays},
+		{"from", day(3), time.Time{}, plays[2:]},
+		{"until", time.Time{}, day(3), plays[:2]},
+		{"between", day(2), day(4), plays[1:3]},
+		{"nothing", day(5), time.Time{}, nil},
+	}
+	for _, test := range tests {
+		got, err := Read(log.Path(), test.from, test.until)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if len(got) != len(test.want) {
+			t.Fatalf("%s: got %d plays, want %d", test.name, len(got), len(test.want))
+		}
+		for idx := range got {
+			if !got[idx].Started.Equal(test.want[idx].Started) {
+				t.Errorf("%s: play %d started %s, want %s", test.name, idx, got[idx].Started, test.want[idx].Started)
+			}
+			got[idx].Started = test.want[idx].Started
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+
+	if got, err := Read(log.Path()+".missing", time.Time{}, time.Time{}); err != nil || got != nil {
+		t.Errorf("missing history is %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,153 @@
+package history
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"sort"
+	"time"
+)
+
+// Periods that plays can be grouped by
+const (
+	Day   = "day"
+	Week  = "week"
+	Month = "month"
+)
+
+// Tally counts the plays of something, like an artist. Listened is in
+// seconds.
+type Tally struct {
+	Name     string  `json:"name"`
+	Plays    int     `json:"plays"`
+	Skips    int     `json:"skips"`
+	Listened float64 `json:"listened"`
+}
+
+func (tally *Tally) add(play Play) {
+	tally.Plays++
+	if play.Skipped {
+		tally.Skips++
+	}
+	tally.Listened += play.Listened
+}
+
+// SkipRate is the share of plays that were skipped, from 0 to 1
+func (tally Tally) SkipRate() float64 {
+	if tally.Plays == 0 {
+		return 0
+	}
+	return float64(tally.Skips) / float64(tally.Plays)
+}
+
+// Summary is what a stretch of history adds up to
+type Summary struct {
+	First time.Time `json:"first"`
+	Last  time.Time `json:"last"`
+	Total Tally     `json:"total"`
+
+	// The most played of each, most first
+	Artists []Tally `json:"artists"`
+	Albums  []Tally `json:"albums"`
+	Tracks  []Tally `json:"tracks"`
+
+	// Periods breaks the total down by day, week or month, oldest first
+	Periods []Tally `json:"periods,omitempty"`
+}
+
+// Summarize adds up plays, keeping the top of each list. period, if not
+// empty, is one of Day, Week or Month.
+func Summarize(plays []Play, top int, period string) Summary {
+	summary := Summary{Total: Tally{Name: "total"}}
+
+	var (
+		artists = newTallies()
+		albums  = newTallies()
+		tracks  = newTallies()
+		periods = newTallies()
+	)
+	for _, play := range plays {
+		if summary.First.IsZero() || play.Started.Before(summary.First) {
+			summary.First = play.Started
+		}
+		if play.Started.After(summary.Last) {
+			summary.Last = play.Started
+		}
+		summary.Total.add(play)
+
+		artists.add(play.Artist, play.Artist, play)
+		if play.Album != "" {
+			albums.add(play.Artist+"\x00"+play.Album, play.Album+" - "+play.Artist, play)
+		}
+		tracks.add(play.ID, play.Title+" - "+play.Artist, play)
+		if period != "" {
+			key := periodStart(play.Started, period)
+			periods.add(key, key, play)
+		}
+	}
+
+	summary.Artists = artists.top(top)
+	summary.Albums = albums.top(top)
+	summary.Tracks = tracks.top(top)
+	if period != "" {
+		summary.Periods = periods.list()
+		sort.Slice(summary.Periods, func(i, j int) bool {
+			return summary.Periods[i].Name < summary.Periods[j].Name
+		})
+	}
+	return summary
+}
+
+// periodStart names the day, week or month a time falls in, in a way that
+// sorts. Weeks start on Monday.
+func periodStart(when time.Time, period string) string {
+	when = when.Local()
+	switch period {
+	case Month:
+		return when.Format("2006-01")
+	case Week:
+		offset := (int(when.Weekday()) + 6) % 7
+		return when.AddDate(0, 0, -offset).Format("2006-01-02")
+	default:
+		return when.Format("2006-01-02")
+	}
+}
+
+// tallies counts plays by key, keeping the order keys first turned up in
+type tallies struct {
+	index map[string]int
+	all   []Tally
+}
+
+func newTallies() *tallies {
+	return &tallies{index: make(map[string]int)}
+}
+
+func (t *tallies) add(key string, name string, play Play) {
+	idx, ok := t.index[key]
+	if !ok {
+		idx = len(t.all)
+		t.index[key] = idx
+		t.all = append(t.all, Tally{Name: name})
+	}
+	t.all[idx].add(play)
+}
+
+func (t *tallies) list() []Tally {
+	return t.all
+}
+
+// top is the n most played, breaking ties by time listened
+func (t *tallies) top(n int) []Tally {
+	sorted := append([]Tally(nil), t.all...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Plays != sorted[j].Plays {
+			return sorted[i].Plays > sorted[j].Plays
+		}
+		return sorted[i].Listened > sorted[j].Listened
+	})
+	if n > 0 && len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
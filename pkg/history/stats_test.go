@@ -0,0 +1,212 @@
+package history
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+// inNewYork runs the test with local time set to New York, whose clocks
+// change on 2024-03-10 and 2024-11-03
+func inNewYork(t *testing.T) *time.Location {
+	t.Helper()
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := time.Local
+	time.Local = newYork
+	t.Cleanup(func() { time.Local = local })
+	return newYork
+}
+
+func TestPeriodStart(t *testing.T) {
+	inNewYork(t)
+
+	tests := []struct {
+		when             string
+		day, week, month string
+	}{
+		// 2024-05-13 is a Monday
+		{"2024-05-13T00:00:00-04:00", "2024-05-13", "2024-05-13", "2024-05"},
+		{"2024-05-15T12:00:00-04:00", "2024-05-15", "2024-05-13", "2024-05"},
+		{"2024-05-19T23:59:59-04:00", "2024-05-19", "2024-05-13", "2024-05"},
+		{"2024-05-20T00:00:00-04:00", "2024-05-20", "2024-05-20", "2024-05"},
+
+		// Weeks run across months and years
+		{"2024-06-02T08:00:00-04:00", "2024-06-02", "2024-05-27", "2024-06"},
+		{"2025-01-01T08:00:00-05:00", "2025-01-01", "2024-12-30", "2025-01"},
+
+		// Local time decides, not UTC
+		{"2024-05-20T02:00:00Z", "2024-05-19", "2024-05-13", "2024-05"},
+		{"2024-04-01T02:00:00Z", "2024-03-31", "2024-03-25", "2024-03"},
+
+		// Around the clocks changing
+		{"2024-03-10T01:59:59-05:00", "2024-03-10", "2024-03-04", "2024-03"},
+		{"2024-03-10T03:00:00-04:00", "2024-03-10", "2024-03-04", "2024-03"},
+		{"2024-03-11T00:30:00-04:00", "2024-03-11", "2024-03-11", "2024-03"},
+		{"2024-11-03T01:30:00-04:00", "2024-11-03", "2024-10-28", "2024-11"},
+		{"2024-11-03T01:30:00-05:00", "2024-11-03", "2024-10-28", "2024-11"},
+		{"2024-11-03T23:30:00-05:00", "2024-11-03", "2024-10-28", "2024-11"},
+		{"2024-11-04T00:00:00-05:00", "2024-11-04", "2024-11-04", "2024-11"},
+	}
+
+	for _, test := range tests {
+		when, err := time.Parse(time.RFC3339, test.when)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for period, want := range map[string]string{Day: test.day, Week: test.week, Month: test.month} {
+			if got := periodStart(when, period); got != want {
+				t.Errorf("%s %s is %s, want %s", test.when, period, got, want)
+			}
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	newYork := inNewYork(t)
+	at := func(day int, hour int) time.Time {
+		return time.Date(2024, time.May, day, hour, 0, 0, 0, newYork)
+	}
+
+	// 2024-05-13 is a Monday
+	plays := []Play{
+		{ID: "1", Title: "One", Artist: "Alpha", Album: "First", Started: at(14, 9), Listened: 200},
+		{ID: "2", Title: "Two", Artist: "Beta", Album: "Second", Started: at(12, 22), Listened: 30, Skipped: true},
+		{ID: "1", Title: "One", Artist: "Alpha", Album: "First", Started: at(20, 8), Listened: 200},
+		{ID: "3", Title: "Three", Artist: "Beta", Started: at(13, 7), Listened: 100},
+		{ID: "4", Title: "Four", Artist: "Alpha", Album: "First", Started: at(13, 8), Listened: 20, Skipped: true},
+		// Same album name, different artist
+		{ID: "5", Title: "Five", Artist: "Gamma", Album: "First", Started: at(19, 23), Listened: 300},
+	}
+
+	tests := []struct {
+		name    string
+		top     int
+		period  string
+		artists []Tally
+		albums  []Tally
+		tracks  []Tally
+		periods []Tally
+	}{
+		{
+			name: "everything",
+			artists: []Tally{
+				{Name: "Alpha", Plays: 3, Skips: 1, Listened: 420},
+				{Name: "Beta", Plays: 2, Skips: 1, Listened: 130},
+				{Name: "Gamma", Plays: 1, Listened: 300},
+			},
+			albums: []Tally{
+				{Name: "First - Alpha", Plays: 3, Skips: 1, Listened: 420},
+				{Name: "First - Gamma", Plays: 1, Listened: 300},
+				{Name: "Second - Beta", Plays: 1, Skips: 1, Listened: 30},
+			},
+			tracks: []Tally{
+				{Name: "One - Alpha", Plays: 2, Listened: 400},
+				{Name: "Five - Gamma", Plays: 1, Listened: 300},
+				{Name: "Three - Beta", Plays: 1, Listened: 100},
+				{Name: "Two - Beta", Plays: 1, Skips: 1, Listened: 30},
+				{Name: "Four - Alpha", Plays: 1, Skips: 1, Listened: 20},
+			},
+		},
+		{
+			name:    "top two, ties broken by time listened",
+			top:     2,
+			artists: []Tally{{Name: "Alpha", Plays: 3, Skips: 1, Listened: 420}, {Name: "Beta", Plays: 2, Skips: 1, Listened: 130}},
+			albums:  []Tally{{Name: "First - Alpha", Plays: 3, Skips: 1, Listened: 420}, {Name: "First - Gamma", Plays: 1, Listened: 300}},
+			tracks:  []Tally{{Name: "One - Alpha", Plays: 2, Listened: 400}, {Name: "Five - Gamma", Plays: 1, Listened: 300}},
+		},
+		{
+			name:    "by day",
+			top:     1,
+			period:  Day,
+			artists: []Tally{{Name: "Alpha", Plays: 3, Skips: 1, Listened: 420}},
+			albums:  []Tally{{Name: "First - Alpha", Plays: 3, Skips: 1, Listened: 420}},
+			tracks:  []Tally{{Name: "One - Alpha", Plays: 2, Listened: 400}},
+			periods: []Tally{
+				{Name: "2024-05-12", Plays: 1, Skips: 1, Listened: 30},
+				{Name: "2024-05-13", Plays: 2, Skips: 1, Listened: 120},
+				{Name: "2024-05-14", Plays: 1, Listened: 200},
+				{Name: "2024-05-19", Plays: 1, Listened: 300},
+				{Name: "2024-05-20", Plays: 1, Listened: 200},
+			},
+		},
+		{
+			name:    "by week, starting on monday",
+			top:     1,
+			period:  Week,
+			artists: []Tally{{Name: "Alpha", Plays: 3, Skips: 1, Listened: 420}},
+			albums:  []Tally{{Name: "First - Alpha", Plays: 3, Skips: 1, Listened: 420}},
+			tracks:  []Tally{{Name: "One - Alpha", Plays: 2, Listened: 400}},
+			periods: []Tally{
+				{Name: "2024-05-06", Plays: 1, Skips: 1, Listened: 30},
+				{Name: "2024-05-13", Plays: 4, Skips: 1, Listened: 620},
+				{Name: "2024-05-20", Plays: 1, Listened: 200},
+			},
+		},
+		{
+			name:    "by month",
+			top:     1,
+			period:  Month,
+			artists: []Tally{{Name: "Alpha", Plays: 3, Skips: 1, Listened: 420}},
+			albums:  []Tally{{Name: "First - Alpha", Plays: 3, Skips: 1, Listened: 420}},
+			tracks:  []Tally{{Name: "One - Alpha", Plays: 2, Listened: 400}},
+			periods: []Tally{{Name: "2024-05", Plays: 6, Skips: 2, Listened: 850}},
+		},
+	}
+
+	for _, test := range tests {
+		summary := Summarize(plays, test.top, test.period)
+
+		want := Tally{Name: "total", Plays: 6, Skips: 2, Listened: 850}
+		if summary.Total != want {
+			t.Errorf("%s: total is %+v, want %+v", test.name, summary.Total, want)
+		}
+		if !summary.First.Equal(at(12, 22)) || !summary.Last.Equal(at(20, 8)) {
+			t.Errorf("%s: runs from %s to %s", test.name, summary.First, summary.Last)
+		}
+
+		for what, lists := range map[string][2][]Tally{
+			"artists": {summary.Artists, test.artists},
+			"albums":  {summary.Albums, test.albums},
+			"tracks":  {summary.Tracks, test.tracks},
+			"periods": {summary.Periods, test.periods},
+		} {
+			if !reflect.DeepEqual(lists[0], lists[1]) {
+				t.Errorf("%s: %s are %+v, want %+v", test.name, what, lists[0], lists[1])
+			}
+		}
+	}
+}
+
+func TestSummarizeNothing(t *testing.T) {
+	summary := Summarize(nil, 10, Week)
+	if summary.Total.Plays != 0 || !summary.First.IsZero() || !summary.Last.IsZero() {
+		t.Errorf("got %+v", summary)
+	}
+	if len(summary.Artists) != 0 || len(summary.Periods) != 0 {
+		t.Errorf("got %+v", summary)
+	}
+}
+
+func TestSkipRate(t *testing.T) {
+	tests := []struct {
+		tally Tally
+		want  float64
+	}{
+		{Tally{}, 0},
+		{Tally{Plays: 4}, 0},
+		{Tally{Plays: 4, Skips: 1}, 0.25},
+		{Tally{Plays: 2, Skips: 2}, 1},
+	}
+	for _, test := range tests {
+		if got := test.tally.SkipRate(); got != test.want {
+			t.Errorf("%+v skip rate is %v, want %v", test.tally, got, test.want)
+		}
+	}
+}
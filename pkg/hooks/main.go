@@ -0,0 +1,169 @@
+package hooks
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+// Hooks are shell commands run when something happens in the player, like
+// a track starting. Each gets the details in HEDGEHOG_* environment
+// variables and as JSON on stdin, so a one liner can use the former and a
+// script the latter. Hooks run in the background and are killed if they
+// take too long. What they print goes to the log.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/control"
+)
+
+const DefaultTimeout = 10 * time.Second
+
+// Events lists everything a hook can be run for
+var Events = []string{
+	control.TrackStart,
+	control.TrackEnd,
+	control.Scrobble,
+	control.Star,
+	control.Pause,
+	control.Exit,
+}
+
+type Hooks struct {
+	// Commands are the shell commands to run, by event name
+	Commands map[string][]string
+	Timeout  time.Duration
+
+	running sync.WaitGroup
+}
+
+// New checks that every event is one we know about
+func New(commands map[string][]string, timeout time.Duration) (*Hooks, error) {
+	for event := range commands {
+		known := false
+		for _, name := range Events {
+			known = known || name == event
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown hook event '%s' (have: %s)", event, strings.Join(Events, ", "))
+		}
+	}
+
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Hooks{Commands: commands, Timeout: timeout}, nil
+}
+
+// Watch runs hooks for everything published on hub until the player
+// exits. The returned func waits for the last of them to finish.
+func (hooks *Hooks) Watch(hub *control.Hub) func() {
+	events, stop := hub.Subscribe()
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		defer stop()
+		for event := range events {
+			hooks.Run(event)
+			if event.Name == control.Exit {
+				return
+			}
+		}
+	}()
+
+	return func() {
+		select {
+		case <-done:
+		case <-time.After(hooks.Timeout):
+		}
+		hooks.running.Wait()
+	}
+}
+
+// Run starts the hooks for an event in the background
+func (hooks *Hooks) Run(event control.Event) {
+	commands := hooks.Commands[event.Name]
+	if len(commands) == 0 {
+		return
+	}
+
+	stdin, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("encoding hook event failed", "event", event.Name, "err", err)
+		return
+	}
+	env := append(os.Environ(), Environment(event)...)
+
+	for _, command := range commands {
+		hooks.running.Add(1)
+		go func(command string) {
+			defer hooks.running.Done()
+			hooks.run(event.Name, command, env, stdin)
+		}(command)
+	}
+}
+
+func (hooks *Hooks) run(event string, command string, env []string, stdin []byte) {
+	ctx, cancel := context.WithTimeout(context.Background(), hooks.Timeout)
+	defer cancel()
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = env
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// The shell might leave children holding the output open after it's
+	// killed, so don't wait on them for long
+	cmd.WaitDelay = time.Second
+
+	started := time.Now()
+	err := cmd.Run()
+	took := time.Since(started).Round(time.Millisecond)
+
+	out := strings.TrimSpace(output.String())
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		slog.Warn("hook timed out", "event", event, "command", command, "timeout", hooks.Timeout, "output", out)
+	case err != nil:
+		slog.Warn("hook failed", "event", event, "command", command, "err", err, "output", out)
+	default:
+		slog.Debug("hook ran", "event", event, "command", command, "took", took, "output", out)
+	}
+}
+
+// Environment describes an event as HEDGEHOG_* variables
+func Environment(event control.Event) []string {
+	state := event.State
+	vars := map[string]string{
+		"EVENT":    event.Name,
+		"STATUS":   state.Status,
+		"ID":       state.ID,
+		"TITLE":    state.Title,
+		"ARTIST":   state.Artist,
+		"ALBUM":    state.Album,
+		"YEAR":     strconv.Itoa(state.Year),
+		"STARRED":  strconv.FormatBool(state.Starred),
+		"LIVE":     strconv.FormatBool(state.Live),
+		"POSITION": strconv.Itoa(int(state.Position)),
+		"DURATION": strconv.Itoa(int(state.Duration)),
+		"PLAYLIST": state.Playlist,
+	}
+
+	env := make([]string, 0, len(vars))
+	for key, value := range vars {
+		env = append(env, fmt.Sprintf("HEDGEHOG_%s=%s", key, value))
+	}
+	sort.Strings(env)
+	return env
+}
@@ -0,0 +1,364 @@
+package keymap
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eiannone/keyboard"
+)
+
+type Action string
+
+// sequenceTimeout is how long a half typed sequence waits for its next key
+var sequenceTimeout = time.Second
+
+const (
+	Quit     Action = "quit"
+	Mute     Action = "mute"
+	Previous Action = "previous"
+	Next     Action = "next"
+	Star     Action = "star"
+	Reload   Action = "reload"
+	Pause    Action = "pause"
+	Lyrics   Action = "lyrics"
+	Similar  Action = "similar"
+	Sleep    Action = "sleep"
+	Wake     Action = "wake"
+	Stop     Action = "stop"
+	Snooze   Action = "snooze"
+)
+
+type registration struct {
+	action   Action
+	label    string
+	defaults []string
+}
+
+// registry lists every action in the order it appears in the help line,
+// along with its default keys
+var registry = []registration{
+	{Quit, "quit", []string{"q", "ctrl-c", "esc"}},
+	{Mute, "mute", []string{"m"}},
+	{Previous, "back", []string{"p", "<"}},
+	{Next, "next", []string{"n", ">"}},
+	{Star, "star/unstar", []string{"*"}},
+	{Reload, "update playlist", []string{"r"}},
+	{Pause, "pause/unpause", []string{"space"}},
+	{Lyrics, "lyrics", []string{"l"}},
+	{Similar, "play similar", []string{"s"}},
+	{Sleep, "sleep +15m", []string{"z"}},
+	{Wake, "no sleep", []string{"Z"}},
+	{Stop, "stop after this", []string{"x"}},
+	{Snooze, "snooze", []string{"enter"}},
+}
+
+// Actions lists the names of every known action
+func Actions() []Action {
+	actions := make([]Action, len(registry))
+	for idx, reg := range registry {
+		actions[idx] = reg.action
+	}
+	return actions
+}
+
+func lookup(action Action) (registration, bool) {
+	for _, reg := range registry {
+		if reg.action == action {
+			return reg, true
+		}
+	}
+	return registration{}, false
+}
+
+type (
+	Binding struct {
+		Keys   Sequence
+		Action Action
+	}
+
+	Keymap struct {
+		bindings []Binding
+		pending  Sequence
+		// lastKey is when the last key in pending was pressed
+		lastKey time.Time
+	}
+)
+
+// New builds a keymap from the defaults, replacing the keys for any action
+// named in overrides. Each override is a list of key sequences, and each
+// sequence is a space separated list of keys, like "g g" or "ctrl-x n".
+// An action overridden with an empty list is unbound.
+func New(overrides map[string][]string) (*Keymap, error) {
+	for name := range overrides {
+		if _, ok := lookup(Action(name)); !ok {
+			return nil, fmt.Errorf("unknown action '%s' (have: %v)", name, Actions())
+		}
+	}
+
+	keymap := Keymap{}
+	for _, reg := range registry {
+		sequences, ok := overrides[string(reg.action)]
+		if !ok {
+			sequences = reg.defaults
+		}
+
+		for _, raw := range sequences {
+			seq, err := ParseSequence(raw)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", reg.action, err)
+			}
+			if err := keymap.bind(seq, reg.action); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &keymap, nil
+}
+
+func (keymap *Keymap) bind(seq Sequence, action Action) error {
+	for _, existing := range keymap.bindings {
+		if existing.Keys.Equal(seq) {
+			return fmt.Errorf("'%s' is bound to both %s and %s", seq, existing.Action, action)
+		}
+		if existing.Keys.HasPrefix(seq) || seq.HasPrefix(existing.Keys) {
+			return fmt.Errorf("'%s' (%s) and '%s' (%s) overlap", seq, action, existing.Keys, existing.Action)
+		}
+	}
+
+	keymap.bindings = append(keymap.bindings, Binding{Keys: seq, Action: action})
+	return nil
+}
+
+// Unbind drops every key bound to an action, for actions that don't make
+// sense right now
+func (keymap *Keymap) Unbind(action Action) {
+	kept := keymap.bindings[:0]
+	for _, binding := range keymap.bindings {
+		if binding.Action != action {
+			kept = append(kept, binding)
+		}
+	}
+	keymap.bindings = kept
+}
+
+// Feed hands the keymap the next key press. Once the keys pressed so far
+// complete a binding, its action is returned. Keys that can't lead to any
+// binding are dropped, as is a sequence left unfinished for longer than
+// sequenceTimeout.
+func (keymap *Keymap) Feed(key Key) (Action, bool) {
+	now := time.Now()
+	if now.Sub(keymap.lastKey) > sequenceTimeout {
+		keymap.pending = nil
+	}
+	keymap.lastKey = now
+	keymap.pending = append(keymap.pending, key.normalize())
+
+	for {
+		partial := false
+		for _, binding := range keymap.bindings {
+			if binding.Keys.Equal(keymap.pending) {
+				keymap.pending = nil
+				return binding.Action, true
+			}
+			if binding.Keys.HasPrefix(keymap.pending) {
+				partial = true
+			}
+		}
+
+		if partial {
+			return "", false
+		}
+		if len(keymap.pending) <= 1 {
+			keymap.pending = nil
+			return "", false
+		}
+		// The sequence went nowhere, but the latest key might start a new one
+		keymap.pending = keymap.pending[len(keymap.pending)-1:]
+	}
+}
+
+// Keys returns the sequences bound to an action
+func (keymap *Keymap) Keys(action Action) []Sequence {
+	keys := make([]Sequence, 0)
+	for _, binding := range keymap.bindings {
+		if binding.Action == action {
+			keys = append(keys, binding.Keys)
+		}
+	}
+	return keys
+}
+
+// Help renders the active bindings for display under the player
+func (keymap *Keymap) Help() string {
+	parts := make([]string, 0)
+	for _, reg := range registry {
+		keys := keymap.Keys(reg.action)
+		if len(keys) == 0 {
+			continue
+		}
+
+		names := make([]string, len(keys))
+		for idx, seq := range keys {
+			names[idx] = seq.String()
+		}
+		parts = append(parts, fmt.Sprintf("%s: %s", strings.Join(names, "/"), reg.label))
+	}
+
+	return fmt.Sprintf("[ %s ]", strings.Join(parts, " | "))
+}
+
+type (
+	// Key is a single key press as reported by eiannone/keyboard. Printable
+	// keys set Char, everything else sets Code.
+	Key struct {
+		Char rune
+		Code keyboard.Key
+	}
+
+	Sequence []Key
+)
+
+var named = map[string]keyboard.Key{
+	"space":     keyboard.KeySpace,
+	"esc":       keyboard.KeyEsc,
+	"enter":     keyboard.KeyEnter,
+	"tab":       keyboard.KeyTab,
+	"backspace": keyboard.KeyBackspace2,
+	"up":        keyboard.KeyArrowUp,
+	"down":      keyboard.KeyArrowDown,
+	"left":      keyboard.KeyArrowLeft,
+	"right":     keyboard.KeyArrowRight,
+	"home":      keyboard.KeyHome,
+	"end":       keyboard.KeyEnd,
+	"pgup":      keyboard.KeyPgup,
+	"pgdn":      keyboard.KeyPgdn,
+	"insert":    keyboard.KeyInsert,
+	"delete":    keyboard.KeyDelete,
+}
+
+var aliases = map[string]string{
+	"escape":   "esc",
+	"return":   "enter",
+	"pageup":   "pgup",
+	"pagedown": "pgdn",
+	"del":      "delete",
+	"ins":      "insert",
+}
+
+// ParseKey understands single characters, the names in the named table
+// above, f1 through f12 and ctrl-a through ctrl-z
+func ParseKey(raw string) (Key, error) {
+	if runes := []rune(raw); len(runes) == 1 {
+		return Key{Char: runes[0]}.normalize(), nil
+	}
+
+	name := strings.ToLower(raw)
+	if alias, ok := aliases[name]; ok {
+		name = alias
+	}
+
+	if code, ok := named[name]; ok {
+		return Key{Code: code}, nil
+	}
+
+	var num int
+	if _, err := fmt.Sscanf(name, "f%d", &num); err == nil && fmt.Sprintf("f%d", num) == name {
+		if num < 1 || num > 12 {
+			return Key{}, fmt.Errorf("no such function key '%s'", raw)
+		}
+		return Key{Code: keyboard.KeyF1 - keyboard.Key(num-1)}, nil
+	}
+
+	if letter, ok := strings.CutPrefix(name, "ctrl-"); ok && len(letter) == 1 && letter[0] >= 'a' && letter[0] <= 'z' {
+		return Key{Code: keyboard.KeyCtrlA + keyboard.Key(letter[0]-'a')}.normalize(), nil
+	}
+
+	return Key{}, fmt.Errorf("unknown key '%s'", raw)
+}
+
+func ParseSequence(raw string) (Sequence, error) {
+	fields := strings.Fields(raw)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty key binding")
+	}
+
+	seq := make(Sequence, len(fields))
+	for idx, field := range fields {
+		key, err := ParseKey(field)
+		if err != nil {
+			return nil, err
+		}
+		seq[idx] = key
+	}
+	return seq, nil
+}
+
+// normalize folds the different ways a terminal can report the same key
+// into one
+func (key Key) normalize() Key {
+	switch {
+	case key.Char == ' ':
+		return Key{Code: keyboard.KeySpace}
+	case key.Char != 0:
+		return Key{Char: key.Char}
+	case key.Code == keyboard.KeyBackspace:
+		return Key{Code: keyboard.KeyBackspace2}
+	}
+	return key
+}
+
+func (key Key) String() string {
+	if key.Char != 0 {
+		return string(key.Char)
+	}
+
+	names := make([]string, 0, len(named))
+	for name := range named {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if named[name] == key.Code {
+			return name
+		}
+	}
+
+	if key.Code <= keyboard.KeyF1 && key.Code >= keyboard.KeyF12 {
+		return fmt.Sprintf("f%d", keyboard.KeyF1-key.Code+1)
+	}
+	if key.Code >= keyboard.KeyCtrlA && key.Code <= keyboard.KeyCtrlZ {
+		return fmt.Sprintf("ctrl-%c", 'a'+rune(key.Code-keyboard.KeyCtrlA))
+	}
+	return fmt.Sprintf("0x%X", uint16(key.Code))
+}
+
+func (seq Sequence) String() string {
+	names := make([]string, len(seq))
+	for idx, key := range seq {
+		names[idx] = key.String()
+	}
+	return strings.Join(names, " ")
+}
+
+func (seq Sequence) Equal(other Sequence) bool {
+	return len(seq) == len(other) && seq.HasPrefix(other)
+}
+
+// HasPrefix reports whether prefix is the start of (or all of) seq
+func (seq Sequence) HasPrefix(prefix Sequence) bool {
+	if len(prefix) > len(seq) {
+		return false
+	}
+	for idx := range prefix {
+		if seq[idx] != prefix[idx] {
+			return false
+		}
+	}
+	return true
+}
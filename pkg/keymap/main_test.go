@@ -0,0 +1,225 @@
+package keymap
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eiannone/keyboard"
+)
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want Key
+	}{
+		{"a", Key{Char: 'a'}},
+		{"Z", Key{Char: 'Z'}},
+		{"*", Key{Char: '*'}},
+		{"é", Key{Char: 'é'}},
+		{" ", Key{Code: keyboard.KeySpace}},
+		{"space", Key{Code: keyboard.KeySpace}},
+		{"SPACE", Key{Code: keyboard.KeySpace}},
+		{"esc", Key{Code: keyboard.KeyEsc}},
+		{"Escape", Key{Code: keyboard.KeyEsc}},
+		{"return", Key{Code: keyboard.KeyEnter}},
+		{"backspace", Key{Code: keyboard.KeyBackspace2}},
+		{"pageup", Key{Code: keyboard.KeyPgup}},
+		{"pagedown", Key{Code: keyboard.KeyPgdn}},
+		{"del", Key{Code: keyboard.KeyDelete}},
+		{"ins", Key{Code: keyboard.KeyInsert}},
+		{"f1", Key{Code: keyboard.KeyF1}},
+		{"F12", Key{Code: keyboard.KeyF12}},
+		{"ctrl-x", Key{Code: keyboard.KeyCtrlX}},
+		{"Ctrl-C", Key{Code: keyboard.KeyCtrlC}},
+		// Terminals send ctrl-h for backspace
+		{"ctrl-h", Key{Code: keyboard.KeyBackspace2}},
+	}
+	for _, test := range tests {
+		got, err := ParseKey(test.raw)
+		if err != nil {
+			t.Errorf("%q: %s", test.raw, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%q is %#v, want %#v", test.raw, got, test.want)
+		}
+	}
+
+	for _, raw := range []string{"", "f0", "f13", "f01", "ctrl-", "ctrl-1", "ctrl-ab", "shift-a", "bogus"} {
+		if got, err := ParseKey(raw); err == nil {
+			t.Errorf("%q parsed as %#v", raw, got)
+		}
+	}
+}
+
+// Whatever a key is shown as can be read back as the same key
+func TestKeyString(t *testing.T) {
+	var names []string
+	for name := range named {
+		names = append(names, name)
+	}
+	for alias := range aliases {
+		names = append(names, alias)
+	}
+	for num := 1; num <= 12; num++ {
+		names = append(names, fmt.Sprintf("f%d", num))
+	}
+	for letter := 'a'; letter <= 'z'; letter++ {
+		names = append(names, "ctrl-"+string(letter), string(letter))
+	}
+
+	for _, name := range names {
+		key, err := ParseKey(name)
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		back, err := ParseKey(key.String())
+		if err != nil || back != key {
+			t.Errorf("%s shows as %q, which reads back as %#v (%v)", name, key.String(), back, err)
+		}
+	}
+}
+
+func TestParseSequence(t *testing.T) {
+	seq, err := ParseSequence("  ctrl-x   n ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Sequence{{Code: keyboard.KeyCtrlX}, {Char: 'n'}}
+	if !reflect.DeepEqual(seq, want) {
+		t.Errorf("got %#v, want %#v", seq, want)
+	}
+	if seq.String() != "ctrl-x n" {
+		t.Errorf("shows as %q", seq.String())
+	}
+
+	for _, raw := range []string{"", "   ", "g bogus"} {
+		if got, err := ParseSequence(raw); err == nil {
+			t.Errorf("%q parsed as %#v", raw, got)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides map[string][]string
+		err       string
+	}{
+		{"defaults", nil, ""},
+		{"replaced", map[string][]string{"next": {"right", "g g"}}, ""},
+		{"swapped", map[string][]string{"next": {"p"}, "previous": {"n"}}, ""},
+		{"unknown action", map[string][]string{"dance": {"d"}}, "unknown action 'dance'"},
+		{"bad key", map[string][]string{"next": {"ctrl-1"}}, "next: unknown key"},
+		{"conflict", map[string][]string{"mute": {"q"}}, "'q' is bound to both quit and mute"},
+		{"conflict by alias", map[string][]string{"next": {"escape"}}, "'esc' is bound to both quit and next"},
+		{"same action twice", map[string][]string{"next": {"n", "n"}}, "'n' is bound to both next and next"},
+		{"overlap", map[string][]string{"next": {"g"}, "previous": {"g g"}}, "overlap"},
+		{"overlap with a default", map[string][]string{"next": {"m n"}}, "overlap"},
+	}
+
+	for _, test := range tests {
+		_, err := New(test.overrides)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: %s", test.name, err)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%s: got %v, want %s", test.name, err, test.err)
+		}
+	}
+}
+
+func TestBindings(t *testing.T) {
+	keys, err := New(map[string][]string{
+		"next":   {"right", "g g"},
+		"lyrics": {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, seq := range keys.Keys(Next) {
+		got = append(got, seq.String())
+	}
+	if !reflect.DeepEqual(got, []string{"right", "g g"}) {
+		t.Errorf("next is %v", got)
+	}
+	if len(keys.Keys(Lyrics)) != 0 || strings.Contains(keys.Help(), "lyrics") {
+		t.Errorf("lyrics still bound: %s", keys.Help())
+	}
+	if !strings.Contains(keys.Help(), "right/g g: next") {
+		t.Errorf("help is %s", keys.Help())
+	}
+
+	keys.Unbind(Snooze)
+	if len(keys.Keys(Snooze)) != 0 || strings.Contains(keys.Help(), "snooze") {
+		t.Errorf("snooze still bound: %s", keys.Help())
+	}
+	if len(keys.Keys(Quit)) != 3 {
+		t.Errorf("unbinding snooze took quit with it: %v", keys.Keys(Quit))
+	}
+}
+
+func TestFeed(t *testing.T) {
+	type press struct {
+		key    Key
+		action Action
+	}
+	tests := []struct {
+		name    string
+		presses []press
+	}{
+		{"single", []press{{Key{Char: 'n'}, Next}}},
+		{"space as a char", []press{{Key{Char: ' '}, Pause}}},
+		{"space as a code", []press{{Key{Code: keyboard.KeySpace}, Pause}}},
+		{"old backspace", []press{{Key{Code: keyboard.KeyBackspace}, Previous}}},
+		{"sequence", []press{{Key{Char: 'g'}, ""}, {Key{Char: 'g'}, Next}}},
+		{"unbound", []press{{Key{Char: 'k'}, ""}, {Key{Char: 'n'}, Next}}},
+		{"broken sequence", []press{{Key{Char: 'g'}, ""}, {Key{Char: 'k'}, ""}, {Key{Char: 'g'}, ""}, {Key{Char: 'g'}, Next}}},
+		{"sequence that starts over", []press{{Key{Char: 'g'}, ""}, {Key{Char: 'x'}, Stop}}},
+	}
+
+	for _, test := range tests {
+		keys, err := New(map[string][]string{
+			"next":     {"n", "g g"},
+			"previous": {"backspace"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for idx, press := range test.presses {
+			action, ok := keys.Feed(press.key)
+			if ok != (press.action != "") || action != press.action {
+				t.Errorf("%s: press %d is %q (%v), want %q", test.name, idx, action, ok, press.action)
+			}
+		}
+	}
+}
+
+func TestFeedTimeout(t *testing.T) {
+	sequenceTimeout = 20 * time.Millisecond
+	defer func() { sequenceTimeout = time.Second }()
+
+	keys, err := New(map[string][]string{"next": {"g g"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys.Feed(Key{Char: 'g'})
+	time.Sleep(50 * time.Millisecond)
+	if action, ok := keys.Feed(Key{Char: 'g'}); ok {
+		t.Errorf("a stale g finished %s", action)
+	}
+	if action, ok := keys.Feed(Key{Char: 'g'}); !ok || action != Next {
+		t.Errorf("g g is %q (%v)", action, ok)
+	}
+}
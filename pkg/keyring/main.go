@@ -0,0 +1,203 @@
+package keyring
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+// A minimal client for the freedesktop Secret Service API
+// (https://specifications.freedesktop.org/secret-service/), as provided by
+// gnome-keyring, KeePassXC and friends. Secrets are stored in the default
+// collection and found again by their url and user attributes.
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	serviceName       = "org.freedesktop.secrets"
+	servicePath       = dbus.ObjectPath("/org/freedesktop/secrets")
+	defaultCollection = dbus.ObjectPath("/org/freedesktop/secrets/aliases/default")
+
+	serviceInterface    = "org.freedesktop.Secret.Service"
+	collectionInterface = "org.freedesktop.Secret.Collection"
+	itemInterface       = "org.freedesktop.Secret.Item"
+	promptInterface     = "org.freedesktop.Secret.Prompt"
+
+	application = "hedgehog"
+)
+
+var ErrNotFound = errors.New("no password in the keyring")
+
+type (
+	Keyring struct {
+		conn    *dbus.Conn
+		session dbus.ObjectPath
+	}
+
+	// secret mirrors the Secret Service's (oayays) struct
+	secret struct {
+		Session     dbus.ObjectPath
+		Parameters  []byte
+		Value       []byte
+		ContentType string
+	}
+)
+
+// Open connects to the session bus and opens an unencrypted session with
+// the secret service. The bus is local to the user, which is the same trust
+// boundary every other Secret Service client relies on.
+func Open() (*Keyring, error) {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		output  dbus.Variant
+		session dbus.ObjectPath
+	)
+	err = conn.Object(serviceName, servicePath).
+		Call(serviceInterface+".OpenSession", 0, "plain", dbus.MakeVariant("")).
+		Store(&output, &session)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open a secret service session: %w", err)
+	}
+
+	return &Keyring{conn: conn, session: session}, nil
+}
+
+func (ring *Keyring) Close() {
+	ring.conn.Object(serviceName, ring.session).Call("org.freedesktop.Secret.Session.Close", 0)
+}
+
+func attributes(url string, user string) map[string]string {
+	return map[string]string{
+		"application": application,
+		"url":         url,
+		"user":        user,
+	}
+}
+
+// Get returns the stored password for the user on the given server
+func (ring *Keyring) Get(url string, user string) (string, error) {
+	var unlocked, locked []dbus.ObjectPath
+
+	err := ring.conn.Object(serviceName, servicePath).
+		Call(serviceInterface+".SearchItems", 0, attributes(url, user)).
+		Store(&unlocked, &locked)
+	if err != nil {
+		return "", err
+	}
+
+	if len(unlocked) == 0 && len(locked) > 0 {
+		if err := ring.unlock(locked); err != nil {
+			return "", err
+		}
+		unlocked = locked
+	}
+	if len(unlocked) == 0 {
+		return "", ErrNotFound
+	}
+
+	var found secret
+	err = ring.conn.Object(serviceName, unlocked[0]).
+		Call(itemInterface+".GetSecret", 0, ring.session).
+		Store(&found)
+	if err != nil {
+		return "", err
+	}
+
+	return string(found.Value), nil
+}
+
+// Set stores the password for the user on the given server, replacing any
+// password stored there before
+func (ring *Keyring) Set(url string, user string, password string) error {
+	if err := ring.unlock([]dbus.ObjectPath{defaultCollection}); err != nil {
+		return err
+	}
+
+	properties := map[string]dbus.Variant{
+		itemInterface + ".Label":      dbus.MakeVariant(fmt.Sprintf("%s: %s at %s", application, user, url)),
+		itemInterface + ".Attributes": dbus.MakeVariant(attributes(url, user)),
+	}
+	value := secret{
+		Session:     ring.session,
+		Parameters:  []byte{},
+		Value:       []byte(password),
+		ContentType: "text/plain",
+	}
+
+	var item, prompt dbus.ObjectPath
+	err := ring.conn.Object(serviceName, defaultCollection).
+		Call(collectionInterface+".CreateItem", 0, properties, value, true).
+		Store(&item, &prompt)
+	if err != nil {
+		return err
+	}
+
+	return ring.prompt(prompt)
+}
+
+func (ring *Keyring) unlock(objects []dbus.ObjectPath) error {
+	var (
+		unlocked []dbus.ObjectPath
+		prompt   dbus.ObjectPath
+	)
+
+	err := ring.conn.Object(serviceName, servicePath).
+		Call(serviceInterface+".Unlock", 0, objects).
+		Store(&unlocked, &prompt)
+	if err != nil {
+		return err
+	}
+
+	return ring.prompt(prompt)
+}
+
+// prompt shows a secret service prompt (usually a password dialog for the
+// keyring itself) and waits for the user to deal with it. A path of "/"
+// means no prompt was needed.
+func (ring *Keyring) prompt(path dbus.ObjectPath) error {
+	if path == "/" || path == "" {
+		return nil
+	}
+
+	err := ring.conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(path),
+		dbus.WithMatchInterface(promptInterface),
+		dbus.WithMatchMember("Completed"),
+	)
+	if err != nil {
+		return err
+	}
+	defer ring.conn.RemoveMatchSignal(
+		dbus.WithMatchObjectPath(path),
+		dbus.WithMatchInterface(promptInterface),
+		dbus.WithMatchMember("Completed"),
+	)
+
+	signals := make(chan *dbus.Signal, 1)
+	ring.conn.Signal(signals)
+	defer ring.conn.RemoveSignal(signals)
+
+	err = ring.conn.Object(serviceName, path).
+		Call(promptInterface+".Prompt", 0, "").
+		Err
+	if err != nil {
+		return err
+	}
+
+	for signal := range signals {
+		if signal.Path != path || len(signal.Body) == 0 {
+			continue
+		}
+		if dismissed, ok := signal.Body[0].(bool); ok && dismissed {
+			return errors.New("keyring prompt dismissed")
+		}
+		return nil
+	}
+	return errors.New("lost connection to the secret service")
+}
@@ -0,0 +1,259 @@
+package library
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+// A library is a directory of songs synced from the server, along with a
+// manifest describing which playlists they belong to, so hedgehog can play
+// without a network connection.
+//
+//	<dir>/manifest.json
+//	<dir>/songs/<song id>.<suffix>
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/sonic"
+)
+
+const (
+	manifestFile = "manifest.json"
+	songsDir     = "songs"
+)
+
+type (
+	Track struct {
+		Song sonic.Song `json:"song"`
+		File string     `json:"file"`
+	}
+
+	Playlist struct {
+		ID       string    `json:"id"`
+		Name     string    `json:"name"`
+		SongIDs  []string  `json:"songs"`
+		SyncedAt time.Time `json:"syncedAt"`
+	}
+
+	// PendingStar is a star or unstar made while offline, waiting to be
+	// sent to the server
+	PendingStar struct {
+		Song sonic.Song `json:"song"`
+		Star bool       `json:"star"`
+	}
+
+	Manifest struct {
+		Playlists    map[string]Playlist `json:"playlists"`
+		Tracks       map[string]Track    `json:"tracks"`
+		Starred      map[string]bool     `json:"starred"`
+		PendingStars []PendingStar       `json:"pendingStars"`
+	}
+
+	Library struct {
+		dir      string
+		manifest Manifest
+		lock     sync.Mutex
+	}
+)
+
+func Open(dir string) (*Library, error) {
+	lib := Library{
+		dir: dir,
+		manifest: Manifest{
+			Playlists: make(map[string]Playlist),
+			Tracks:    make(map[string]Track),
+			Starred:   make(map[string]bool),
+		},
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return &lib, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &lib.manifest); err != nil {
+		return nil, fmt.Errorf("%s: %w", manifestFile, err)
+	}
+	if lib.manifest.Playlists == nil {
+		lib.manifest.Playlists = make(map[string]Playlist)
+	}
+	if lib.manifest.Tracks == nil {
+		lib.manifest.Tracks = make(map[string]Track)
+	}
+	if lib.manifest.Starred == nil {
+		lib.manifest.Starred = make(map[string]bool)
+	}
+
+	return &lib, nil
+}
+
+func (lib *Library) Dir() string {
+	return lib.dir
+}
+
+// save writes the manifest out. Callers hold the lock.
+func (lib *Library) save() error {
+	if err := os.MkdirAll(lib.dir, 0o755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(lib.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(lib.dir, manifestFile+".new")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(lib.dir, manifestFile))
+}
+
+// File returns the absolute path of a synced song, if we have it
+func (lib *Library) File(songID string) (string, bool) {
+	lib.lock.Lock()
+	defer lib.lock.Unlock()
+
+	track, ok := lib.manifest.Tracks[songID]
+	if !ok {
+		return "", false
+	}
+
+	path := filepath.Join(lib.dir, track.File)
+	if _, err := os.Stat(path); err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+// SongForFile finds the song a library file belongs to
+func (lib *Library) SongForFile(path string) (sonic.Song, bool) {
+	lib.lock.Lock()
+	defer lib.lock.Unlock()
+
+	base := filepath.Base(path)
+	for _, track := range lib.manifest.Tracks {
+		if filepath.Base(track.File) == base {
+			return track.Song, true
+		}
+	}
+	return sonic.Song{}, false
+}
+
+// Playlist rebuilds a synced playlist from the manifest
+func (lib *Library) Playlist(name string) (sonic.Playlist, error) {
+	lib.lock.Lock()
+	defer lib.lock.Unlock()
+
+	synced, ok := lib.manifest.Playlists[name]
+	if !ok {
+		return sonic.Playlist{}, fmt.Errorf("playlist '%s' hasn't been synced. Run 'hedgehog sync \"%s\"'", name, name)
+	}
+
+	playlist := sonic.Playlist{
+		ID:    synced.ID,
+		Name:  synced.Name,
+		Songs: make(sonic.Songs, 0, len(synced.SongIDs)),
+	}
+	for _, id := range synced.SongIDs {
+		if track, ok := lib.manifest.Tracks[id]; ok {
+			playlist.Songs = append(playlist.Songs, track.Song)
+			playlist.Duration += track.Song.Duration
+		}
+	}
+	playlist.SongCount = len(playlist.Songs)
+
+	return playlist, nil
+}
+
+func (lib *Library) Playlists() []Playlist {
+	lib.lock.Lock()
+	defer lib.lock.Unlock()
+
+	playlists := make([]Playlist, 0, len(lib.manifest.Playlists))
+	for _, playlist := range lib.manifest.Playlists {
+		playlists = append(playlists, playlist)
+	}
+	return playlists
+}
+
+// Starred is the starred state as of the last sync, with any stars made
+// offline applied on top
+func (lib *Library) Starred() map[string]bool {
+	lib.lock.Lock()
+	defer lib.lock.Unlock()
+
+	starred := make(map[string]bool, len(lib.manifest.Starred))
+	for id, star := range lib.manifest.Starred {
+		starred[id] = star
+	}
+	for _, pending := range lib.manifest.PendingStars {
+		starred[pending.Song.ID] = pending.Star
+	}
+	return starred
+}
+
+// QueueStar records a star or unstar to send once we're back online
+func (lib *Library) QueueStar(song sonic.Song, star bool) error {
+	lib.lock.Lock()
+	defer lib.lock.Unlock()
+
+	lib.manifest.PendingStars = append(lib.manifest.PendingStars, PendingStar{Song: song, Star: star})
+	return lib.save()
+}
+
+// ReplayStars sends any stars made offline to the server, keeping the
+// ones that fail for next time
+func (lib *Library) ReplayStars(client *sonic.Sonic) error {
+	lib.lock.Lock()
+	defer lib.lock.Unlock()
+
+	if len(lib.manifest.PendingStars) == 0 {
+		return nil
+	}
+
+	var (
+		remaining = make([]PendingStar, 0)
+		lastErr   error
+	)
+	for _, pending := range lib.manifest.PendingStars {
+		var err error
+		if pending.Star {
+			err = client.Star(pending.Song)
+		} else {
+			err = client.UnStar(pending.Song)
+		}
+
+		if err != nil {
+			lastErr = err
+			remaining = append(remaining, pending)
+			continue
+		}
+		lib.manifest.Starred[pending.Song.ID] = pending.Star
+	}
+
+	lib.manifest.PendingStars = remaining
+	if err := lib.save(); err != nil {
+		return err
+	}
+	return lastErr
+}
+
+// songFile is where a song lives in the library, relative to its root
+func songFile(song sonic.Song) string {
+	id := strings.NewReplacer("/", "_", "\\", "_", "..", "_").Replace(song.ID)
+	suffix := song.Suffix
+	if suffix == "" {
+		suffix = "bin"
+	}
+	return filepath.Join(songsDir, fmt.Sprintf("%s.%s", id, suffix))
+}
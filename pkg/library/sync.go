@@ -0,0 +1,117 @@
+package library
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/sonic"
+)
+
+// Progress is told about each song as the sync reaches it. Skipped is true
+// when the song was already in the library.
+type Progress func(done int, total int, song sonic.Song, skipped bool)
+
+// Sync downloads every song in the playlist that the library doesn't have
+// yet, records the playlist in the manifest, and prunes songs no synced
+// playlist refers to anymore. The manifest is saved after every song so an
+// interrupted sync keeps what it got. A song that fails to download is
+// logged and left out of the playlist until a later sync gets it, and the
+// number of those is returned.
+func (lib *Library) Sync(client *sonic.Sonic, playlist sonic.Playlist, progress Progress) (int, error) {
+	if err := os.MkdirAll(filepath.Join(lib.dir, songsDir), 0o755); err != nil {
+		return 0, err
+	}
+
+	starred, err := client.GetStarred()
+	if err == nil {
+		lib.lock.Lock()
+		lib.manifest.Starred = starred
+		lib.lock.Unlock()
+	}
+
+	ids := make([]string, 0, len(playlist.Songs))
+	failed := 0
+	for idx, song := range playlist.Songs {
+		if _, ok := lib.File(song.ID); ok {
+			ids = append(ids, song.ID)
+			lib.lock.Lock()
+			track := lib.manifest.Tracks[song.ID]
+			track.Song = song
+			lib.manifest.Tracks[song.ID] = track
+			lib.lock.Unlock()
+
+			if progress != nil {
+				progress(idx+1, len(playlist.Songs), song, true)
+			}
+			continue
+		}
+
+		if err := lib.download(client, song); err != nil {
+			slog.Warn("syncing a song failed", "playlist", playlist.Name, "song", song.ID, "title", song.Title, "err", err)
+			failed++
+		} else {
+			ids = append(ids, song.ID)
+		}
+		if progress != nil {
+			progress(idx+1, len(playlist.Songs), song, false)
+		}
+	}
+
+	lib.lock.Lock()
+	defer lib.lock.Unlock()
+
+	lib.manifest.Playlists[playlist.Name] = Playlist{
+		ID:       playlist.ID,
+		Name:     playlist.Name,
+		SongIDs:  ids,
+		SyncedAt: time.Now(),
+	}
+	lib.prune()
+	return failed, lib.save()
+}
+
+func (lib *Library) download(client *sonic.Sonic, song sonic.Song) error {
+	data, err := client.DownloadSong(song)
+	if err != nil {
+		return err
+	}
+
+	file := songFile(song)
+	path := filepath.Join(lib.dir, file)
+	tmp := path + ".part"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+
+	lib.lock.Lock()
+	defer lib.lock.Unlock()
+	lib.manifest.Tracks[song.ID] = Track{Song: song, File: file}
+	return lib.save()
+}
+
+// prune drops songs that are no longer in any synced playlist. Callers
+// hold the lock.
+func (lib *Library) prune() {
+	wanted := make(map[string]bool)
+	for _, playlist := range lib.manifest.Playlists {
+		for _, id := range playlist.SongIDs {
+			wanted[id] = true
+		}
+	}
+
+	for id, track := range lib.manifest.Tracks {
+		if wanted[id] {
+			continue
+		}
+		os.Remove(filepath.Join(lib.dir, track.File))
+		delete(lib.manifest.Tracks, id)
+	}
+}
@@ -0,0 +1,199 @@
+package logging
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+// Logs go to a file, never the terminal, since the terminal belongs to the
+// progress bar. Anything that looks like a credential is blanked on the way
+// out, so a log can be handed over in a bug report as is.
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	// The log moves aside once it's this big, keeping this many old ones
+	maxSize  = 5 * 1024 * 1024
+	keepLogs = 3
+
+	redacted = "[redacted]"
+)
+
+// Levels are the names --log-level understands
+var Levels = []string{"debug", "info", "warn", "error"}
+
+// secrets are parameter, header and attribute names whose values never
+// make it into the log. Subsonic sends the password as p, or a salted
+// token as t and s.
+var secrets = map[string]bool{
+	"p":             true,
+	"t":             true,
+	"s":             true,
+	"password":      true,
+	"token":         true,
+	"apikey":        true,
+	"api_key":       true,
+	"api_sig":       true,
+	"sk":            true,
+	"session_key":   true,
+	"secret":        true,
+	"authorization": true,
+	"cookie":        true,
+	"set-cookie":    true,
+}
+
+// Setup sends the default slog logger to a file at path, rotating it as it
+// grows. The returned closer closes the file.
+func Setup(path string, level string) (io.Closer, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("unknown log level '%s' (have: %s)", level, strings.Join(Levels, ", "))
+	}
+
+	file, err := openRotating(path)
+	if err != nil {
+		return nil, err
+	}
+
+	logger := slog.New(slog.NewTextHandler(file, &slog.HandlerOptions{
+		Level:       lvl,
+		ReplaceAttr: redactAttr,
+	}))
+	slog.SetDefault(logger)
+	return file, nil
+}
+
+// Secret is true for names whose values shouldn't be logged
+func Secret(name string) bool {
+	return secrets[strings.ToLower(name)]
+}
+
+// RedactURL blanks out credentials in a url's query string and user info
+func RedactURL(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	if parsed.User != nil {
+		parsed.User = url.User(parsed.User.Username())
+	}
+	if parsed.RawQuery != "" {
+		parsed.RawQuery = RedactForm(parsed.Query()).Encode()
+	}
+	return parsed.String()
+}
+
+// RedactForm returns a copy of values with credentials blanked out
+func RedactForm(values url.Values) url.Values {
+	clean := make(url.Values, len(values))
+	for key, vals := range values {
+		if Secret(key) {
+			clean[key] = []string{redacted}
+			continue
+		}
+		clean[key] = vals
+	}
+	return clean
+}
+
+// RedactHeaders returns a copy of headers with credentials blanked out
+func RedactHeaders(headers http.Header) http.Header {
+	clean := make(http.Header, len(headers))
+	for key, vals := range headers {
+		if Secret(key) {
+			clean[key] = []string{redacted}
+			continue
+		}
+		clean[key] = vals
+	}
+	return clean
+}
+
+// redactAttr catches credentials logged by name, and urls that carry them
+func redactAttr(groups []string, attr slog.Attr) slog.Attr {
+	if Secret(attr.Key) {
+		return slog.String(attr.Key, redacted)
+	}
+	if attr.Value.Kind() == slog.KindString {
+		if value := attr.Value.String(); strings.Contains(value, "://") {
+			return slog.String(attr.Key, RedactURL(value))
+		}
+	}
+	return attr
+}
+
+// rotating is a log file that moves itself aside once it gets big. Old
+// logs are kept as path.1 (the newest) through path.3.
+type rotating struct {
+	lock sync.Mutex
+	path string
+	file *os.File
+	size int64
+}
+
+func openRotating(path string) (*rotating, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	log := &rotating{path: path}
+	if err := log.open(); err != nil {
+		return nil, err
+	}
+	return log, nil
+}
+
+func (log *rotating) open() error {
+	file, err := os.OpenFile(log.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	log.file = file
+	log.size = info.Size()
+	return nil
+}
+
+func (log *rotating) Write(p []byte) (int, error) {
+	log.lock.Lock()
+	defer log.lock.Unlock()
+
+	if log.size+int64(len(p)) > maxSize && log.size > 0 {
+		if err := log.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := log.file.Write(p)
+	log.size += int64(n)
+	return n, err
+}
+
+func (log *rotating) rotate() error {
+	log.file.Close()
+
+	for idx := keepLogs - 1; idx > 0; idx-- {
+		os.Rename(fmt.Sprintf("%s.%d", log.path, idx), fmt.Sprintf("%s.%d", log.path, idx+1))
+	}
+	if err := os.Rename(log.path, log.path+".1"); err != nil {
+		return err
+	}
+	return log.open()
+}
+
+func (log *rotating) Close() error {
+	log.lock.Lock()
+	defer log.lock.Unlock()
+	return log.file.Close()
+}
@@ -0,0 +1,103 @@
+package lyrics
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseLRC reads an .lrc file. Lines look like "[01:23.45]words", possibly
+// with several timestamps. Tags like [ar:...] are skipped, except [offset:]
+// which shifts every line. A file without any timestamps is treated as
+// plain lyrics.
+func ParseLRC(r io.Reader) (Lyrics, error) {
+	var (
+		timed  []Line
+		plain  []Line
+		offset time.Duration
+	)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if line == "" {
+			continue
+		}
+
+		var (
+			starts []time.Duration
+			tagged bool
+		)
+		for strings.HasPrefix(line, "[") {
+			end := strings.Index(line, "]")
+			if end < 0 {
+				break
+			}
+			tag := line[1:end]
+			line = strings.TrimSpace(line[end+1:])
+			tagged = true
+
+			if start, ok := parseTimestamp(tag); ok {
+				starts = append(starts, start)
+				continue
+			}
+			if value, ok := strings.CutPrefix(tag, "offset:"); ok {
+				// A positive offset makes lines show up sooner
+				if ms, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+					offset = -time.Duration(ms) * time.Millisecond
+				}
+			}
+		}
+
+		if len(starts) == 0 {
+			if !tagged {
+				plain = append(plain, Line{Text: line})
+			}
+			continue
+		}
+		for _, start := range starts {
+			timed = append(timed, Line{Start: start, Text: line})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Lyrics{}, err
+	}
+
+	if len(timed) == 0 {
+		return Lyrics{Lines: plain}, nil
+	}
+
+	for idx := range timed {
+		timed[idx].Start += offset
+	}
+	sortLines(timed)
+	return Lyrics{Synced: true, Lines: timed}, nil
+}
+
+// parseTimestamp reads mm:ss, mm:ss.xx or mm:ss:xx
+func parseTimestamp(tag string) (time.Duration, bool) {
+	mins, rest, ok := strings.Cut(tag, ":")
+	if !ok {
+		return 0, false
+	}
+	minutes, err := strconv.Atoi(mins)
+	if err != nil || minutes < 0 {
+		return 0, false
+	}
+
+	// Some files use a colon before the hundredths
+	if secs, frac, ok := strings.Cut(rest, ":"); ok {
+		rest = secs + "." + frac
+	}
+	seconds, err := strconv.ParseFloat(rest, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+
+	return time.Duration(minutes)*time.Minute + time.Duration(seconds*float64(time.Second)), true
+}
@@ -0,0 +1,160 @@
+package lyrics
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+// Lyrics for the song that's playing, from the server or from .lrc files
+// sitting next to the music.
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/sonic"
+)
+
+type (
+	Line struct {
+		Start time.Duration
+		Text  string
+	}
+
+	// Lyrics are Synced when every line has a start time. Otherwise, the
+	// lines are just the text, in order.
+	Lyrics struct {
+		Synced bool
+		Lines  []Line
+		Source string
+	}
+
+	// Finder looks for lyrics on the server, then in .lrc files. Dir is
+	// optional and points at a local copy of the server's music folder.
+	Finder struct {
+		Client *sonic.Sonic
+		Dir    string
+	}
+)
+
+func (lyrics Lyrics) Empty() bool {
+	return len(lyrics.Lines) == 0
+}
+
+// At is the index of the line being sung at pos, or -1 before the first
+// line. Unsynced lyrics are always at -1.
+func (lyrics Lyrics) At(pos time.Duration) int {
+	if !lyrics.Synced {
+		return -1
+	}
+	return sort.Search(len(lyrics.Lines), func(idx int) bool {
+		return lyrics.Lines[idx].Start > pos
+	}) - 1
+}
+
+// FromStructured converts the server's structured lyrics
+func FromStructured(structured sonic.StructuredLyrics) Lyrics {
+	lyrics := Lyrics{Synced: structured.Synced, Source: "server"}
+	// A positive offset makes lines show up sooner, as in .lrc files
+	offset := time.Duration(structured.Offset) * time.Millisecond
+	for _, line := range structured.Lines {
+		lyrics.Lines = append(lyrics.Lines, Line{
+			Start: time.Duration(line.Start)*time.Millisecond - offset,
+			Text:  line.Value,
+		})
+	}
+	if lyrics.Synced {
+		sortLines(lyrics.Lines)
+	}
+	return lyrics
+}
+
+// Plain splits unsynced lyrics into lines
+func Plain(text string, source string) Lyrics {
+	lyrics := Lyrics{Source: source}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		lyrics.Lines = append(lyrics.Lines, Line{Text: strings.TrimSpace(line)})
+	}
+	if len(lyrics.Lines) == 1 && lyrics.Lines[0].Text == "" {
+		lyrics.Lines = nil
+	}
+	return lyrics
+}
+
+// Find returns the best lyrics it can for a song, preferring synced ones.
+// localFile is where the song is playing from, since a library copy can
+// have a sidecar too.
+func (finder Finder) Find(song sonic.Song, localFile string) (Lyrics, bool) {
+	var fallback Lyrics
+
+	if finder.Client != nil && finder.Client.Supports(sonic.ExtensionSongLyrics) {
+		if structured, err := finder.Client.GetLyricsBySongID(song.ID); err == nil {
+			for _, set := range structured {
+				lyrics := FromStructured(set)
+				if lyrics.Empty() {
+					continue
+				}
+				if lyrics.Synced {
+					return lyrics, true
+				}
+				if fallback.Empty() {
+					fallback = lyrics
+				}
+			}
+		}
+	}
+
+	for _, path := range finder.sidecars(song, localFile) {
+		file, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		lyrics, err := ParseLRC(file)
+		file.Close()
+		if err != nil || lyrics.Empty() {
+			continue
+		}
+		lyrics.Source = path
+		if lyrics.Synced {
+			return lyrics, true
+		}
+		if fallback.Empty() {
+			fallback = lyrics
+		}
+	}
+
+	if fallback.Empty() && finder.Client != nil && song.Artist != "" && song.Title != "" {
+		if plain, err := finder.Client.GetLyrics(song.Artist, song.Title); err == nil {
+			fallback = Plain(plain.Value, "server")
+		}
+	}
+
+	return fallback, !fallback.Empty()
+}
+
+// sidecars lists the .lrc files that could belong to a song
+func (finder Finder) sidecars(song sonic.Song, localFile string) []string {
+	paths := make([]string, 0, 3)
+	if localFile != "" && !strings.Contains(localFile, "://") {
+		paths = append(paths, strings.TrimSuffix(localFile, filepath.Ext(localFile))+".lrc")
+	}
+	if finder.Dir != "" {
+		if song.Path != "" {
+			path := filepath.Join(finder.Dir, filepath.FromSlash(song.Path))
+			paths = append(paths, strings.TrimSuffix(path, filepath.Ext(path))+".lrc")
+		}
+		if song.Artist != "" && song.Title != "" {
+			name := strings.NewReplacer("/", "_", "\\", "_").Replace(song.Artist + " - " + song.Title)
+			paths = append(paths, filepath.Join(finder.Dir, name+".lrc"))
+		}
+	}
+	return paths
+}
+
+func sortLines(lines []Line) {
+	sort.SliceStable(lines, func(i int, j int) bool {
+		return lines[i].Start < lines[j].Start
+	})
+}
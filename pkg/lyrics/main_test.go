@@ -0,0 +1,73 @@
+package lyrics
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/sonic"
+)
+
+// The server's offset and an .lrc [offset:] tag mean the same thing, so
+// both should land lines in the same place
+func TestOffsets(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset int64
+		lrc    string
+		want   []time.Duration
+	}{
+		{
+			name: "none",
+			lrc:  "[00:01.00]one\n[00:02.50]two\n",
+			want: []time.Duration{1 * time.Second, 2500 * time.Millisecond},
+		},
+		{
+			name:   "positive shows lines sooner",
+			offset: 500,
+			lrc:    "[offset:500]\n[00:01.00]one\n[00:02.50]two\n",
+			want:   []time.Duration{500 * time.Millisecond, 2 * time.Second},
+		},
+		{
+			name:   "negative shows lines later",
+			offset: -250,
+			lrc:    "[offset:-250]\n[00:01.00]one\n[00:02.50]two\n",
+			want:   []time.Duration{1250 * time.Millisecond, 2750 * time.Millisecond},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			structured := FromStructured(sonic.StructuredLyrics{
+				Synced: true,
+				Offset: test.offset,
+				Lines: []sonic.LyricsLine{
+					{Start: 1000, Value: "one"},
+					{Start: 2500, Value: "two"},
+				},
+			})
+
+			parsed, err := ParseLRC(strings.NewReader(test.lrc))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for source, lyrics := range map[string]Lyrics{"structured": structured, "lrc": parsed} {
+				if !lyrics.Synced {
+					t.Errorf("%s: not synced", source)
+				}
+				if len(lyrics.Lines) != len(test.want) {
+					t.Fatalf("%s: got %d lines, want %d", source, len(lyrics.Lines), len(test.want))
+				}
+				for idx, want := range test.want {
+					if got := lyrics.Lines[idx].Start; got != want {
+						t.Errorf("%s: line %d starts at %s, want %s", source, idx, got, want)
+					}
+				}
+			}
+		})
+	}
+}
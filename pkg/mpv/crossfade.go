@@ -0,0 +1,166 @@
+package mpv
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"sync"
+	"time"
+)
+
+const fadeStep = 100 * time.Millisecond
+
+// Decks pairs two mpv instances so that the tail of one track can overlap
+// the start of the next. If the second instance is nil, Decks behaves like
+// a single instance and Swap does nothing.
+type Decks struct {
+	decks  [2]*Instance
+	active int
+	fading bool
+	lock   sync.Mutex
+}
+
+func NewDecks(primary *Instance, secondary *Instance) *Decks {
+	return &Decks{decks: [2]*Instance{primary, secondary}}
+}
+
+// Active is the instance playing the current track
+func (d *Decks) Active() *Instance {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.decks[d.active]
+}
+
+// Standby is the instance waiting for the next track, or nil if crossfading
+// is disabled
+func (d *Decks) Standby() *Instance {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.decks[1-d.active]
+}
+
+func (d *Decks) Swap() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.decks[1] == nil {
+		return
+	}
+	d.active = 1 - d.active
+}
+
+func (d *Decks) Fading() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.fading
+}
+
+func (d *Decks) each(fn func(*Instance)) {
+	for _, inst := range d.decks {
+		if inst != nil {
+			fn(inst)
+		}
+	}
+}
+
+func (d *Decks) PauseToggle() {
+	d.each(func(inst *Instance) { inst.PauseToggle() })
+}
+
+// Paused is whether the active track is paused
+func (d *Decks) Paused() bool {
+	return d.Active().Paused()
+}
+
+func (d *Decks) SetPause(paused bool) {
+	d.each(func(inst *Instance) { inst.SetPause(paused) })
+}
+
+func (d *Decks) MuteToggle() {
+	d.each(func(inst *Instance) { inst.MuteToggle() })
+}
+
+// Next stops the active track. If a crossfade is in progress, the incoming
+// track keeps playing.
+func (d *Decks) Next() {
+	d.Active().Next()
+}
+
+// Seek moves around in the active track
+func (d *Decks) Seek(position time.Duration) {
+	d.Active().Seek(position)
+}
+
+// Volume is the volume of the active track
+func (d *Decks) Volume() float64 {
+	return d.Active().Volume()
+}
+
+func (d *Decks) SetVolume(vol float64) {
+	d.each(func(inst *Instance) { inst.SetVolume(vol) })
+}
+
+func (d *Decks) Shutdown() {
+	d.each(func(inst *Instance) { inst.Shutdown() })
+}
+
+// Crossfade starts path on the standby instance and ramps the volumes of
+// both instances over the given duration. When the fade completes, the
+// outgoing track is stopped, which closes its notification channel. The
+// caller is expected to Swap once it moves on to the incoming track.
+func (d *Decks) Crossfade(path string, over time.Duration) chan PlayNotification {
+	d.lock.Lock()
+	from := d.decks[d.active]
+	to := d.decks[1-d.active]
+	if to == nil || d.fading {
+		d.lock.Unlock()
+		return nil
+	}
+	d.fading = true
+	d.lock.Unlock()
+
+	level := from.Volume()
+	if level <= 0 {
+		level = 100
+	}
+
+	to.SetVolume(0)
+	notif := to.Play(path)
+
+	go func() {
+		steps := int(over / fadeStep)
+		if steps < 1 {
+			steps = 1
+		}
+
+		for step := 1; step <= steps; step++ {
+			time.Sleep(fadeStep)
+			ratio := float64(step) / float64(steps)
+			from.SetVolume(level * (1 - ratio))
+			to.SetVolume(level * ratio)
+		}
+
+		from.Next()
+		from.SetVolume(level)
+
+		d.lock.Lock()
+		d.fading = false
+		d.lock.Unlock()
+	}()
+
+	return notif
+}
+
+// Cancel abandons an incoming track, stopping it and draining its
+// notifications so the polling goroutine can exit
+func (d *Decks) Cancel(notif chan PlayNotification) {
+	if notif == nil {
+		return
+	}
+	if standby := d.Standby(); standby != nil {
+		standby.Next()
+	}
+	go func() {
+		for range notif {
+		}
+	}()
+}
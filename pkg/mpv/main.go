@@ -6,7 +6,10 @@ package mpv
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"os/exec"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/blang/mpv"
@@ -16,43 +19,97 @@ type Instance struct {
 	running    bool
 	socketPath string
 
-	mpv *mpv.Client
-	cmd *exec.Cmd
+	// lock guards mpv and cmd, which change whenever mpv restarts
+	lock sync.Mutex
+	mpv  *mpv.Client
+	cmd  *exec.Cmd
 }
 
 func New(socketPath string) Instance {
 	return Instance{socketPath: socketPath}
 }
 
+// client is the connection to the running mpv, or nil between runs
+func (inst *Instance) client() *mpv.Client {
+	inst.lock.Lock()
+	defer inst.lock.Unlock()
+	return inst.mpv
+}
+
 func (inst *Instance) PauseToggle() {
-	if inst.mpv == nil {
+	client := inst.client()
+	if client == nil {
 		return
 	}
-	ok, _ := inst.mpv.Pause()
-	inst.mpv.SetPause(!ok)
+	ok, _ := client.Pause()
+	client.SetPause(!ok)
 }
 
 func (inst *Instance) MuteToggle() {
-	if inst.mpv == nil {
+	client := inst.client()
+	if client == nil {
+		return
+	}
+	ok, _ := client.Mute()
+	client.SetMute(!ok)
+}
+
+func (inst *Instance) Paused() bool {
+	client := inst.client()
+	if client == nil {
+		return false
+	}
+	paused, _ := client.Pause()
+	return paused
+}
+
+func (inst *Instance) SetPause(paused bool) {
+	client := inst.client()
+	if client == nil {
 		return
 	}
-	ok, _ := inst.mpv.Mute()
-	inst.mpv.SetMute(!ok)
+	client.SetPause(paused)
+}
+
+func (inst *Instance) Volume() float64 {
+	client := inst.client()
+	if client == nil {
+		return 0
+	}
+	vol, _ := client.Volume()
+	return vol
+}
+
+func (inst *Instance) SetVolume(vol float64) {
+	client := inst.client()
+	if client == nil {
+		return
+	}
+	client.SetProperty("volume", vol)
 }
 
 func (inst *Instance) LaunchAndBlock(ctx context.Context, started chan bool) chan error {
-	errChan := make(chan error)
+	// Buffered, so nothing is left hanging once the caller stops listening
+	errChan := make(chan error, 1)
 
 	go func() {
 	LOOP:
-		for {
+		for runs := 0; ctx.Err() == nil; runs++ {
+			if runs > 0 {
+				slog.Info("restarting mpv", "socket", inst.socketPath, "restarts", runs)
+			}
 			inst.running = true
-			runErr := make(chan error)
+			runErr := make(chan error, 1)
 
 			go inst.runOne(runErr, started)
 			select {
 			case err := <-runErr:
-				errChan <- err
+				slog.Warn("mpv stopped unexpectedly", "socket", inst.socketPath, "err", err)
+				select {
+				case errChan <- err:
+				case <-ctx.Done():
+					break LOOP
+				}
 			case <-ctx.Done():
 				errChan <- nil
 				break LOOP
@@ -67,27 +124,82 @@ func (inst *Instance) LaunchAndBlock(ctx context.Context, started chan bool) cha
 
 type PlayNotification struct {
 	PercentComplete float64
+	Position        float64
+	Duration        float64
+
+	// Title is what a live stream says is playing, from its ICY metadata
+	Title string
+}
+
+// Remaining is the number of seconds left in the track, or zero if mpv
+// hasn't told us how long the track is
+func (notif PlayNotification) Remaining() float64 {
+	if notif.Duration <= 0 {
+		return 0
+	}
+	return notif.Duration - notif.Position
 }
 
 func (inst *Instance) Next() {
-	if inst.mpv == nil {
+	client := inst.client()
+	if client == nil {
+		return
+	}
+	client.Exec("stop")
+}
+
+// Seek jumps to a position in the current track
+func (inst *Instance) Seek(position time.Duration) {
+	client := inst.client()
+	if client == nil {
 		return
 	}
-	inst.mpv.Exec("stop")
+	client.Exec("seek", position.Seconds(), mpv.SeekModeAbsolute)
 }
 
 func (inst *Instance) Play(path string) chan PlayNotification {
+	return inst.PlayFrom(path, 0)
+}
+
+// PlayFrom is Play, starting at an offset into the track
+func (inst *Instance) PlayFrom(path string, offset time.Duration) chan PlayNotification {
 	notif := make(chan PlayNotification)
-	inst.mpv.Loadfile(path, mpv.LoadFileModeReplace)
+
+	// 'start' applies to every file loaded after it's set, so it's reset
+	// for tracks that play from the top
+	start := "none"
+	if offset > 0 {
+		start = fmt.Sprintf("%.3f", offset.Seconds())
+	}
+	client := inst.client()
+	if client == nil {
+		close(notif)
+		return notif
+	}
+	client.SetProperty("start", start)
+
+	client.Loadfile(path, mpv.LoadFileModeReplace)
 	go func() {
 		for {
 			time.Sleep(1 * time.Second)
-			pct, err := inst.mpv.PercentPosition()
+			// mpv may have been restarted since
+			client := inst.client()
+			if client == nil {
+				close(notif)
+				return
+			}
+			pct, err := client.PercentPosition()
 			if err != nil {
 				close(notif)
 				return
 			}
-			notif <- PlayNotification{PercentComplete: pct}
+			pos, _ := client.Position()
+			dur, _ := client.Duration()
+			notif <- PlayNotification{
+				PercentComplete: pct,
+				Position:        pos,
+				Duration:        dur,
+			}
 			if pct <= 0 {
 				close(notif)
 				return
@@ -98,37 +210,125 @@ func (inst *Instance) Play(path string) chan PlayNotification {
 	return notif
 }
 
+// PlayLive plays a stream that has no end, like internet radio. There's no
+// percentage to report, so notifications carry the position and the
+// stream's current title instead. The channel closes when the stream stops.
+func (inst *Instance) PlayLive(url string) chan PlayNotification {
+	notif := make(chan PlayNotification)
+	client := inst.client()
+	if client == nil {
+		close(notif)
+		return notif
+	}
+	client.SetProperty("start", "none")
+	client.Loadfile(url, mpv.LoadFileModeReplace)
+	go func() {
+		for {
+			time.Sleep(1 * time.Second)
+			client := inst.client()
+			if client == nil {
+				close(notif)
+				return
+			}
+			idle, err := client.GetBoolProperty("idle-active")
+			if err != nil || idle {
+				close(notif)
+				return
+			}
+			pos, _ := client.Position()
+			notif <- PlayNotification{
+				Position: pos,
+				Title:    streamTitle(client),
+			}
+		}
+	}()
+
+	return notif
+}
+
+// streamTitle digs the ICY title out of mpv's metadata property
+func streamTitle(client *mpv.Client) string {
+	res, err := client.Exec("get_property", "metadata")
+	if err != nil || res == nil {
+		return ""
+	}
+
+	metadata, ok := res.Data.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	for key, value := range metadata {
+		if strings.EqualFold(key, "icy-title") {
+			title, _ := value.(string)
+			return strings.TrimSpace(title)
+		}
+	}
+	return ""
+}
+
 func (inst *Instance) Shutdown() {
+	inst.lock.Lock()
+	defer inst.lock.Unlock()
 	if inst.cmd != nil {
 		inst.cmd.Process.Kill()
 	}
 }
 
 func (inst *Instance) runOne(errChan chan error, started chan bool) {
-	inst.cmd = exec.Command(
+	cmd := exec.Command(
 		"mpv",
 		"--idle",
 		fmt.Sprintf("--input-ipc-server=%s", inst.socketPath),
 	)
 
-	err := inst.cmd.Start()
+	err := cmd.Start()
 	if err != nil {
-		inst.mpv = nil
 		errChan <- err
 		return
 	}
+	inst.lock.Lock()
+	inst.cmd = cmd
+	inst.lock.Unlock()
 	time.Sleep(1 * time.Second)
 
+	slog.Info("mpv started", "pid", cmd.Process.Pid, "socket", inst.socketPath)
 	ipcc := mpv.NewIPCClient(inst.socketPath)
-	inst.mpv = mpv.NewClient(ipcc)
+	inst.lock.Lock()
+	inst.mpv = mpv.NewClient(logIPC{ipcc, inst.socketPath})
+	inst.lock.Unlock()
 
 	started <- true
-	err = inst.cmd.Wait()
+	err = cmd.Wait()
+	slog.Info("mpv exited", "socket", inst.socketPath, "err", err)
 
+	inst.lock.Lock()
 	inst.mpv = nil
 	inst.cmd = nil
+	inst.lock.Unlock()
 
 	if err != nil {
 		errChan <- err
 	}
 }
+
+// logIPC logs what goes back and forth with mpv. Progress polling makes a
+// lot of it, so it's all at debug.
+type logIPC struct {
+	next   mpv.LLClient
+	socket string
+}
+
+func (ipc logIPC) Exec(command ...interface{}) (*mpv.Response, error) {
+	resp, err := ipc.next.Exec(command...)
+	if slog.Default().Enabled(context.Background(), slog.LevelDebug) {
+		attrs := []any{"socket", ipc.socket, "command", command}
+		if resp != nil {
+			attrs = append(attrs, "data", resp.Data)
+		}
+		if err != nil {
+			attrs = append(attrs, "err", err)
+		}
+		slog.Debug("mpv ipc", attrs...)
+	}
+	return resp, err
+}
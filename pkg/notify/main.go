@@ -0,0 +1,310 @@
+package notify
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+// Desktop notifications for track changes, through the freedesktop
+// notification service (https://specifications.freedesktop.org/notification-spec/)
+// when there's a session bus to find it on. Each notification replaces the
+// last, so they don't pile up, shows the album art, and has buttons for
+// next, star and pause that are kept up to date while it's showing. Without a session bus, like on a Mac, it falls back
+// to a plain notification.
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/gen2brain/beeep"
+	"github.com/godbus/dbus/v5"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/control"
+	"git.sr.ht/~sungo/hedgehog/pkg/sonic"
+)
+
+const (
+	serviceName = "org.freedesktop.Notifications"
+	servicePath = dbus.ObjectPath("/org/freedesktop/Notifications")
+	iface       = "org.freedesktop.Notifications"
+
+	application = "hedgehog"
+	defaultIcon = "audio-x-generic"
+
+	// Cover art is asked for at this size, which is plenty for a popup
+	coverSize = 256
+
+	DefaultSummary = "{{.Title}}"
+	DefaultBody    = "{{.Artist}}{{with .Details}}\n{{.}}{{end}}"
+)
+
+// Templates format the summary and body of each notification
+type Templates struct {
+	summary *template.Template
+	body    *template.Template
+}
+
+type Notifier struct {
+	Templates
+
+	// client fetches cover art into dir. Without one, notifications get a
+	// generic icon.
+	client *sonic.Sonic
+	dir    string
+
+	// do runs a player action, by key binding action name
+	do func(action string) error
+
+	conn *dbus.Conn
+
+	// replaces is the notification that's up, or 0 once it's closed
+	lock     sync.Mutex
+	replaces uint32
+}
+
+// Parse checks the summary and body templates. Empty templates use the
+// defaults.
+func Parse(summary string, body string) (Templates, error) {
+	if summary == "" {
+		summary = DefaultSummary
+	}
+	if body == "" {
+		body = DefaultBody
+	}
+
+	var (
+		templates Templates
+		err       error
+	)
+	if templates.summary, err = template.New("summary").Option("missingkey=zero").Parse(summary); err != nil {
+		return Templates{}, fmt.Errorf("bad notification summary: %w", err)
+	}
+	if templates.body, err = template.New("body").Option("missingkey=zero").Parse(body); err != nil {
+		return Templates{}, fmt.Errorf("bad notification body: %w", err)
+	}
+	return templates, nil
+}
+
+// New sets up notifications, formatted with templates. Buttons pressed on
+// a notification are handed to do.
+func New(templates Templates, client *sonic.Sonic, dir string, do func(action string) error) *Notifier {
+	notifier := &Notifier{Templates: templates, client: client, dir: dir, do: do}
+	if err := notifier.connect(); err != nil {
+		slog.Info("no notification service on the session bus, using plain notifications", "err", err)
+	}
+	return notifier
+}
+
+// connect finds the notification service and listens for its buttons
+func (notifier *Notifier) connect() error {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return err
+	}
+
+	var name, vendor, version, spec string
+	err = conn.Object(serviceName, servicePath).
+		Call(iface+".GetServerInformation", 0).
+		Store(&name, &vendor, &version, &spec)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	slog.Debug("notification service", "name", name, "vendor", vendor, "version", version, "spec", spec)
+
+	for _, member := range []string{"ActionInvoked", "NotificationClosed"} {
+		err = conn.AddMatchSignal(
+			dbus.WithMatchObjectPath(servicePath),
+			dbus.WithMatchInterface(iface),
+			dbus.WithMatchMember(member),
+		)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+	}
+
+	signals := make(chan *dbus.Signal, 8)
+	conn.Signal(signals)
+	go notifier.buttons(signals)
+
+	notifier.conn = conn
+	return nil
+}
+
+// buttons passes presses of our notifications' buttons on to the player,
+// and notes when the notification goes away
+func (notifier *Notifier) buttons(signals chan *dbus.Signal) {
+	for signal := range signals {
+		if len(signal.Body) < 2 {
+			continue
+		}
+		id, _ := signal.Body[0].(uint32)
+
+		notifier.lock.Lock()
+		ours := id != 0 && id == notifier.replaces
+		if ours && signal.Name == iface+".NotificationClosed" {
+			notifier.replaces = 0
+		}
+		notifier.lock.Unlock()
+
+		action, _ := signal.Body[1].(string)
+		if !ours || signal.Name != iface+".ActionInvoked" || action == "default" {
+			continue
+		}
+
+		if err := notifier.do(action); err != nil {
+			slog.Warn("notification button failed", "action", action, "err", err)
+		}
+	}
+}
+
+// Watch shows a notification for every track that starts, until the
+// player exits. Pausing and starring redo the one that's up, so its
+// buttons say what they'll do.
+func (notifier *Notifier) Watch(hub *control.Hub) {
+	events, stop := hub.Subscribe()
+	go func() {
+		defer stop()
+		for event := range events {
+			switch event.Name {
+			case control.TrackStart:
+				// Radio stations go quiet on the title between songs
+				if event.State.Title != "" {
+					notifier.Show(event.State)
+				}
+			case control.Pause, control.Star:
+				notifier.refresh(event.State)
+			case control.Exit:
+				notifier.Close()
+				return
+			}
+		}
+	}()
+}
+
+// Show pops up a notification for a track, replacing the last one
+func (notifier *Notifier) Show(state control.State) {
+	summary, err := render(notifier.summary, state)
+	if err != nil {
+		slog.Warn("notification summary failed", "err", err)
+		return
+	}
+	body, err := render(notifier.body, state)
+	if err != nil {
+		slog.Warn("notification body failed", "err", err)
+		return
+	}
+
+	if notifier.conn == nil {
+		beeep.Notify(summary, body, "")
+		return
+	}
+
+	icon := notifier.cover(state)
+	hints := map[string]dbus.Variant{
+		"category":      dbus.MakeVariant("x-gnome.music"),
+		"desktop-entry": dbus.MakeVariant(application),
+	}
+	if icon != defaultIcon {
+		hints["image-path"] = dbus.MakeVariant("file://" + icon)
+	}
+
+	star, pause := "Star", "Pause"
+	if state.Starred {
+		star = "Unstar"
+	}
+	if state.Status == control.Paused {
+		pause = "Play"
+	}
+	actions := []string{"next", "Next", "pause", pause}
+	if !state.Live {
+		actions = append(actions, "star", star)
+	}
+
+	notifier.lock.Lock()
+	defer notifier.lock.Unlock()
+
+	var id uint32
+	err = notifier.conn.Object(serviceName, servicePath).
+		Call(iface+".Notify", 0, application, notifier.replaces, icon, summary, body, actions, hints, int32(-1)).
+		Store(&id)
+	if err != nil {
+		slog.Warn("notification failed", "err", err)
+		return
+	}
+	notifier.replaces = id
+}
+
+// refresh redoes the notification that's up, if it's still up
+func (notifier *Notifier) refresh(state control.State) {
+	if notifier.conn == nil || state.Title == "" {
+		return
+	}
+
+	notifier.lock.Lock()
+	showing := notifier.replaces != 0
+	notifier.lock.Unlock()
+	if showing {
+		notifier.Show(state)
+	}
+}
+
+// cover fetches a track's artwork, or falls back to a generic icon
+func (notifier *Notifier) cover(state control.State) string {
+	if notifier.client == nil || state.CoverArt == "" || notifier.dir == "" {
+		return defaultIcon
+	}
+
+	path := filepath.Join(notifier.dir, "cover-"+safeName(state.CoverArt))
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+
+	data, err := notifier.client.GetCoverArt(state.CoverArt, coverSize)
+	if err != nil {
+		slog.Debug("no cover art", "id", state.CoverArt, "err", err)
+		return defaultIcon
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		slog.Warn("saving cover art failed", "err", err)
+		return defaultIcon
+	}
+	return path
+}
+
+// Close takes down the last notification and hangs up on the bus
+func (notifier *Notifier) Close() {
+	if notifier.conn == nil {
+		return
+	}
+
+	notifier.lock.Lock()
+	if notifier.replaces != 0 {
+		notifier.conn.Object(serviceName, servicePath).Call(iface+".CloseNotification", 0, notifier.replaces)
+	}
+	notifier.lock.Unlock()
+	notifier.conn.Close()
+}
+
+func render(tmpl *template.Template, state control.State) (string, error) {
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, state); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// safeName keeps a server id from wandering out of the directory
+func safeName(id string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, id)
+}
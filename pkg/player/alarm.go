@@ -0,0 +1,102 @@
+package player
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/mpv"
+)
+
+// wakeUp brings the volume up gently, for mornings. Snoozing pauses for a
+// while and then starts the ramp over.
+type wakeUp struct {
+	lock       sync.Mutex
+	generation int
+
+	ctx   context.Context
+	decks *mpv.Decks
+
+	// level is where the volume ends up once the ramp is done
+	level float64
+	over  time.Duration
+}
+
+func newWakeUp(ctx context.Context, decks *mpv.Decks, over time.Duration) *wakeUp {
+	level := decks.Volume()
+	if level <= 0 {
+		level = 100
+	}
+	return &wakeUp{ctx: ctx, decks: decks, level: level, over: over}
+}
+
+// Ramp drops the volume to nothing and brings it back up over the ramp
+// time. Without a ramp time, the volume is left alone.
+func (wake *wakeUp) Ramp() {
+	if wake.over <= 0 {
+		return
+	}
+
+	wake.lock.Lock()
+	wake.generation++
+	generation := wake.generation
+	wake.lock.Unlock()
+
+	wake.decks.SetVolume(0)
+	go func() {
+		ticker := time.NewTicker(sleepTick)
+		defer ticker.Stop()
+
+		started := time.Now()
+		for {
+			select {
+			case <-wake.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if wake.stale(generation) {
+				return
+			}
+
+			ratio := float64(time.Since(started)) / float64(wake.over)
+			if ratio >= 1 {
+				wake.decks.SetVolume(wake.level)
+				return
+			}
+			if !wake.decks.Fading() {
+				wake.decks.SetVolume(wake.level * ratio)
+			}
+		}
+	}()
+}
+
+// Snooze pauses for a while, then ramps back up. Snoozing again while
+// snoozed starts the wait over.
+func (wake *wakeUp) Snooze(pause time.Duration) {
+	wake.lock.Lock()
+	wake.generation++
+	generation := wake.generation
+	wake.lock.Unlock()
+
+	wake.decks.SetPause(true)
+	fmt.Printf("=> Snoozing until %s\n", time.Now().Add(pause).Format("15:04"))
+
+	time.AfterFunc(pause, func() {
+		if wake.ctx.Err() != nil || wake.stale(generation) {
+			return
+		}
+		wake.Ramp()
+		wake.decks.SetPause(false)
+	})
+}
+
+// stale is true once a newer ramp or snooze has taken over
+func (wake *wakeUp) stale(generation int) bool {
+	wake.lock.Lock()
+	defer wake.lock.Unlock()
+	return wake.generation != generation
+}
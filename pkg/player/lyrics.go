@@ -0,0 +1,156 @@
+package player
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/lyrics"
+	"git.sr.ht/~sungo/hedgehog/pkg/queue"
+
+	progressbar "github.com/schollz/progressbar/v3"
+)
+
+const (
+	ansiBold  = "\033[1m"
+	ansiDim   = "\033[2m"
+	ansiReset = "\033[0m"
+
+	// Up a line and clear it
+	ansiRewrite = "\033[1A\r\033[K"
+)
+
+// lyricsView prints the lyrics above the progress bar as the song plays.
+// Synced lyrics are printed a line at a time, with the current line in bold.
+// It's toggled from the keyboard but only draws from the play loop, so it
+// doesn't fight the progress bar.
+type lyricsView struct {
+	finder lyrics.Finder
+
+	lock      sync.Mutex
+	enabled   bool
+	entry     *queue.Entry
+	requested bool
+	loaded    bool
+	lyrics    lyrics.Lyrics
+
+	// announced is set once anything about this song's lyrics is printed
+	announced bool
+	// current is the line printed in bold, and fresh is whether it's still
+	// right above the bar, where it can be redrawn
+	current int
+	fresh   bool
+}
+
+func newLyricsView(finder lyrics.Finder, enabled bool) *lyricsView {
+	return &lyricsView{finder: finder, enabled: enabled, current: -1}
+}
+
+func (view *lyricsView) Toggle() {
+	view.lock.Lock()
+	defer view.lock.Unlock()
+
+	view.enabled = !view.enabled
+	view.announced = false
+	view.current = -1
+	view.fresh = false
+}
+
+// Reset starts over for a new entry
+func (view *lyricsView) Reset(entry *queue.Entry) {
+	view.lock.Lock()
+	defer view.lock.Unlock()
+
+	view.entry = entry
+	view.requested = false
+	view.loaded = false
+	view.lyrics = lyrics.Lyrics{}
+	view.announced = false
+	view.current = -1
+	view.fresh = false
+}
+
+func (view *lyricsView) fetch(entry *queue.Entry) {
+	found, _ := view.finder.Find(entry.Meta, entry.LocalFile)
+
+	view.lock.Lock()
+	defer view.lock.Unlock()
+	if view.entry != entry {
+		return
+	}
+	view.lyrics = found
+	view.loaded = true
+}
+
+// Update draws whatever has changed since last time. Call it before the bar
+// is redrawn.
+func (view *lyricsView) Update(bar *progressbar.ProgressBar, pos time.Duration) {
+	view.lock.Lock()
+	defer view.lock.Unlock()
+
+	if !view.enabled || view.entry == nil || view.entry.Live {
+		return
+	}
+	if !view.requested {
+		view.requested = true
+		go view.fetch(view.entry)
+		return
+	}
+	if !view.loaded {
+		return
+	}
+
+	lines := view.lyrics.Lines
+	if !view.announced {
+		view.announced = true
+		bar.Clear()
+		switch {
+		case view.lyrics.Empty():
+			fmt.Println(ansiDim + "(no lyrics)" + ansiReset)
+			return
+		case !view.lyrics.Synced:
+			for _, line := range lines {
+				fmt.Println(line.Text)
+			}
+			return
+		}
+	}
+	if !view.lyrics.Synced {
+		return
+	}
+
+	idx := view.lyrics.At(pos)
+	if idx == view.current {
+		return
+	}
+
+	bar.Clear()
+	from := idx
+	if view.current >= 0 && idx > view.current {
+		if view.fresh {
+			fmt.Print(ansiRewrite)
+			fmt.Println(ansiDim + lyricText(lines[view.current]) + ansiReset)
+		}
+		// Polling can step over short lines
+		from = view.current + 1
+	}
+	for ; from >= 0 && from < idx; from++ {
+		fmt.Println(ansiDim + lyricText(lines[from]) + ansiReset)
+	}
+	if idx >= 0 {
+		fmt.Println(ansiBold + lyricText(lines[idx]) + ansiReset)
+	}
+
+	view.current = idx
+	view.fresh = idx >= 0
+}
+
+func lyricText(line lyrics.Line) string {
+	if line.Text == "" {
+		return "♪"
+	}
+	return line.Text
+}
@@ -7,16 +7,27 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
-
+	"time"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/control"
+	"git.sr.ht/~sungo/hedgehog/pkg/history"
+	"git.sr.ht/~sungo/hedgehog/pkg/hooks"
+	"git.sr.ht/~sungo/hedgehog/pkg/keymap"
+	"git.sr.ht/~sungo/hedgehog/pkg/library"
+	"git.sr.ht/~sungo/hedgehog/pkg/lyrics"
 	"git.sr.ht/~sungo/hedgehog/pkg/mpv"
+	"git.sr.ht/~sungo/hedgehog/pkg/notify"
 	"git.sr.ht/~sungo/hedgehog/pkg/queue"
+	"git.sr.ht/~sungo/hedgehog/pkg/scrobble"
 	"git.sr.ht/~sungo/hedgehog/pkg/sonic"
+	"git.sr.ht/~sungo/hedgehog/pkg/web"
 
 	"github.com/eiannone/keyboard"
-	"github.com/gen2brain/beeep"
 	progressbar "github.com/schollz/progressbar/v3"
 )
 
@@ -25,16 +36,150 @@ type Config struct {
 	Password string
 	URL      string
 
+	// HTTP is how to reach the server: timeouts, certificates, proxies
+	HTTP sonic.HTTPConfig
+
 	PlaylistName   string
 	Shuffle        bool
 	Repeat         bool
 	ReloadOnRepeat bool
 	Notifications  bool
+	MaxBitRate     int
+
+	// NotifySummary and NotifyBody are text/template formats for song
+	// change notifications, given a control.State. Empty uses the defaults.
+	NotifySummary string
+	NotifyBody    string
+
+	// Crossfade is how long the end of one track overlaps the start of the
+	// next. Zero disables crossfading.
+	Crossfade time.Duration
+
+	// Keys overrides the default key bindings, by action name
+	Keys map[string][]string
+
+	Scrobble scrobble.Config
+	StateDir string
+
+	// LibraryDir holds playlists synced with 'hedgehog sync'. Songs found
+	// there aren't downloaded again. When Offline, the server is left alone
+	// and everything comes from the library.
+	LibraryDir string
+	Offline    bool
+
+	// Lyrics starts with the lyrics view open. LyricsDir is a local copy
+	// of the server's music folder, to look for .lrc files in.
+	Lyrics    bool
+	LyricsDir string
+
+	// BookmarkOver keeps the place in songs at least this long, so long
+	// mixes and audiobooks pick up where they left off. Zero disables it.
+	BookmarkOver time.Duration
+
+	// Radio plays one of the server's internet radio stations, by name,
+	// instead of a playlist
+	Radio string
+
+	// Podcast plays a podcast channel, by title, instead of a playlist.
+	// NewestPodcasts plays that many of the newest episodes across every
+	// channel. Either way, episodes resume where they were left off.
+	Podcast        string
+	NewestPodcasts int
+
+	// Albums plays AlbumCount whole albums from one of the server's album
+	// lists, in the list's order, instead of a playlist
+	Albums     sonic.AlbumList
+	AlbumCount int
+
+	// Endless keeps playing songs like the last one once the playlist
+	// runs out, instead of repeating or stopping
+	Endless bool
+
+	// Sleep stops playback after this long, fading out over the final
+	// minute. StopAfter stops once that many tracks have played. Zero
+	// disables either.
+	Sleep     time.Duration
+	StopAfter int
+
+	// History keeps every track played in StateDir, for 'hedgehog stats'.
+	// Like scrobbles, it leaves out podcasts and radio.
+	History bool
+
+	// Socket is where to answer status requests from 'hedgehog status'
+	// and the like. Empty doesn't listen.
+	Socket string
+
+	// Web serves a remote control on this address, like ":8080", for
+	// phones and browsers. WebToken is the password for it. Without one,
+	// a token is made up and kept in StateDir.
+	Web      string
+	WebToken string
+
+	// Hooks are shell commands to run when things happen, by event name
+	// (see hooks.Events). HookTimeout is how long each gets.
+	Hooks       map[string][]string
+	HookTimeout time.Duration
+
+	// RampUp brings the volume up from nothing over this long once the
+	// first track starts, and again after a snooze. Snooze is how long the
+	// snooze key pauses for. Without it, as outside of an alarm, there's no
+	// snooze key.
+	RampUp time.Duration
+	Snooze time.Duration
 }
 
-const Controls string = "[ q: quit | m: mute | p/<: back | n/>: next | *: star/unstar | r: update playlist | space: pause/unpause ]"
+const (
+	// How often to check whether the server is back while offline
+	reconnectInterval = time.Minute
+
+	// How often to save the position of bookmarked entries while they play
+	bookmarkInterval = 15 * time.Second
+
+	// Stopping this close to the end counts as finishing the entry
+	bookmarkTail = 30 * time.Second
+)
+
+func (config Config) podcasts() bool {
+	return config.Podcast != "" || config.NewestPodcasts > 0
+}
 
 func Start(config Config) error {
+	keys, err := keymap.New(config.Keys)
+	if err != nil {
+		return err
+	}
+	if config.Snooze <= 0 {
+		keys.Unbind(keymap.Snooze)
+	}
+
+	hooked, err := hooks.New(config.Hooks, config.HookTimeout)
+	if err != nil {
+		return err
+	}
+
+	var templates notify.Templates
+	if config.Notifications {
+		if templates, err = notify.Parse(config.NotifySummary, config.NotifyBody); err != nil {
+			return err
+		}
+	}
+
+	// Claim the web remote's port now, rather than find out it's taken
+	// once mpv is running
+	var site *web.Server
+	if config.Web != "" {
+		token := config.WebToken
+		if token == "" {
+			if token, err = web.Token(config.StateDir); err != nil {
+				return err
+			}
+		}
+		if site, err = web.Listen(config.Web, token); err != nil {
+			return fmt.Errorf("web remote: %w", err)
+		}
+		defer site.Close()
+	}
+
 	if err := keyboard.Open(); err != nil {
 		return err
 	}
@@ -53,41 +198,83 @@ func Start(config Config) error {
 		},
 		config.URL,
 	)
-	fmt.Println("Fetching playlists...")
-	playlists, err := client.GetPlaylists()
-	if err != nil {
+	if err := client.Configure(config.HTTP); err != nil {
 		return err
 	}
-
-	var playlistID string
-
-	for idx := range playlists {
-		playlist := playlists[idx]
-
-		if playlist.Name == config.PlaylistName {
-			playlistID = playlist.ID
-			break
+	client.MaxBitRate = config.MaxBitRate
+	if !config.Offline {
+		if err := client.Probe(); err != nil {
+			return err
 		}
 	}
 
-	if playlistID == "" {
-		return errors.New("unable to find playlist")
+	lib, err := library.Open(config.LibraryDir)
+	if err != nil {
+		return err
 	}
-	fmt.Printf("Fetching playlist '%s' as '%s'\n", config.PlaylistName, playlistID)
 
-	playlist, err := client.GetPlaylist(playlistID)
+	scrobbler, err := scrobble.New(config.Scrobble, &client, config.StateDir)
 	if err != nil {
 		return err
 	}
-	fmt.Println("Processing playlist...")
+	scrobbler.Offline = config.Offline
+	defer scrobbler.Close()
+
+	var (
+		playlist sonic.Playlist
+		source   queue.Source
+	)
+	switch {
+	case config.podcasts() && config.Offline:
+		return errors.New("podcasts can't be played offline")
+	case config.Radio != "" && config.Offline:
+		return errors.New("radio can't be played offline")
+	case config.Albums.Type != "" && config.Offline:
+		return errors.New("album lists can't be played offline")
+	case config.Radio != "":
+		source = queue.Radio{Client: &client, Station: config.Radio}
+		fmt.Printf("Tuning in to '%s'\n", config.Radio)
+	case config.podcasts():
+		source = queue.Podcasts{
+			Client:  &client,
+			Channel: config.Podcast,
+			Newest:  config.NewestPodcasts,
+		}
+		fmt.Println("Fetching podcasts...")
+	case config.Albums.Type != "":
+		source = queue.Albums{
+			Client: &client,
+			List:   config.Albums,
+			Count:  config.AlbumCount,
+		}
+		fmt.Printf("Fetching %s...\n", config.Albums)
+	case config.Offline:
+		fmt.Printf("Loading playlist '%s' from %s\n", config.PlaylistName, lib.Dir())
+		playlist, err = lib.Playlist(config.PlaylistName)
+		if err != nil {
+			return err
+		}
+	default:
+		if pending := scrobbler.Pending(); pending > 0 {
+			fmt.Printf("Retrying %d queued scrobbles in the background\n", pending)
+			scrobbler.Flush()
+		}
+		lib.ReplayStars(&client)
 
-	if len(playlist.Songs) == 0 {
-		return errors.New("empty playlist")
+		source = queue.ServerPlaylist{Client: &client, Name: config.PlaylistName}
+		fmt.Printf("Fetching playlist '%s'\n", config.PlaylistName)
 	}
 
-	if config.Shuffle {
-		fmt.Println("Shuffling...")
-		playlist = playlist.Shuffle()
+	if source == nil {
+		fmt.Println("Processing playlist...")
+		if len(playlist.Songs) == 0 {
+			return errors.New("empty playlist")
+		}
+
+		if config.Shuffle {
+			fmt.Println("Shuffling...")
+			playlist = playlist.Shuffle()
+		}
 	}
 
 	q := queue.New()
@@ -98,38 +285,104 @@ func Start(config Config) error {
 	q.ReloadOnRepeat = config.ReloadOnRepeat
 	q.Client = &client
 	q.TempDir = tempDir
+	q.Library = lib
+	q.Offline = config.Offline
+	q.Source = source
+	q.Bookmarks = config.podcasts()
+	q.BookmarkOver = config.BookmarkOver
+	q.Live = config.Radio != ""
+	q.Endless = config.Endless && !q.Live && !config.podcasts() && !config.Offline
 	defer q.CleanUp()
 
+	if source != nil {
+		// Big playlists start playing before they've fully arrived
+		if err := q.Load(); err != nil {
+			return err
+		}
+	}
+
 	fmt.Println("Updating metadata...")
 	q.UpdateStarred()
 
+	if config.Offline {
+		go replayWhenOnline(&client, lib, scrobbler)
+	}
+
 	fmt.Println("Launching backend...")
-	started := make(chan bool)
-	music := mpv.New(fmt.Sprintf("%s/mpv.sock", tempDir))
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	music := mpv.New(fmt.Sprintf("%s/mpv.sock", tempDir))
+	var fader *mpv.Instance
+	if config.Crossfade > 0 {
+		second := mpv.New(fmt.Sprintf("%s/mpv-fade.sock", tempDir))
+		fader = &second
+	}
+	decks := mpv.NewDecks(&music, fader)
+
+	hub := control.NewHub()
+	var remote *control.Server
+	waitForHooks := hooked.Watch(hub)
+	if config.History && !config.podcasts() {
+		plays, err := history.Open(config.StateDir)
+		if err != nil {
+			return err
+		}
+		plays.Watch(hub)
+	}
+
 	bye := func() {
+		hub.Publish(control.Exit, func(state *control.State) {
+			*state = control.State{Status: control.Stopped}
+		})
+		if remote != nil {
+			remote.Close()
+		}
+		if site != nil {
+			site.Close()
+		}
 		cancel()
 		q.CleanUp()
-		music.Shutdown()
+		q.Close()
+		decks.Shutdown()
 		os.RemoveAll(tempDir)
 	}
 
-	go func() {
-		select {
-		case err := <-music.LaunchAndBlock(ctx, started):
+	// finish ends playback and hands Start its return value. Only the first
+	// reason to stop counts, since shutting mpv down makes it complain too.
+	var (
+		stopping sync.Once
+		stopped  = make(chan error, 1)
+	)
+	finish := func(err error) {
+		stopping.Do(func() {
 			bye()
-			if err != nil {
-				fmt.Println(err)
-				os.Exit(1)
-			}
-			os.Exit(0)
+			stopped <- err
+		})
+	}
+
+	launch := func(inst *mpv.Instance) error {
+		started := make(chan bool)
+		go func() {
+			finish(<-inst.LaunchAndBlock(ctx, started))
+		}()
+		select {
+		case <-started:
+			return nil
+		case <-ctx.Done():
+			return <-stopped
 		}
-	}()
+	}
 
-	<-started
-	defer music.Shutdown()
+	if err := launch(&music); err != nil {
+		return err
+	}
+	if fader != nil {
+		if err := launch(fader); err != nil {
+			return err
+		}
+	}
+	defer decks.Shutdown()
 
 	sigc := make(chan os.Signal, 1)
 	signal.Notify(sigc,
@@ -138,105 +391,371 @@ func Start(config Config) error {
 		syscall.SIGTERM,
 		syscall.SIGQUIT,
 	)
+	defer signal.Stop(sigc)
 	go func() {
-		<-sigc
-		bye()
-		os.Exit(1)
+		select {
+		case <-sigc:
+			bye()
+			waitForHooks()
+			os.Exit(1)
+		case <-ctx.Done():
+		}
 	}()
 
-	go func() {
-		for {
-			char, key, err := keyboard.GetKey()
-			if err != nil {
-				panic(err)
+	finder := lyrics.Finder{Dir: config.LyricsDir}
+	if !config.Offline {
+		finder.Client = &client
+	}
+	view := newLyricsView(finder, config.Lyrics)
+
+	after := &stopAfter{left: config.StopAfter}
+	wake := newWakeUp(ctx, decks, config.RampUp)
+	sleeper := newSleepTimer(config.Sleep)
+	go sleeper.run(ctx, decks, func() {
+		fmt.Println("=> Good night")
+		finish(nil)
+	})
+
+	actions := map[keymap.Action]func(){
+		keymap.Quit: func() { finish(nil) },
+		keymap.Mute: decks.MuteToggle,
+		keymap.Previous: func() {
+			q.Previous()
+			decks.Next()
+		},
+		keymap.Next: decks.Next,
+		keymap.Star: func() {
+			q.StarToggle()
+			if song := q.Current(); song != nil {
+				hub.Publish(control.Star, func(state *control.State) {
+					state.Starred = q.Starred(song.Meta.ID)
+				})
+			}
+		},
+		keymap.Reload: func() {
+			q.UpdatePlaylist()
+			decks.Next()
+		},
+		keymap.Pause: func() {
+			decks.PauseToggle()
+			hub.Publish(control.Pause, func(state *control.State) {
+				state.Status = control.Playing
+				if decks.Paused() {
+					state.Status = control.Paused
+				}
+			})
+		},
+		keymap.Lyrics: view.Toggle,
+		keymap.Similar: func() {
+			if config.podcasts() || config.Offline {
+				return
 			}
-			// fmt.Printf("You pressed: rune %q, key %X\r\n", char, key)
-			switch {
-			case key == keyboard.KeyCtrlC:
-				fallthrough
-			case char == 'q':
-				fallthrough
-			case key == keyboard.KeyEsc:
-				bye()
-				os.Exit(0)
+			q.StartRadio()
+		},
+		keymap.Sleep: func() { sleeper.Extend(sleepStep) },
+		keymap.Wake:  sleeper.Cancel,
+		keymap.Stop:  after.Toggle,
+	}
+	if config.Snooze > 0 {
+		actions[keymap.Snooze] = func() { wake.Snooze(config.Snooze) }
+	}
 
-			case char == 'm':
-				music.MuteToggle()
+	// do runs an action on behalf of something other than the keyboard,
+	// like 'hedgehog remote' or a notification button
+	do := func(command string) error {
+		fn := actions[keymap.Action(command)]
+		if fn == nil {
+			return fmt.Errorf("unknown command '%s' (have: status, follow, %v)", command, keymap.Actions())
+		}
+		fn()
+		return nil
+	}
 
-			case char == 'p':
-				fallthrough
-			case char == '<':
-				q.Previous()
-				music.Next()
+	// The server, for things that can do without it while offline
+	var online *sonic.Sonic
+	if !config.Offline {
+		online = &client
+	}
 
-			case char == 'n':
-				fallthrough
-			case char == '>':
-				music.Next()
+	if config.Notifications {
+		notify.New(templates, online, tempDir, do).Watch(hub)
+	}
 
-			case char == '*':
-				q.StarToggle()
+	if config.Socket != "" {
+		remote, err = control.Listen(config.Socket, hub, do)
+		if err != nil {
+			// Not worth giving up over, it's only for status bars and such
+			slog.Warn("not listening for status requests", "socket", config.Socket, "err", err)
+		}
+	}
 
-			case char == 'r':
-				q.UpdatePlaylist()
-				music.Next()
+	if site != nil {
+		site.Serve(web.Player{
+			Hub:    hub,
+			Queue:  q,
+			Decks:  decks,
+			Client: online,
+			Do:     do,
+		})
+		fmt.Printf("Remote control at %s\n", site.URL())
+	}
 
-			case key == keyboard.KeySpace:
-				music.PauseToggle()
+	go func() {
+		for {
+			char, key, err := keyboard.GetKey()
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				panic(err)
+			}
+			if action, ok := keys.Feed(keymap.Key{Char: char, Code: key}); ok {
+				if fn := actions[action]; fn != nil {
+					fn()
+				}
 			}
 		}
 	}()
 
 	fmt.Println("Buffering...")
 	fmt.Println()
-	fmt.Println(Controls)
-
-	for {
+	fmt.Println(keys.Help())
 
-		bar := progressbar.NewOptions(100,
-			progressbar.OptionFullWidth(),
-			progressbar.OptionClearOnFinish(),
+	// The player runs until something calls finish, like quitting or
+	// running out of songs
+	go func() {
+		var (
+			incoming      chan mpv.PlayNotification
+			incomingEntry *queue.Entry
+			first         = true
+			partial       error
 		)
 
-		var (
-			lastPercent float64
+		for ctx.Err() == nil {
+			// Live streams don't end, so they get a spinner instead
+			max := 100
+			if config.Radio != "" {
+				max = -1
+			}
+			bar := progressbar.NewOptions(max,
+				progressbar.OptionFullWidth(),
+				progressbar.OptionClearOnFinish(),
+			)
 
-			song = q.WhatsNext()
-		)
+			var (
+				last mpv.PlayNotification
 
-		if song == nil {
-			bye()
-			return nil
-		}
+				song = q.WhatsNext()
+			)
 
-		if config.Notifications {
-			beeep.Notify("Song Change", song.String(), "")
-		}
+			if err := q.Partial(); err != nil && partial == nil {
+				fmt.Printf("=> Loading the rest of the playlist failed, so only part of it will play: %s\n", err)
+			}
+			partial = q.Partial()
 
-		isStarred := song.Starred
+			if song == nil {
+				decks.Cancel(incoming)
+				finish(nil)
+				return
+			}
 
-		bar.Describe(song.String())
-		client.ScrobbleNowPlaying(song.Meta)
+			isStarred := song.Starred
+			view.Reset(song)
+			hub.Publish(control.TrackStart, func(state *control.State) {
+				*state = nowPlaying(song, q.Name())
+			})
+
+			bar.Describe(song.String())
+			listen := scrobble.Listen{Song: song.Meta, StartedAt: time.Now()}
+			// Podcasts aren't music and radio isn't ours, so neither end up in
+			// anyone's listening history
+			scrobbling := !config.podcasts() && !song.Live
+			if scrobbling {
+				scrobbler.NowPlaying(listen)
+			}
 
-		for msg := range music.Play(song.LocalFile) {
-			if q.IsStarred(song) != isStarred {
-				bar.Describe(song.String())
+			if first {
+				first = false
+				wake.Ramp()
 			}
-			isStarred = q.IsStarred(song)
 
-			lastPercent = msg.PercentComplete
-			bar.Set(int(msg.PercentComplete))
-		}
+			var playing chan mpv.PlayNotification
+			if incoming != nil && incomingEntry == song {
+				decks.Swap()
+				playing = incoming
+			} else {
+				decks.Cancel(incoming)
+				switch {
+				case song.Live:
+					playing = decks.Active().PlayLive(song.LocalFile)
+				case song.Resume > 0:
+					fmt.Printf("=> Resuming at %s\n", song.Resume.Truncate(time.Second))
+					fallthrough
+				default:
+					playing = decks.Active().PlayFrom(song.LocalFile, song.Resume)
+				}
+			}
+			incoming = nil
+			incomingEntry = nil
+
+			lastSaved := time.Now()
+			for msg := range playing {
+				if q.IsStarred(song) != isStarred {
+					bar.Describe(song.String())
+				}
+				isStarred = q.IsStarred(song)
+
+				last = msg
+				paused := decks.Paused()
+				hub.Update(func(state *control.State) {
+					state.Position = msg.Position
+					state.Duration = msg.Duration
+				})
+				if status := hub.State().Status; paused != (status == control.Paused) {
+					hub.Publish(control.Pause, func(state *control.State) {
+						state.Status = control.Playing
+						if paused {
+							state.Status = control.Paused
+						}
+					})
+				}
+
+				if song.Live {
+					if msg.Title != song.Meta.Title {
+						song.Meta.Title = msg.Title
+						hub.Publish(control.TrackStart, func(state *control.State) {
+							state.Title = msg.Title
+						})
+						bar.Describe(song.String())
+					}
+					bar.Add(1)
+					continue
+				}
+				view.Update(bar, seconds(msg.Position))
+				bar.Set(int(msg.PercentComplete))
+
+				if song.Bookmark && time.Since(lastSaved) >= bookmarkInterval {
+					lastSaved = time.Now()
+					q.SaveBookmark(song, seconds(msg.Position), false)
+				}
+
+				if incoming == nil && !after.Last() && shouldCrossfade(config.Crossfade, msg, song, q.PeekNext()) {
+					incomingEntry = q.PeekNext()
+					incoming = decks.Crossfade(incomingEntry.LocalFile, config.Crossfade)
+					if incoming == nil {
+						incomingEntry = nil
+					}
+				}
+			}
+
+			if ctx.Err() != nil {
+				// Stopped part way through
+				return
+			}
+
+			listen.Played = seconds(last.Position)
+			listen.Length = seconds(last.Duration)
+			hub.Publish(control.TrackEnd, nil)
+			if scrobbling {
+				scrobbler.Submit(listen)
+				if scrobbler.Rule.Eligible(listen) {
+					hub.Publish(control.Scrobble, nil)
+				}
+			}
+
+			if song.Bookmark {
+				finished := last.Duration > 0 && last.Remaining() <= bookmarkTail.Seconds()
+				q.SaveBookmark(song, seconds(last.Position), finished)
+			}
 
-		if lastPercent >= 75 {
-			client.ScrobbleSubmit(song.Meta)
+			bar.Finish()
+
+			if details := song.Meta.Details(); details != "" && !song.Live {
+				fmt.Printf("=> %s - %s · %s\n", song.Meta.ArtistName(), song.Meta.Title, details)
+			} else {
+				fmt.Printf("=> %s - %s\n", song.Meta.ArtistName(), song.Meta.Title)
+			}
+			song.Remove()
+
+			if after.Played() {
+				decks.Cancel(incoming)
+				finish(nil)
+				return
+			}
 		}
+	}()
+
+	err = <-stopped
+	waitForHooks()
+	return err
+}
+
+// shouldCrossfade decides whether it's time to start fading into the next
+// entry. Tracks from the same album are left alone so gapless albums and
+// live sets play the way they were mastered.
+func shouldCrossfade(over time.Duration, msg mpv.PlayNotification, current *queue.Entry, next *queue.Entry) bool {
+	if over <= 0 || next == nil {
+		return false
+	}
+	if current.Live || next.Live {
+		return false
+	}
+	if !next.Ready() {
+		return false
+	}
+	if sameAlbum(current.Meta, next.Meta) {
+		return false
+	}
+	if next.Resume > 0 {
+		// Fading into the middle of something isn't a transition
+		return false
+	}
+
+	remaining := msg.Remaining()
+	return remaining > 0 && remaining <= over.Seconds()
+}
 
-		bar.Finish()
+// sameAlbum goes by album id when the server gives us one, since plenty of
+// different albums are called "Greatest Hits"
+func sameAlbum(a sonic.Song, b sonic.Song) bool {
+	if a.AlbumID != "" && b.AlbumID != "" {
+		return a.AlbumID == b.AlbumID
+	}
+	return a.Album != "" && a.Album == b.Album
+}
 
-		fmt.Printf("=> %s - %s\n", song.Meta.Artist, song.Meta.Title)
-		song.Remove()
+func seconds(secs float64) time.Duration {
+	return time.Duration(secs * float64(time.Second))
+}
+
+// nowPlaying describes an entry that's just started
+func nowPlaying(song *queue.Entry, playlist string) control.State {
+	return control.State{
+		Status:   control.Playing,
+		ID:       song.Meta.ID,
+		Title:    song.Meta.Title,
+		Artist:   song.Meta.ArtistName(),
+		Album:    song.Meta.Album,
+		Year:     song.Meta.Year,
+		Starred:  song.Starred,
+		Live:     song.Live,
+		Duration: float64(song.Meta.Duration),
+		Playlist: playlist,
+		Details:  song.Meta.Details(),
+		CoverArt: song.Meta.CoverArt,
 	}
+}
 
-	return nil
+// replayWhenOnline keeps an eye on the server while we're offline, sending
+// queued scrobbles and stars whenever it answers
+func replayWhenOnline(client *sonic.Sonic, lib *library.Library, scrobbler *scrobble.Manager) {
+	for {
+		time.Sleep(reconnectInterval)
+		if client.Ping() != nil {
+			continue
+		}
+
+		scrobbler.Flush()
+		lib.ReplayStars(client)
+	}
 }
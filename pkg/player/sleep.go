@@ -0,0 +1,161 @@
+package player
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/mpv"
+)
+
+const (
+	// The volume fades to nothing over the last stretch before sleeping
+	sleepFade = time.Minute
+
+	// How often the sleep timer checks the clock and adjusts the volume
+	sleepTick = 250 * time.Millisecond
+
+	// How much the sleep key adds to the timer
+	sleepStep = 15 * time.Minute
+)
+
+// sleepTimer stops playback at a set time, fading out over the final
+// minute. It's off while deadline is zero.
+type sleepTimer struct {
+	lock     sync.Mutex
+	deadline time.Time
+
+	// level is the volume from before the fade started, put back if the
+	// timer is pushed out or turned off mid fade
+	fading bool
+	level  float64
+}
+
+func newSleepTimer(after time.Duration) *sleepTimer {
+	timer := &sleepTimer{}
+	if after > 0 {
+		timer.deadline = time.Now().Add(after)
+	}
+	return timer
+}
+
+// Extend pushes the deadline out, starting the timer if it's off
+func (timer *sleepTimer) Extend(by time.Duration) {
+	timer.lock.Lock()
+	defer timer.lock.Unlock()
+
+	if timer.deadline.IsZero() {
+		timer.deadline = time.Now()
+	}
+	timer.deadline = timer.deadline.Add(by)
+	fmt.Printf("=> Sleeping in %s\n", time.Until(timer.deadline).Round(time.Second))
+}
+
+// Cancel turns the timer off
+func (timer *sleepTimer) Cancel() {
+	timer.lock.Lock()
+	defer timer.lock.Unlock()
+
+	if timer.deadline.IsZero() {
+		return
+	}
+	timer.deadline = time.Time{}
+	fmt.Println("=> Not sleeping")
+}
+
+// run watches the clock, fading decks out as the deadline gets close, and
+// calls sleep once it passes
+func (timer *sleepTimer) run(ctx context.Context, decks *mpv.Decks, sleep func()) {
+	ticker := time.NewTicker(sleepTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if timer.tick(decks) {
+			sleep()
+			return
+		}
+	}
+}
+
+// tick adjusts the volume for the time left and reports whether it's time
+// to sleep
+func (timer *sleepTimer) tick(decks *mpv.Decks) bool {
+	timer.lock.Lock()
+	defer timer.lock.Unlock()
+
+	left := time.Until(timer.deadline)
+	if timer.deadline.IsZero() || left > sleepFade {
+		if timer.fading {
+			timer.fading = false
+			decks.SetVolume(timer.level)
+		}
+		return false
+	}
+	if left <= 0 {
+		return true
+	}
+
+	if !timer.fading {
+		timer.fading = true
+		timer.level = decks.Volume()
+		if timer.level <= 0 {
+			timer.level = 100
+		}
+	}
+	// A crossfade is already busy with the volume, and it'll be back
+	// under our control by the next tick
+	if !decks.Fading() {
+		decks.SetVolume(timer.level * left.Seconds() / sleepFade.Seconds())
+	}
+	return false
+}
+
+// stopAfter counts down the tracks left to play before stopping. It's off
+// while left is zero.
+type stopAfter struct {
+	lock sync.Mutex
+	left int
+}
+
+// Toggle switches between stopping after the current track and not
+// stopping at all
+func (stop *stopAfter) Toggle() {
+	stop.lock.Lock()
+	defer stop.lock.Unlock()
+
+	if stop.left == 1 {
+		stop.left = 0
+		fmt.Println("=> Playing on")
+		return
+	}
+	stop.left = 1
+	fmt.Println("=> Stopping after this track")
+}
+
+// Last is true while the current track is the last one to play
+func (stop *stopAfter) Last() bool {
+	stop.lock.Lock()
+	defer stop.lock.Unlock()
+	return stop.left == 1
+}
+
+// Played counts off a finished track and reports whether that was the last
+func (stop *stopAfter) Played() bool {
+	stop.lock.Lock()
+	defer stop.lock.Unlock()
+
+	if stop.left == 0 {
+		return false
+	}
+	stop.left--
+	return stop.left == 0
+}
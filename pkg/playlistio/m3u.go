@@ -0,0 +1,89 @@
+package playlistio
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+func encodeM3U8(w io.Writer, doc Document) error {
+	out := bufio.NewWriter(w)
+
+	fmt.Fprintln(out, "#EXTM3U")
+	if doc.Name != "" {
+		fmt.Fprintf(out, "#PLAYLIST:%s\n", doc.Name)
+	}
+
+	for _, entry := range doc.Entries {
+		duration := entry.Duration
+		if duration <= 0 {
+			duration = -1
+		}
+		fmt.Fprintf(out, "#EXTINF:%d,%s\n", duration, entry.String())
+		if entry.Album != "" {
+			fmt.Fprintf(out, "#EXTALB:%s\n", entry.Album)
+		}
+		fmt.Fprintln(out, entry.Location)
+	}
+
+	return out.Flush()
+}
+
+// decodeM3U8 reads extended and plain M3U. The artist and title come from
+// #EXTINF's "Artist - Title" convention, when there is one.
+func decodeM3U8(r io.Reader) (Document, error) {
+	var (
+		doc     Document
+		pending Entry
+	)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+
+		switch {
+		case line == "" || line == "#EXTM3U":
+
+		case strings.HasPrefix(line, "#PLAYLIST:"):
+			doc.Name = strings.TrimSpace(strings.TrimPrefix(line, "#PLAYLIST:"))
+
+		case strings.HasPrefix(line, "#EXTINF:"):
+			info := strings.TrimPrefix(line, "#EXTINF:")
+			length, title, _ := strings.Cut(info, ",")
+			// Attributes like tvg-id="..." can follow the duration
+			length, _, _ = strings.Cut(length, " ")
+			if secs, err := strconv.Atoi(strings.TrimSpace(length)); err == nil && secs > 0 {
+				pending.Duration = secs
+			}
+
+			title = strings.TrimSpace(title)
+			if artist, track, ok := strings.Cut(title, " - "); ok {
+				pending.Artist = strings.TrimSpace(artist)
+				pending.Title = strings.TrimSpace(track)
+			} else {
+				pending.Title = title
+			}
+
+		case strings.HasPrefix(line, "#EXTALB:"):
+			pending.Album = strings.TrimSpace(strings.TrimPrefix(line, "#EXTALB:"))
+
+		case strings.HasPrefix(line, "#EXTART:"):
+			pending.Artist = strings.TrimSpace(strings.TrimPrefix(line, "#EXTART:"))
+
+		case strings.HasPrefix(line, "#"):
+			// Some other directive or a comment
+
+		default:
+			pending.Location = line
+			doc.Entries = append(doc.Entries, pending)
+			pending = Entry{}
+		}
+	}
+
+	return doc, scanner.Err()
+}
@@ -0,0 +1,108 @@
+package playlistio
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+// Reading and writing playlists in formats other players understand: M3U8,
+// XSPF and a plain JSON dump.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/sonic"
+)
+
+const (
+	FormatM3U8 = "m3u8"
+	FormatXSPF = "xspf"
+	FormatJSON = "json"
+)
+
+var Formats = []string{FormatM3U8, FormatXSPF, FormatJSON}
+
+type (
+	// Entry is one line of a playlist. Location is a stream url or a local
+	// file. ID is only known for playlists we wrote ourselves.
+	Entry struct {
+		ID       string `json:"id,omitempty"`
+		Artist   string `json:"artist,omitempty"`
+		Title    string `json:"title,omitempty"`
+		Album    string `json:"album,omitempty"`
+		Track    int    `json:"track,omitempty"`
+		Duration int    `json:"duration,omitempty"`
+		Location string `json:"location,omitempty"`
+	}
+
+	Document struct {
+		Name    string  `json:"name"`
+		Entries []Entry `json:"entries"`
+	}
+)
+
+// EntryFor describes a song, playable from the given location
+func EntryFor(song sonic.Song, location string) Entry {
+	return Entry{
+		ID:       song.ID,
+		Artist:   song.ArtistName(),
+		Title:    song.Title,
+		Album:    song.Album,
+		Track:    song.Track,
+		Duration: song.Duration,
+		Location: location,
+	}
+}
+
+func (entry Entry) String() string {
+	switch {
+	case entry.Artist != "" && entry.Title != "":
+		return fmt.Sprintf("%s - %s", entry.Artist, entry.Title)
+	case entry.Title != "":
+		return entry.Title
+	}
+	return entry.Location
+}
+
+// FormatFor guesses the format from a file name
+func FormatFor(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".m3u8", ".m3u":
+		return FormatM3U8, nil
+	case ".xspf":
+		return FormatXSPF, nil
+	case ".json":
+		return FormatJSON, nil
+	}
+	return "", fmt.Errorf("can't tell the format of '%s', use one of %v", path, Formats)
+}
+
+func Encode(w io.Writer, format string, doc Document) error {
+	switch format {
+	case FormatM3U8:
+		return encodeM3U8(w, doc)
+	case FormatXSPF:
+		return encodeXSPF(w, doc)
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(doc)
+	}
+	return fmt.Errorf("unknown format '%s'", format)
+}
+
+func Decode(r io.Reader, format string) (Document, error) {
+	switch format {
+	case FormatM3U8:
+		return decodeM3U8(r)
+	case FormatXSPF:
+		return decodeXSPF(r)
+	case FormatJSON:
+		var doc Document
+		err := json.NewDecoder(r).Decode(&doc)
+		return doc, err
+	}
+	return Document{}, fmt.Errorf("unknown format '%s'", format)
+}
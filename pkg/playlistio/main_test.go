@@ -0,0 +1,147 @@
+package playlistio
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testDoc = Document{
+	Name: "Late Night",
+	Entries: []Entry{
+		{
+			ID: "s1", Artist: "Aphex Twin", Title: "Windowlicker", Album: "Windowlicker", Track: 1, Duration: 367,
+			Location: "Aphex Twin/Windowlicker/01 Windowlicker.mp3",
+		},
+		{
+			ID: "s2", Artist: "Queen & David Bowie", Title: "Under Pressure", Album: "Hot Space", Track: 11, Duration: 248,
+			Location: filepath.FromSlash("/home/sungo/My Music/Queen/Hot Space/11 Under Pressure #1?.flac"),
+		},
+		{
+			ID: "s3", Artist: "Sigur Rós", Title: "Hoppípolla", Album: "Takk...", Track: 2, Duration: 268,
+			Location: "https://music.example/rest/stream?id=s3&u=sungo&t=abc&s=def",
+		},
+		{
+			ID: "s4", Artist: "Boards of Canada", Title: "Roygbiv", Duration: 151,
+			Location: "Boards of Canada: Live/Roygbiv.mp3",
+		},
+	},
+}
+
+func TestRoundTrip(t *testing.T) {
+	// M3U has nowhere to keep ids and track numbers
+	plain := Document{Name: testDoc.Name}
+	for _, entry := range testDoc.Entries {
+		entry.ID = ""
+		entry.Track = 0
+		plain.Entries = append(plain.Entries, entry)
+	}
+
+	tests := map[string]Document{
+		FormatM3U8: plain,
+		FormatXSPF: testDoc,
+		FormatJSON: testDoc,
+	}
+	for format, want := range tests {
+		var buf bytes.Buffer
+		if err := Encode(&buf, format, testDoc); err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+		got, err := Decode(&buf, format)
+		if err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s came back as\n%+v\nwant\n%+v", format, got, want)
+		}
+	}
+}
+
+func TestXSPFLocations(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, FormatXSPF, testDoc); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"<location>Aphex%20Twin/Windowlicker/01%20Windowlicker.mp3</location>",
+		"<location>https://music.example/rest/stream?id=s3&amp;u=sungo&amp;t=abc&amp;s=def</location>",
+		"<location>./Boards%20of%20Canada:%20Live/Roygbiv.mp3</location>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %s in\n%s", want, out)
+		}
+	}
+	if filepath.Separator == '/' && !strings.Contains(out, "<location>file:///home/sungo/My%20Music/Queen/Hot%20Space/11%20Under%20Pressure%20%231%3F.flac</location>") {
+		t.Errorf("local file isn't a file:// url in\n%s", out)
+	}
+}
+
+// Locations other players write, valid URIs or not
+func TestXSPFForeignLocations(t *testing.T) {
+	tests := map[string]string{
+		"file:///home/sungo/Music/a%20b.mp3":      filepath.FromSlash("/home/sungo/Music/a b.mp3"),
+		"file://localhost/home/sungo/Music/a.mp3": filepath.FromSlash("/home/sungo/Music/a.mp3"),
+		"Music/a%20b.mp3":                         filepath.FromSlash("Music/a b.mp3"),
+		"http://radio.example/stream.mp3":         "http://radio.example/stream.mp3",
+		"Music/100% pure.mp3":                     "Music/100% pure.mp3",
+	}
+	for location, want := range tests {
+		if got := locationPath(location); got != want {
+			t.Errorf("%s is %s, want %s", location, got, want)
+		}
+	}
+}
+
+func TestDecodeM3U8(t *testing.T) {
+	input := "\ufeff#EXTM3U\n" +
+		"#PLAYLIST:Mixed\n" +
+		"#EXTINF:367 tvg-id=\"x\",Aphex Twin - Windowlicker\n" +
+		"#EXTALB:Windowlicker\n" +
+		"Aphex Twin/Windowlicker/01 Windowlicker.mp3\n" +
+		"\n" +
+		"# a comment\n" +
+		"#EXTINF:-1,Just A Title\n" +
+		"#EXTART:Someone\n" +
+		"http://radio.example/stream\n" +
+		"bare/file.mp3\n"
+
+	got, err := Decode(strings.NewReader(input), FormatM3U8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Document{
+		Name: "Mixed",
+		Entries: []Entry{
+			{Artist: "Aphex Twin", Title: "Windowlicker", Album: "Windowlicker", Duration: 367, Location: "Aphex Twin/Windowlicker/01 Windowlicker.mp3"},
+			{Artist: "Someone", Title: "Just A Title", Location: "http://radio.example/stream"},
+			{Location: "bare/file.mp3"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestFormatFor(t *testing.T) {
+	tests := map[string]string{
+		"a.m3u8":        FormatM3U8,
+		"a.M3U":         FormatM3U8,
+		"dir/a.xspf":    FormatXSPF,
+		"a.backup.json": FormatJSON,
+	}
+	for name, want := range tests {
+		if got, err := FormatFor(name); err != nil || got != want {
+			t.Errorf("%s is %s (%v), want %s", name, got, err, want)
+		}
+	}
+	if _, err := FormatFor("a.pls"); err == nil {
+		t.Error("a.pls has a format")
+	}
+}
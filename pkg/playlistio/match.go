@@ -0,0 +1,151 @@
+package playlistio
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/library"
+	"git.sr.ht/~sungo/hedgehog/pkg/sonic"
+)
+
+const searchCount = 20
+
+// Matcher finds the server's copy of playlist entries. Library is optional
+// and lets files from 'hedgehog sync' be recognized.
+type Matcher struct {
+	Client  *sonic.Sonic
+	Library *library.Library
+}
+
+// Match tries, in order: an id we wrote ourselves, a stream url with an id
+// in it, a synced library file, the server's path for the file, and
+// finally a search on artist, title and album
+func (matcher Matcher) Match(entry Entry) (sonic.Song, bool) {
+	if entry.ID != "" {
+		if song, err := matcher.Client.GetSong(entry.ID); err == nil {
+			return song, true
+		}
+	}
+
+	if parsed, err := url.Parse(entry.Location); err == nil && parsed.Scheme != "" && parsed.Query().Get("id") != "" {
+		if song, err := matcher.Client.GetSong(parsed.Query().Get("id")); err == nil {
+			return song, true
+		}
+	}
+
+	if matcher.Library != nil && entry.Location != "" {
+		if song, ok := matcher.Library.SongForFile(entry.Location); ok {
+			return song, true
+		}
+	}
+
+	if song, ok := matcher.byPath(entry); ok {
+		return song, true
+	}
+
+	return matcher.bySearch(entry)
+}
+
+// byPath searches on the file name and looks for a song whose path on the
+// server matches the end of the entry's location
+func (matcher Matcher) byPath(entry Entry) (sonic.Song, bool) {
+	location := filepath.ToSlash(entry.Location)
+	if location == "" || strings.Contains(location, "://") {
+		return sonic.Song{}, false
+	}
+
+	query := entry.Title
+	if query == "" {
+		base := path.Base(location)
+		query = strings.TrimSuffix(base, path.Ext(base))
+	}
+
+	songs, err := matcher.Client.SearchSongs(query, searchCount, 0)
+	if err != nil {
+		return sonic.Song{}, false
+	}
+
+	for _, song := range songs {
+		if song.Path == "" {
+			continue
+		}
+		if strings.HasSuffix(location, "/"+song.Path) || location == song.Path {
+			return song, true
+		}
+	}
+	return sonic.Song{}, false
+}
+
+// bySearch looks for a song with the same title, preferring ones that also
+// match on artist and album. If the entry names an artist, it has to match.
+func (matcher Matcher) bySearch(entry Entry) (sonic.Song, bool) {
+	if entry.Title == "" {
+		return sonic.Song{}, false
+	}
+
+	query := entry.Title
+	if entry.Artist != "" {
+		query = entry.Artist + " " + entry.Title
+	}
+
+	songs, err := matcher.Client.SearchSongs(query, searchCount, 0)
+	if err != nil {
+		return sonic.Song{}, false
+	}
+	if len(songs) == 0 && entry.Artist != "" {
+		// Some servers don't search across fields
+		songs, err = matcher.Client.SearchSongs(entry.Title, searchCount, 0)
+		if err != nil {
+			return sonic.Song{}, false
+		}
+	}
+
+	var (
+		best      sonic.Song
+		bestScore = 0
+	)
+	for _, song := range songs {
+		if !same(song.Title, entry.Title) {
+			continue
+		}
+		// Exports name every artist on the track, as ArtistName does.
+		// Other players tend to write just the main one.
+		if entry.Artist != "" && !same(song.ArtistName(), entry.Artist) && !same(song.Artist, entry.Artist) {
+			continue
+		}
+
+		score := 1
+		if entry.Album != "" && same(song.Album, entry.Album) {
+			score += 2
+		}
+		if entry.Duration > 0 && song.Duration > 0 && abs(entry.Duration-song.Duration) <= 2 {
+			score++
+		}
+
+		if score > bestScore {
+			best = song
+			bestScore = score
+		}
+	}
+
+	return best, bestScore > 0
+}
+
+func same(a string, b string) bool {
+	return strings.EqualFold(
+		strings.Join(strings.Fields(a), " "),
+		strings.Join(strings.Fields(b), " "),
+	)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
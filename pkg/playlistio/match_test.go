@@ -0,0 +1,129 @@
+package playlistio
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/sonic"
+)
+
+var serverSongs = []sonic.Song{
+	{ID: "s1", Artist: "Aphex Twin", Title: "Windowlicker", Album: "Windowlicker", Duration: 367, Path: "Aphex Twin/Windowlicker/01 Windowlicker.mp3"},
+	{ID: "s2", Artist: "Aphex Twin", Title: "Windowlicker", Album: "Live", Duration: 402, Path: "Aphex Twin/Live/05 Windowlicker.mp3"},
+	{ID: "s3", Artist: "Queen", DisplayArtist: "Queen & David Bowie", Title: "Under Pressure", Album: "Hot Space", Duration: 248, Path: "Queen/Hot Space/11 Under Pressure.flac"},
+	{ID: "s4", Artists: []sonic.ArtistRef{{Name: "Vanilla Ice"}, {Name: "Queen"}}, Title: "Under Pressure", Album: "To the Extreme", Duration: 250},
+}
+
+// fakeServer answers getSong by id and search3 with every song whose title
+// is somewhere in the query
+func fakeServer(t *testing.T) *sonic.Sonic {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		w.Header().Set("Content-Type", "application/json")
+
+		body := map[string]any{"status": "ok", "version": "1.16.1"}
+		switch r.URL.Path {
+		case "/rest/getSong":
+			body["status"] = "failed"
+			body["error"] = map[string]any{"code": 70, "message": "not found"}
+			for _, song := range serverSongs {
+				if song.ID == r.Form.Get("id") {
+					body["status"] = "ok"
+					delete(body, "error")
+					body["song"] = song
+				}
+			}
+		case "/rest/search3":
+			query := strings.ToLower(r.Form.Get("query"))
+			found := []sonic.Song{}
+			for _, song := range serverSongs {
+				if strings.Contains(query, strings.ToLower(song.Title)) {
+					found = append(found, song)
+				}
+			}
+			body["searchResult3"] = map[string]any{"song": found}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"subsonic-response": body})
+	}))
+	t.Cleanup(server.Close)
+
+	client := sonic.New(sonic.Auth{}, server.URL)
+	return &client
+}
+
+func TestMatch(t *testing.T) {
+	matcher := Matcher{Client: fakeServer(t)}
+
+	tests := []struct {
+		name  string
+		entry Entry
+		want  string
+	}{
+		{"id", Entry{ID: "s2", Title: "Something Else"}, "s2"},
+		{"stream url", Entry{Location: "https://music.example/rest/stream?id=s3&u=sungo"}, "s3"},
+		{"unknown id", Entry{ID: "gone", Artist: "Aphex Twin", Title: "Windowlicker"}, "s1"},
+		{"path", Entry{Location: filepath.FromSlash("/home/sungo/Music/Aphex Twin/Live/05 Windowlicker.mp3")}, "s2"},
+		{"artist and title", Entry{Artist: "aphex  twin", Title: "windowlicker"}, "s1"},
+		{"album", Entry{Artist: "Aphex Twin", Title: "Windowlicker", Album: "Live"}, "s2"},
+		{"duration", Entry{Title: "Windowlicker", Duration: 401}, "s2"},
+		{"display artist", Entry{Artist: "Queen & David Bowie", Title: "Under Pressure"}, "s3"},
+		{"main artist", Entry{Artist: "Queen", Title: "Under Pressure"}, "s3"},
+		{"joined artists", Entry{Artist: "Vanilla Ice, Queen", Title: "Under Pressure"}, "s4"},
+		{"wrong artist", Entry{Artist: "Nobody", Title: "Under Pressure"}, ""},
+		{"unknown title", Entry{Artist: "Queen", Title: "Bohemian Rhapsody"}, ""},
+		{"nothing to go on", Entry{Location: "https://radio.example/stream"}, ""},
+	}
+
+	for _, test := range tests {
+		song, ok := matcher.Match(test.entry)
+		if test.want == "" {
+			if ok {
+				t.Errorf("%s: matched %s", test.name, song.ID)
+			}
+			continue
+		}
+		if !ok || song.ID != test.want {
+			t.Errorf("%s: got %q (%v), want %s", test.name, song.ID, ok, test.want)
+		}
+	}
+}
+
+// What we export, we can import again, even without the ids
+func TestExportImport(t *testing.T) {
+	matcher := Matcher{Client: fakeServer(t)}
+
+	doc := Document{Name: "mix"}
+	for _, song := range serverSongs {
+		entry := EntryFor(song, song.Path)
+		entry.ID = ""
+		doc.Entries = append(doc.Entries, entry)
+	}
+
+	for _, format := range []string{FormatM3U8, FormatXSPF, FormatJSON} {
+		var buf bytes.Buffer
+		if err := Encode(&buf, format, doc); err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+		back, err := Decode(&buf, format)
+		if err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+		for i, entry := range back.Entries {
+			song, ok := matcher.Match(entry)
+			if !ok || song.ID != serverSongs[i].ID {
+				t.Errorf("%s: %+v matched %q (%v), want %s", format, entry, song.ID, ok, serverSongs[i].ID)
+			}
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package playlistio
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"encoding/xml"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	xspfNamespace = "http://xspf.org/ns/0/"
+	// identifiers we write look like hedgehog:song:<id>
+	xspfIdentifierPrefix = "hedgehog:song:"
+)
+
+type (
+	xspfPlaylist struct {
+		XMLName xml.Name    `xml:"playlist"`
+		Version string      `xml:"version,attr"`
+		XMLNS   string      `xml:"xmlns,attr"`
+		Title   string      `xml:"title,omitempty"`
+		Tracks  []xspfTrack `xml:"trackList>track"`
+	}
+
+	xspfTrack struct {
+		Location   []string `xml:"location,omitempty"`
+		Identifier []string `xml:"identifier,omitempty"`
+		Title      string   `xml:"title,omitempty"`
+		Creator    string   `xml:"creator,omitempty"`
+		Album      string   `xml:"album,omitempty"`
+		TrackNum   int      `xml:"trackNum,omitempty"`
+		// Duration is in milliseconds
+		Duration int `xml:"duration,omitempty"`
+	}
+)
+
+func encodeXSPF(w io.Writer, doc Document) error {
+	playlist := xspfPlaylist{
+		Version: "1",
+		XMLNS:   xspfNamespace,
+		Title:   doc.Name,
+	}
+
+	for _, entry := range doc.Entries {
+		track := xspfTrack{
+			Title:    entry.Title,
+			Creator:  entry.Artist,
+			Album:    entry.Album,
+			TrackNum: entry.Track,
+			Duration: entry.Duration * 1000,
+		}
+		if entry.Location != "" {
+			track.Location = []string{locationURI(entry.Location)}
+		}
+		if entry.ID != "" {
+			track.Identifier = []string{xspfIdentifierPrefix + entry.ID}
+		}
+		playlist.Tracks = append(playlist.Tracks, track)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(playlist); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func decodeXSPF(r io.Reader) (Document, error) {
+	var playlist xspfPlaylist
+	if err := xml.NewDecoder(r).Decode(&playlist); err != nil {
+		return Document{}, err
+	}
+
+	doc := Document{Name: playlist.Title}
+	for _, track := range playlist.Tracks {
+		entry := Entry{
+			Artist:   track.Creator,
+			Title:    track.Title,
+			Album:    track.Album,
+			Track:    track.TrackNum,
+			Duration: track.Duration / 1000,
+		}
+		if len(track.Location) > 0 {
+			entry.Location = locationPath(track.Location[0])
+		}
+		for _, id := range track.Identifier {
+			if strings.HasPrefix(id, xspfIdentifierPrefix) {
+				entry.ID = strings.TrimPrefix(id, xspfIdentifierPrefix)
+			}
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+
+	return doc, nil
+}
+
+// locationURI turns a location into the URI that XSPF wants. Absolute
+// paths become file:// urls, relative ones (like the server's path for a
+// song) stay relative, and urls are left alone.
+func locationURI(location string) string {
+	if parsed, err := url.Parse(location); err == nil && len(parsed.Scheme) > 1 {
+		return location
+	}
+
+	path := filepath.ToSlash(location)
+	if filepath.IsAbs(location) {
+		if !strings.HasPrefix(path, "/") {
+			// A windows drive, like C:/Music
+			path = "/" + path
+		}
+		return (&url.URL{Scheme: "file", Path: path}).String()
+	}
+	return (&url.URL{Path: path}).String()
+}
+
+// locationPath undoes locationURI, leaving urls other than file:// ones as
+// they are. Locations that aren't valid URIs, as some players write, are
+// taken as plain paths.
+func locationPath(location string) string {
+	parsed, err := url.Parse(location)
+	switch {
+	case err != nil:
+		return location
+	case parsed.Scheme == "file":
+		path := parsed.Path
+		if len(path) > 2 && path[0] == '/' && path[2] == ':' {
+			// file:///C:/Music
+			path = path[1:]
+		}
+		return filepath.FromSlash(path)
+	case parsed.Scheme == "" && parsed.Host == "":
+		return filepath.FromSlash(strings.TrimPrefix(parsed.Path, "./"))
+	}
+	return location
+}
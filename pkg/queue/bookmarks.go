@@ -0,0 +1,172 @@
+package queue
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+// Bookmarks keep the place in long entries, like podcast episodes and DJ
+// sets. They're fetched when the queue starts and every so often after,
+// since other clients can move them, and saved in the background so a slow
+// server doesn't hold up playback.
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/sonic"
+)
+
+const (
+	// How often to look for bookmarks that other clients moved
+	bookmarkRefresh = 10 * time.Minute
+
+	// How many saves can wait on a slow server before progress updates
+	// are skipped. Clearing a finished entry's bookmark waits for room.
+	bookmarkBacklog = 8
+)
+
+type bookmarkSave struct {
+	id       string
+	position time.Duration
+	finished bool
+}
+
+// bookmarker sends saves to the server one at a time, in order
+type bookmarker struct {
+	sync.Mutex
+	saves  chan bookmarkSave
+	done   chan struct{}
+	closed bool
+}
+
+func (queue *Queue) UpdateBookmarks() {
+	if (!queue.Bookmarks && queue.BookmarkOver <= 0) || queue.Offline {
+		return
+	}
+
+	bookmarks, err := queue.Client.GetBookmarks()
+	if err != nil {
+		slog.Warn("fetching bookmarks failed", "err", err)
+		return
+	}
+
+	resume := make(map[string]time.Duration, len(bookmarks))
+	for id, bookmark := range bookmarks {
+		resume[id] = bookmark.Offset()
+	}
+
+	queue.lock.Lock()
+	queue.resume = resume
+	queue.bookmarksAt = time.Now()
+	queue.lock.Unlock()
+}
+
+// refreshBookmarks fetches the bookmarks if it's been a while. In between,
+// the queue goes by what it saved itself.
+func (queue *Queue) refreshBookmarks() {
+	queue.lock.Lock()
+	stale := time.Since(queue.bookmarksAt) >= bookmarkRefresh
+	queue.lock.Unlock()
+
+	if stale {
+		queue.UpdateBookmarks()
+	}
+}
+
+// SaveBookmark records how far into an entry we got. A finished entry
+// loses its bookmark and starts from the top next time. The entry is
+// updated straight away, and the server hears about it in the background.
+func (queue *Queue) SaveBookmark(entry *Entry, position time.Duration, finished bool) {
+	if !entry.Bookmark || queue.Offline {
+		return
+	}
+
+	if finished {
+		position = 0
+	}
+	entry.Resume = position
+
+	queue.lock.Lock()
+	if queue.resume == nil {
+		queue.resume = make(map[string]time.Duration)
+	}
+	if finished {
+		delete(queue.resume, entry.Meta.ID)
+	} else {
+		queue.resume[entry.Meta.ID] = position
+	}
+	queue.lock.Unlock()
+
+	queue.bookmarker.send(queue.Client, bookmarkSave{
+		id:       entry.Meta.ID,
+		position: position,
+		finished: finished,
+	})
+}
+
+// wantsBookmark is whether a song's position should be kept
+func (queue *Queue) wantsBookmark(song sonic.Song) bool {
+	if queue.Bookmarks {
+		return true
+	}
+	length := time.Duration(song.Duration) * time.Second
+	return queue.BookmarkOver > 0 && length >= queue.BookmarkOver
+}
+
+// Close waits for bookmarks that are still on their way to the server.
+// Anything saved after is dropped.
+func (queue *Queue) Close() {
+	saver := &queue.bookmarker
+	saver.Lock()
+	saver.closed = true
+	if saver.saves != nil {
+		close(saver.saves)
+	}
+	done := saver.done
+	saver.Unlock()
+
+	if done != nil {
+		<-done
+	}
+}
+
+func (saver *bookmarker) send(client *sonic.Sonic, save bookmarkSave) {
+	saver.Lock()
+	defer saver.Unlock()
+
+	if saver.closed {
+		slog.Warn("bookmark not saved, the player is on its way out", "song", save.id)
+		return
+	}
+	if saver.saves == nil {
+		saver.saves = make(chan bookmarkSave, bookmarkBacklog)
+		saver.done = make(chan struct{})
+		go saver.run(client)
+	}
+
+	if save.finished {
+		saver.saves <- save
+		return
+	}
+	select {
+	case saver.saves <- save:
+	default:
+		slog.Debug("the server is behind on bookmarks, skipping one", "song", save.id)
+	}
+}
+
+func (saver *bookmarker) run(client *sonic.Sonic) {
+	defer close(saver.done)
+
+	for save := range saver.saves {
+		var err error
+		if save.finished {
+			err = client.DeleteBookmark(save.id)
+		} else {
+			err = client.CreateBookmark(save.id, save.position, "")
+		}
+		if err != nil {
+			slog.Warn("saving bookmark failed", "song", save.id, "finished", save.finished, "err", err)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package queue
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/sonic"
+)
+
+// bookmarkServer keeps track of the bookmark calls made to it
+type bookmarkServer struct {
+	lock    sync.Mutex
+	fetches int
+	calls   []string
+}
+
+func (server *bookmarkServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	server.lock.Lock()
+	switch r.URL.Path {
+	case "/rest/getBookmarks":
+		server.fetches++
+	case "/rest/createBookmark":
+		server.calls = append(server.calls, "create "+r.PostForm.Get("id")+" "+r.PostForm.Get("position"))
+	case "/rest/deleteBookmark":
+		server.calls = append(server.calls, "delete "+r.PostForm.Get("id"))
+	}
+	server.lock.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Path == "/rest/getBookmarks" {
+		w.Write([]byte(`{"subsonic-response":{"status":"ok","version":"1.16.1","bookmarks":{"bookmark":{"position":90000,"entry":{"id":"set"}}}}}`))
+		return
+	}
+	w.Write([]byte(`{"subsonic-response":{"status":"ok","version":"1.16.1"}}`))
+}
+
+func TestSaveBookmark(t *testing.T) {
+	fake := &bookmarkServer{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	client := sonic.New(sonic.Auth{}, server.URL)
+
+	q := New()
+	q.Client = &client
+	q.BookmarkOver = 20 * time.Minute
+
+	// Fetched once, then left alone until it's been a while
+	for idx := 0; idx < 3; idx++ {
+		q.refreshBookmarks()
+	}
+	if fake.fetches != 1 {
+		t.Errorf("fetched bookmarks %d times, want 1", fake.fetches)
+	}
+	if got := q.resume["set"]; got != 90*time.Second {
+		t.Errorf("resuming at %s, want 1m30s", got)
+	}
+
+	entry := &Entry{Meta: sonic.Song{ID: "set", Duration: 3600}, Bookmark: true}
+	q.SaveBookmark(entry, 2*time.Minute, false)
+	if entry.Resume != 2*time.Minute || q.resume["set"] != 2*time.Minute {
+		t.Errorf("resuming at %s (queue says %s), want 2m", entry.Resume, q.resume["set"])
+	}
+	q.SaveBookmark(entry, 3*time.Minute, false)
+	q.SaveBookmark(entry, 59*time.Minute, true)
+	if entry.Resume != 0 {
+		t.Errorf("resuming a finished entry at %s", entry.Resume)
+	}
+	if _, ok := q.resume["set"]; ok {
+		t.Error("a finished entry still has a bookmark")
+	}
+
+	// Songs that don't want one are left alone
+	q.SaveBookmark(&Entry{Meta: sonic.Song{ID: "short", Duration: 200}}, time.Minute, false)
+
+	q.Close()
+	want := []string{"create set 120000", "create set 180000", "delete set"}
+	if !reflect.DeepEqual(fake.calls, want) {
+		t.Errorf("server got %v, want %v", fake.calls, want)
+	}
+
+	// Once closed, nothing more goes out
+	q.SaveBookmark(entry, time.Minute, false)
+	if len(fake.calls) != len(want) {
+		t.Errorf("saved after closing: %v", fake.calls)
+	}
+}
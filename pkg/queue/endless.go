@@ -0,0 +1,112 @@
+package queue
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+// Endless mode keeps the queue going after the playlist runs out, with
+// songs like the one that's playing: a mix of similar artists from
+// getSimilarSongs2, then the artist's own top songs. Nothing queued once
+// comes back for the rest of the session.
+
+import (
+	"log/slog"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/sonic"
+)
+
+const (
+	// How many songs each top up adds
+	endlessBatch = 10
+
+	// How many to ask the server for, so there's some left over after
+	// skipping the ones we've had
+	endlessAsk = 50
+)
+
+// TopUp adds songs like seed to the end of the queue and returns how many
+// it found
+func (queue *Queue) TopUp(seed sonic.Song) int {
+	if queue.Client == nil || queue.Offline {
+		return 0
+	}
+
+	var candidates sonic.Songs
+	if seed.ArtistID != "" {
+		similar, err := queue.Client.GetSimilarSongs2(seed.ArtistID, endlessAsk)
+		if err != nil {
+			slog.Warn("finding similar songs failed", "artist", seed.ArtistID, "err", err)
+		}
+		candidates = append(candidates, similar...)
+	}
+	if artist := seed.ArtistName(); artist != "" {
+		top, err := queue.Client.GetTopSongs(artist, endlessAsk)
+		if err != nil {
+			slog.Warn("finding top songs failed", "artist", artist, "err", err)
+		}
+		candidates = append(candidates, top...)
+	}
+
+	// queue.songs can share its backing array with the playlist
+	songs := append(sonic.Songs{}, queue.songs...)
+	added := 0
+	for _, song := range candidates {
+		if added == endlessBatch {
+			break
+		}
+		if song.ID == "" || song.ID == seed.ID || song.IsVideo || queue.heard[song.ID] {
+			continue
+		}
+		queue.remember(song)
+		songs = append(songs, song)
+		added++
+	}
+	queue.songs = songs
+	slog.Info("topped up", "seed", seed.ID, "candidates", len(candidates), "added", added)
+	return added
+}
+
+// StartRadio turns on endless mode from the entry that's playing, swapping
+// whatever was lined up next for songs like it, apart from anything
+// enqueued. It's false if nothing like it turned up, in which case the
+// queue is left alone.
+func (queue *Queue) StartRadio() bool {
+	queue.moving.Lock()
+	defer queue.moving.Unlock()
+
+	playing := queue.Current()
+	if playing == nil || playing.Live || queue.Offline {
+		return false
+	}
+
+	upcoming := queue.songs
+	queue.songs = nil
+	if queue.TopUp(playing.Meta) == 0 {
+		queue.songs = upcoming
+		return false
+	}
+
+	queue.Endless = true
+	queue.lock.Lock()
+	// Songs asked for with Enqueue still play first, with the radio's
+	// picks lined up behind them
+	requested := make(entryList, 0, len(queue.upNext))
+	for _, entry := range queue.upNext {
+		if entry.Requested {
+			requested = append(requested, entry)
+		} else {
+			entry.Remove()
+		}
+	}
+	queue.upNext = requested
+	queue.lock.Unlock()
+	queue.lineUp()
+	return true
+}
+
+// remember notes that a song has been queued this session
+func (queue *Queue) remember(song sonic.Song) {
+	if queue.heard == nil {
+		queue.heard = make(map[string]bool)
+	}
+	queue.heard[song.ID] = true
+}
@@ -0,0 +1,79 @@
+package queue
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/sonic"
+)
+
+type fixed sonic.Playlist
+
+func (src fixed) Load() (sonic.Playlist, error) {
+	return sonic.Playlist(src), nil
+}
+
+func TestStartRadioKeepsRequested(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/rest/getSimilarSongs2":
+			w.Write([]byte(`{"subsonic-response":{"status":"ok","version":"1.16.1","similarSongs2":{"song":[` +
+				`{"id":"x1","artistId":"a2"},{"id":"p3","artistId":"a1"},{"id":"x2","artistId":"a2"},{"id":"x3","artistId":"a2"}]}}}`))
+		case "/rest/getTopSongs":
+			w.Write([]byte(`{"subsonic-response":{"status":"ok","version":"1.16.1","topSongs":{}}}`))
+		case "/rest/download":
+			w.Write([]byte("not really audio"))
+		default:
+			w.Write([]byte(`{"subsonic-response":{"status":"ok","version":"1.16.1","starred":""}}`))
+		}
+	}))
+	defer server.Close()
+	client := sonic.New(sonic.Auth{}, server.URL)
+
+	playlist := sonic.Playlist{Name: "mix"}
+	for _, id := range []string{"p1", "p2", "p3", "p4", "p5"} {
+		playlist.Songs = append(playlist.Songs, sonic.Song{ID: id, ArtistID: "a1"})
+	}
+
+	q := New()
+	q.Client = &client
+	q.Depth = 2
+	q.TempDir = t.TempDir()
+	q.Source = fixed(playlist)
+	if err := q.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.CleanUp()
+
+	if playing := q.WhatsNext(); playing == nil || playing.Meta.ID != "p1" {
+		t.Fatalf("playing %v", playing)
+	}
+	for _, id := range []string{"r1", "r2"} {
+		if err := q.Enqueue(sonic.Song{ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !q.StartRadio() {
+		t.Fatal("no radio")
+	}
+
+	var got []string
+	for idx := 0; idx < 5; idx++ {
+		entry := q.WhatsNext()
+		if entry == nil {
+			break
+		}
+		got = append(got, entry.Meta.ID)
+	}
+	// p2 and p3 were lined up already, so they've been heard
+	want := []string{"r1", "r2", "x1", "x2", "x3"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("played %v, want %v", got, want)
+	}
+}
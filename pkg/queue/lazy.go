@@ -0,0 +1,245 @@
+package queue
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+// Big playlists load lazily. The queue starts playing as soon as the first
+// few songs arrive, and the rest are added as WhatsNext gets to them.
+// Shuffled playlists still wait for everything, since a fair shuffle needs
+// the whole list.
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/sonic"
+)
+
+// Streamer is a Source that can hand songs over as they arrive, instead of
+// all at once
+type Streamer interface {
+	Source
+	Stream(each func(sonic.Song) error) (sonic.Playlist, error)
+}
+
+// ServerPlaylist is a playlist on the server, by name
+type ServerPlaylist struct {
+	Client *sonic.Sonic
+	Name   string
+}
+
+func (src ServerPlaylist) Load() (sonic.Playlist, error) {
+	return src.Client.GetPlaylistByName(src.Name)
+}
+
+func (src ServerPlaylist) Stream(each func(sonic.Song) error) (sonic.Playlist, error) {
+	playlists, err := src.Client.GetPlaylists()
+	if err != nil {
+		return sonic.Playlist{}, err
+	}
+
+	for _, listing := range playlists {
+		if listing.Name == src.Name {
+			return src.Client.StreamPlaylist(listing.ID, each)
+		}
+	}
+	return sonic.Playlist{}, fmt.Errorf("unable to find playlist '%s'", src.Name)
+}
+
+// errStale stops a load that's been replaced by a newer one
+var errStale = errors.New("a newer load took over")
+
+// A load that fails part way is picked up where it left off, this many
+// times, this far apart
+const streamRetries = 3
+
+var streamRetryDelay = 2 * time.Second
+
+// arrivals holds songs that came in after the queue started playing
+type arrivals struct {
+	sync.Mutex
+	ready chan struct{}
+
+	generation int
+	songs      sonic.Songs
+	loading    bool
+	header     *sonic.Playlist
+
+	// err is why loading gave up before the end, if it did
+	err error
+}
+
+// stream loads a Streamer in the background, returning once there's enough
+// to start playing
+func (queue *Queue) stream(src Streamer) (sonic.Playlist, error) {
+	incoming := &queue.arrivals
+	incoming.Lock()
+	incoming.generation++
+	generation := incoming.generation
+	incoming.songs = nil
+	incoming.header = nil
+	incoming.err = nil
+	incoming.loading = true
+	incoming.ready = make(chan struct{}, 1)
+	incoming.Unlock()
+
+	var (
+		name    = queue.Playlist.Name
+		first   = make(chan sonic.Songs, 1)
+		failed  = make(chan error, 1)
+		batch   sonic.Songs
+		started bool
+		// Enough to fill the queue, so it doesn't run dry while the
+		// rest arrives
+		enough = queue.Depth + 1
+	)
+
+	// delivered counts the songs handed over so far, so a retry can skip
+	// past them
+	var delivered int
+	each := func(song sonic.Song) error {
+		delivered++
+		if !started {
+			batch = append(batch, song)
+			if len(batch) >= enough {
+				started = true
+				first <- batch
+			}
+			return nil
+		}
+
+		incoming.Lock()
+		defer incoming.Unlock()
+		if incoming.generation != generation {
+			return errStale
+		}
+		incoming.songs = append(incoming.songs, song)
+		incoming.signal()
+		return nil
+	}
+
+	go func() {
+		header, err := src.Stream(each)
+		for retry := 1; err != nil && started && !errors.Is(err, errStale) && retry <= streamRetries; retry++ {
+			slog.Warn("loading the playlist stopped part way, trying again",
+				"playlist", name,
+				"songs", delivered,
+				"retry", retry,
+				"err", err,
+			)
+			time.Sleep(streamRetryDelay)
+
+			skip := delivered
+			header, err = src.Stream(func(song sonic.Song) error {
+				if skip > 0 {
+					skip--
+					return nil
+				}
+				return each(song)
+			})
+		}
+
+		if !started {
+			if err != nil {
+				failed <- err
+			} else {
+				first <- batch
+				started = true
+			}
+		}
+
+		incoming.Lock()
+		defer incoming.Unlock()
+		if incoming.generation != generation {
+			return
+		}
+		incoming.loading = false
+		if err == nil {
+			incoming.header = &header
+		} else if started {
+			// The first batch made it, so nobody's waiting to hear about
+			// this. The queue carries on with what did arrive, and says so.
+			slog.Error("loading the rest of the playlist failed", "playlist", name, "songs", delivered, "err", err)
+			incoming.err = err
+		}
+		incoming.signal()
+	}()
+
+	select {
+	case songs := <-first:
+		return sonic.Playlist{Name: queue.Playlist.Name, ID: queue.Playlist.ID, Songs: songs}, nil
+	case err := <-failed:
+		incoming.Lock()
+		incoming.loading = false
+		incoming.Unlock()
+		return sonic.Playlist{}, err
+	}
+}
+
+// signal wakes anything waiting on arrivals. The lock must be held.
+func (incoming *arrivals) signal() {
+	select {
+	case incoming.ready <- struct{}{}:
+	default:
+	}
+}
+
+// take hands over everything that's arrived so far, and why loading gave
+// up if it did
+func (incoming *arrivals) take() (sonic.Songs, *sonic.Playlist, bool, error) {
+	incoming.Lock()
+	defer incoming.Unlock()
+
+	songs, header, err := incoming.songs, incoming.header, incoming.err
+	incoming.songs = nil
+	incoming.header = nil
+	incoming.err = nil
+	return songs, header, incoming.loading, err
+}
+
+// wait blocks until more songs arrive or loading is done
+func (incoming *arrivals) wait() {
+	incoming.Lock()
+	if !incoming.loading || len(incoming.songs) > 0 {
+		incoming.Unlock()
+		return
+	}
+	ready := incoming.ready
+	incoming.Unlock()
+	<-ready
+}
+
+// takeArrivals adds songs that showed up since last time to the playlist
+// and to what's left to play. It's true while more are on the way.
+func (queue *Queue) takeArrivals() bool {
+	songs, header, loading, err := queue.arrivals.take()
+
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+
+	if err != nil {
+		queue.partial = err
+	}
+	if header != nil {
+		queue.partial = nil
+		queue.Playlist.ID = header.ID
+		queue.Playlist.Name = header.Name
+		queue.Playlist.Duration = header.Duration
+		queue.Playlist.SongCount = header.SongCount
+	}
+	if len(songs) == 0 {
+		return loading
+	}
+
+	queue.Playlist.Songs = append(queue.Playlist.Songs, songs...)
+
+	// queue.songs can share its backing array with the playlist, so it
+	// gets a copy rather than an append
+	remaining := make(sonic.Songs, len(queue.songs), len(queue.songs)+len(songs))
+	copy(remaining, queue.songs)
+	queue.songs = append(remaining, songs...)
+	return loading
+}
@@ -0,0 +1,138 @@
+package queue
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/sonic"
+)
+
+// flaky streams ten songs, giving up at the song failAt says for each try.
+// Zero means that try gets them all.
+type flaky struct {
+	failAt []int
+
+	lock  sync.Mutex
+	tries int
+}
+
+func (src *flaky) Load() (sonic.Playlist, error) {
+	return sonic.Playlist{}, errors.New("flaky only streams")
+}
+
+func (src *flaky) Stream(each func(sonic.Song) error) (sonic.Playlist, error) {
+	src.lock.Lock()
+	try := src.tries
+	src.tries++
+	src.lock.Unlock()
+
+	fail := 0
+	if try < len(src.failAt) {
+		fail = src.failAt[try]
+	}
+	for idx := 1; idx <= 10; idx++ {
+		if idx == fail {
+			return sonic.Playlist{}, fmt.Errorf("connection reset at song %d", idx)
+		}
+		if err := each(sonic.Song{ID: fmt.Sprintf("s%d", idx)}); err != nil {
+			return sonic.Playlist{}, err
+		}
+	}
+	return sonic.Playlist{Name: "flaky", SongCount: 10}, nil
+}
+
+func ids(from int, to int) []string {
+	var list []string
+	for idx := from; idx <= to; idx++ {
+		list = append(list, fmt.Sprintf("s%d", idx))
+	}
+	return list
+}
+
+func TestStreamFailures(t *testing.T) {
+	streamRetryDelay = time.Millisecond
+	defer func() { streamRetryDelay = 2 * time.Second }()
+
+	// Just enough of a server for stars, which the queue checks as it goes
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"subsonic-response":{"status":"ok","version":"1.16.1","starred":""}}`))
+	}))
+	defer server.Close()
+	client := sonic.New(sonic.Auth{}, server.URL)
+
+	tests := []struct {
+		name    string
+		failAt  []int
+		repeat  bool
+		plays   int
+		want    []string
+		partial bool
+	}{
+		{
+			name:  "no trouble",
+			plays: 20,
+			want:  ids(1, 10),
+		},
+		{
+			name:   "picks up where it left off",
+			failAt: []int{6},
+			plays:  20,
+			want:   ids(1, 10),
+		},
+		{
+			name:    "gives up and says so",
+			failAt:  []int{6, 6, 6, 6},
+			plays:   20,
+			want:    ids(1, 5),
+			partial: true,
+		},
+		{
+			name:   "tries again on repeat",
+			failAt: []int{6, 6, 6, 6},
+			repeat: true,
+			plays:  15,
+			want:   append(ids(1, 5), ids(1, 10)...),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			q := New()
+			q.Client = &client
+			q.Live = true
+			q.Depth = 2
+			q.Repeat = test.repeat
+			q.Source = &flaky{failAt: test.failAt}
+			if err := q.Load(); err != nil {
+				t.Fatal(err)
+			}
+			defer q.CleanUp()
+
+			var got []string
+			for len(got) < test.plays {
+				entry := q.WhatsNext()
+				if entry == nil {
+					break
+				}
+				got = append(got, entry.Meta.ID)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("played %v, want %v", got, test.want)
+			}
+			if partial := q.Partial() != nil; partial != test.partial {
+				t.Errorf("partial is %v, want %v", q.Partial(), test.partial)
+			}
+		})
+	}
+}
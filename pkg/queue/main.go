@@ -6,9 +6,12 @@ package queue
 import (
 	"errors"
 	"fmt"
+	"log/slog"
 	"os"
+	"sync"
 	"time"
 
+	"git.sr.ht/~sungo/hedgehog/pkg/library"
 	"git.sr.ht/~sungo/hedgehog/pkg/sonic"
 )
 
@@ -17,13 +20,41 @@ type Entry struct {
 	LocalFile   string
 	Downloading bool
 	Starred     bool
+
+	// Cached entries play straight out of the library and their file
+	// is left alone when the entry is done
+	Cached bool
+
+	// Bookmark entries keep their position in a server bookmark, and
+	// start playing from Resume
+	Bookmark bool
+	Resume   time.Duration
+
+	// Live entries are internet radio. They play straight from the
+	// stream url in Meta.Path, and are never scrobbled or starred. Meta.Title
+	// follows whatever the station says is playing.
+	Live bool
+
+	// Requested entries were asked for with Enqueue, and play ahead of
+	// the rest of the queue
+	Requested bool
+
+	// lock guards LocalFile, Downloading and Cached, since downloads
+	// finish in the background
+	lock sync.Mutex
 }
 
-func (entry Entry) String() string {
+func (entry *Entry) String() string {
+	if entry.Live {
+		if entry.Meta.Title == "" {
+			return fmt.Sprintf("|> %s [live]", entry.Meta.Artist)
+		}
+		return fmt.Sprintf("|> %s : %s [live]", entry.Meta.Artist, entry.Meta.Title)
+	}
 	if entry.Starred {
-		return fmt.Sprintf("|> %s : %s [*]", entry.Meta.Artist, entry.Meta.Title)
+		return fmt.Sprintf("|> %s : %s [*]", entry.Meta.ArtistName(), entry.Meta.Title)
 	}
-	return fmt.Sprintf("|> %s : %s", entry.Meta.Artist, entry.Meta.Title)
+	return fmt.Sprintf("|> %s : %s", entry.Meta.ArtistName(), entry.Meta.Title)
 }
 
 type (
@@ -38,12 +69,53 @@ type (
 		Client  *sonic.Sonic
 		TempDir string
 
+		// Library, if set, is checked before downloading anything. When
+		// Offline, it's the only source of playlists, songs and stars.
+		Library *library.Library
+		Offline bool
+
+		// Source, if set, replaces the server playlist as the place songs
+		// come from
+		Source Source
+
+		// Bookmarks saves each entry's position in a server bookmark and
+		// picks up from there next time. BookmarkOver does the same for
+		// songs at least that long, like DJ sets and audiobooks.
+		Bookmarks    bool
+		BookmarkOver time.Duration
+
+		// Live means every entry is an internet radio stream
+		Live bool
+
+		// Endless tops the queue up with songs like the one playing,
+		// instead of stopping or repeating, when it runs out
+		Endless bool
+
 		Playing  *Entry
 		upNext   entryList
 		previous entryList
 		starred  map[string]bool
+		resume   map[string]time.Duration
 
-		songs sonic.Songs
+		// moving is held while WhatsNext, Previous, StartRadio or Load
+		// move the queue along, so they take turns. Key bindings and the
+		// web remote call them from outside the play loop.
+		moving sync.Mutex
+
+		// lock guards Playing, upNext, previous, starred, resume,
+		// bookmarksAt and Playlist, since the player, the web remote and Enqueue look at
+		// them from other goroutines. Use Current to read Playing.
+		lock sync.Mutex
+
+		songs    sonic.Songs
+		arrivals arrivals
+		heard    map[string]bool
+
+		// partial is why the playlist stopped loading part way, if it did
+		partial error
+
+		bookmarksAt time.Time
+		bookmarker  bookmarker
 	}
 )
 
@@ -57,67 +129,203 @@ func New() *Queue {
 }
 
 func (queue *Queue) UpdatePlaylist() {
-	playlist, err := queue.Client.GetPlaylist(queue.Playlist.ID)
-	if err != nil {
+	if err := queue.Load(); err != nil {
 		panic(err)
 	}
+}
+
+// Load fetches the playlist and starts the queue over from the top.
+// Streamers only hand over the first few songs here, unless the queue is
+// shuffled. The rest show up as the queue plays.
+func (queue *Queue) Load() error {
+	queue.moving.Lock()
+	defer queue.moving.Unlock()
+
+	streamed, err := queue.load()
+	if err != nil {
+		return err
+	}
+
+	queue.CleanUp()
+	if streamed {
+		// Start over with the new playlist, rather than finishing the old
+		// one with the new one's songs tacked on
+		queue.songs = append(sonic.Songs{}, queue.Playlist.Songs...)
+	}
+	return nil
+}
+
+// load fetches the playlist, leaving whatever's lined up alone. It's true
+// if the playlist is still streaming in.
+func (queue *Queue) load() (bool, error) {
+	var (
+		playlist sonic.Playlist
+		err      error
+		started  = time.Now()
+	)
+	streamer, streams := queue.Source.(Streamer)
+	switch {
+	case streams && !queue.Shuffle:
+		playlist, err = queue.stream(streamer)
+	case queue.Source != nil:
+		playlist, err = queue.Source.Load()
+	case queue.Offline:
+		playlist, err = queue.Library.Playlist(queue.Playlist.Name)
+	default:
+		playlist, err = queue.Client.GetPlaylist(queue.Playlist.ID)
+	}
+	if err != nil {
+		slog.Error("loading playlist failed", "playlist", queue.Playlist.Name, "err", err)
+		return false, err
+	}
+	slog.Info("loaded playlist",
+		"playlist", playlist.Name,
+		"songs", len(playlist.Songs),
+		"streaming", streams && !queue.Shuffle,
+		"took", time.Since(started).Round(time.Millisecond),
+	)
 
 	if len(playlist.Songs) == 0 {
-		panic(errors.New("empty playlist"))
+		return false, errors.New("empty playlist")
 	}
 
 	if queue.Shuffle {
-		queue.Playlist = playlist.Shuffle()
-	} else {
-		queue.Playlist = playlist
+		playlist = playlist.Shuffle()
 	}
-	queue.CleanUp()
+	queue.lock.Lock()
+	queue.Playlist = playlist
+	queue.partial = nil
+	queue.lock.Unlock()
+	return streams && !queue.Shuffle, nil
 }
 
 func (queue *Queue) UpdateStarred() {
-	starred, err := queue.Client.GetStarred()
-	if err != nil {
-		return
+	var starred map[string]bool
+	if queue.Offline {
+		starred = queue.Library.Starred()
+	} else {
+		var err error
+		starred, err = queue.Client.GetStarred()
+		if err != nil {
+			slog.Warn("fetching stars failed", "err", err)
+			return
+		}
 	}
 
+	queue.lock.Lock()
 	queue.starred = starred
+	queue.lock.Unlock()
 }
 
 func (queue *Queue) Fetch(entry *Entry) error {
+	entry.lock.Lock()
 	entry.Downloading = true
-	defer func() { entry.Downloading = false }()
+	entry.lock.Unlock()
 
-	song := entry.Meta
-	tmpFile, err := os.CreateTemp(queue.TempDir, fmt.Sprintf("hedgehog-*.%s", song.Suffix))
+	path, cached, err := queue.fetch(entry.Meta, entry.Live)
+
+	entry.lock.Lock()
+	defer entry.lock.Unlock()
+	entry.Downloading = false
 	if err != nil {
 		return err
 	}
-	// fmt.Printf("==> [BK] Downloading %s as %s\n", song.Title, tmpFile.Name())
+	entry.LocalFile = path
+	entry.Cached = cached
+	return nil
+}
 
+// fetch finds a song in the library or downloads it, returning where it
+// ended up and whether that's the library's copy
+func (queue *Queue) fetch(song sonic.Song, live bool) (string, bool, error) {
+	if live {
+		return song.Path, true, nil
+	}
+	if queue.Library != nil {
+		if path, ok := queue.Library.File(song.ID); ok {
+			slog.Debug("cache hit", "song", song.ID, "title", song.Title, "path", path)
+			return path, true, nil
+		}
+	}
+	if queue.Offline {
+		return "", false, fmt.Errorf("'%s' isn't in the library", song.Title)
+	}
+
+	tmpFile, err := os.CreateTemp(queue.TempDir, fmt.Sprintf("hedgehog-*.%s", song.Suffix))
+	if err != nil {
+		return "", false, err
+	}
+
+	started := time.Now()
 	data, err := queue.Client.DownloadSong(song)
 	if err != nil {
-		return err
+		slog.Error("download failed", "song", song.ID, "title", song.Title, "err", err)
+		return "", false, err
 	}
 
 	if _, err := tmpFile.Write(data); err != nil {
-		return err
+		return "", false, err
 	}
 	if err := tmpFile.Close(); err != nil {
-		return err
+		return "", false, err
+	}
+	slog.Info("downloaded",
+		"song", song.ID,
+		"title", song.Title,
+		"bytes", len(data),
+		"took", time.Since(started).Round(time.Millisecond),
+	)
+	return tmpFile.Name(), false, nil
+}
+
+// missing is true for songs that can't be played because we're offline
+// and the library doesn't have them
+func (queue *Queue) missing(song sonic.Song) bool {
+	if !queue.Offline || queue.Live {
+		return false
+	}
+	if queue.Library == nil {
+		return true
+	}
+	_, ok := queue.Library.File(song.ID)
+	return !ok
+}
+
+// Ready is whether the entry is downloaded and can be played
+func (entry *Entry) Ready() bool {
+	entry.lock.Lock()
+	defer entry.lock.Unlock()
+	return !entry.Downloading && entry.LocalFile != ""
+}
+
+// wait blocks while the entry is downloading
+func (entry *Entry) wait() {
+	for {
+		entry.lock.Lock()
+		downloading := entry.Downloading
+		entry.lock.Unlock()
+		if !downloading {
+			return
+		}
+		time.Sleep(250 * time.Millisecond)
 	}
-	entry.LocalFile = tmpFile.Name()
-	return nil
 }
 
 func (entry *Entry) Remove() {
-	if entry.LocalFile == "" {
+	entry.lock.Lock()
+	fetched := entry.LocalFile != ""
+	entry.lock.Unlock()
+	if !fetched {
 		return
 	}
 
-	for entry.Downloading == true {
-		time.Sleep(250 * time.Millisecond)
+	entry.wait()
+
+	entry.lock.Lock()
+	defer entry.lock.Unlock()
+	if !entry.Cached {
+		os.Remove(entry.LocalFile)
 	}
-	os.Remove(entry.LocalFile)
 	entry.LocalFile = ""
 }
 
@@ -125,12 +333,16 @@ func (list entryList) Clear() {
 	for idx := range list {
 		list[idx].Remove()
 	}
-	list = make(entryList, 0)
 }
 
 func (queue *Queue) CleanUp() {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+
 	queue.upNext.Clear()
 	queue.previous.Clear()
+	queue.upNext = make(entryList, 0)
+	queue.previous = make(entryList, 0)
 	if queue.Playing != nil {
 		queue.Playing.Remove()
 		queue.Playing = nil
@@ -138,55 +350,90 @@ func (queue *Queue) CleanUp() {
 }
 
 func (queue *Queue) Previous() {
+	queue.moving.Lock()
+	defer queue.moving.Unlock()
+
 	if len(queue.Playlist.Songs) == 0 {
 		return
 	}
 	if len(queue.songs) == 0 {
 		return
 	}
+
+	queue.lock.Lock()
 	if len(queue.previous) == 0 {
+		queue.lock.Unlock()
 		return
 	}
-
 	prev := queue.previous[len(queue.previous)-1]
-	if prev.LocalFile == "" {
-		if err := queue.Fetch(prev); err != nil {
-			panic(err)
-		}
+	playing := queue.Playing
+	queue.lock.Unlock()
 
-		for prev.Downloading == true {
-			time.Sleep(250 * time.Millisecond)
+	prev.wait()
+	if !prev.Ready() {
+		if err := queue.Fetch(prev); err != nil {
+			slog.Warn("going back failed", "song", prev.Meta.ID, "title", prev.Meta.Title, "err", err)
+			return
 		}
 	}
 
-	if queue.Playing == nil {
-		queue.upNext = append(entryList{prev}, queue.upNext...)
-	} else {
-		for queue.Playing.Downloading == true {
-			time.Sleep(250 * time.Millisecond)
-		}
-
-		queue.upNext = append(entryList{prev, queue.Playing}, queue.upNext...)
+	lined := entryList{prev}
+	if playing != nil {
+		playing.wait()
+		lined = append(lined, playing)
 	}
+
+	queue.lock.Lock()
+	queue.upNext = append(lined, queue.upNext...)
+	queue.lock.Unlock()
 }
 
 func (queue *Queue) WhatsNext() *Entry {
+	queue.moving.Lock()
+	defer queue.moving.Unlock()
+
+	loading := queue.takeArrivals()
 	if len(queue.Playlist.Songs) == 0 {
 		return nil
 	}
+	playing := queue.Current()
 
-	if len(queue.songs) == 0 {
-		if len(queue.previous) > 0 {
-			if !queue.Repeat {
-				return nil
+	// Ran ahead of a playlist that's still loading
+	for loading && len(queue.songs) == 0 && playing != nil {
+		queue.arrivals.wait()
+		loading = queue.takeArrivals()
+	}
+
+	if len(queue.songs) == 0 && queue.Endless && playing != nil {
+		queue.TopUp(playing.Meta)
+	}
+
+	switch {
+	case len(queue.songs) > 0:
+	case playing != nil && !queue.Repeat:
+		// Nothing left to line up, so play out what already is
+		if queue.PeekNext() == nil {
+			return nil
+		}
+	default:
+		switch {
+		case playing != nil && queue.ReloadOnRepeat:
+			if _, err := queue.load(); err != nil {
+				panic(err)
 			}
-			if queue.ReloadOnRepeat {
-				queue.UpdatePlaylist()
+		case playing != nil && queue.Partial() != nil:
+			// Only some of it loaded last time, so have another go at the
+			// whole thing before going around again
+			if _, err := queue.load(); err != nil {
+				slog.Warn("reloading the playlist failed", "playlist", queue.Playlist.Name, "err", err)
 			}
 		}
 
 		if queue.Shuffle {
+			// Shuffling moves the playlist's songs around in place
+			queue.lock.Lock()
 			shuffled := queue.Playlist.Shuffle()
+			queue.lock.Unlock()
 			queue.songs = shuffled.Songs
 		} else {
 			queue.songs = queue.Playlist.Songs
@@ -194,7 +441,9 @@ func (queue *Queue) WhatsNext() *Entry {
 	}
 
 	queue.UpdateStarred()
+	queue.refreshBookmarks()
 
+	queue.lock.Lock()
 	if len(queue.previous) > len(queue.Playlist.Songs) {
 		// Gotta limit the buffer somehow
 		queue.previous = queue.previous[1:]
@@ -208,67 +457,209 @@ func (queue *Queue) WhatsNext() *Entry {
 		queue.Playing = queue.upNext[0]
 		queue.upNext = queue.upNext[1:]
 	}
+	queue.lock.Unlock()
 
-	for len(queue.upNext) < queue.Depth {
-		if len(queue.songs) == 0 {
-			break
-		}
-		nextQueued := &Entry{Meta: queue.songs[0]}
-		if queue.starred[nextQueued.Meta.ID] {
-			nextQueued.Starred = true
+	queue.lineUp()
+
+	playing = queue.Current()
+	if playing == nil {
+		// Nothing in the playlist could be lined up
+		return nil
+	}
+	playing.wait()
+	if !playing.Ready() {
+		if err := queue.Fetch(playing); err != nil {
+			panic(err)
 		}
+	}
+	return playing
+}
 
-		if queue.Playing == nil {
-			queue.Playing = nextQueued
+// Name is the name of the playlist
+func (queue *Queue) Name() string {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	return queue.Playlist.Name
+}
 
-			if err := queue.Fetch(nextQueued); err != nil {
-				panic(err)
-			}
-		} else {
-			queue.upNext = append(queue.upNext, nextQueued)
-			go func() {
-				if err := queue.Fetch(nextQueued); err != nil {
-					panic(err)
-				}
-			}()
+// Song finds a song in the playlist
+func (queue *Queue) Song(id string) (sonic.Song, bool) {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	for _, song := range queue.Playlist.Songs {
+		if song.ID == id {
+			return song, true
 		}
-		queue.songs = queue.songs[1:]
 	}
+	return sonic.Song{}, false
+}
 
-	if queue.Playing.Downloading == false && queue.Playing.LocalFile == "" {
-		if err := queue.Fetch(queue.Playing); err != nil {
-			panic(err)
-		}
+// Partial is why the playlist only partly loaded, or nil if it all did or
+// is still on its way. With Repeat, the queue tries loading it again
+// before going around.
+func (queue *Queue) Partial() error {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	return queue.partial
+}
+
+// Current is the entry that's playing, or nil before anything has
+func (queue *Queue) Current() *Entry {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	return queue.Playing
+}
+
+// PeekNext returns the entry that will play after the current one, or nil
+// if nothing is lined up
+func (queue *Queue) PeekNext() *Entry {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+
+	if len(queue.upNext) == 0 {
+		return nil
 	}
+	return queue.upNext[0]
+}
 
-	for queue.Playing.Downloading == true {
-		time.Sleep(250 * time.Millisecond)
+// Upcoming is what's lined up to play after the current entry
+func (queue *Queue) Upcoming() []*Entry {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	return append([]*Entry(nil), queue.upNext...)
+}
+
+// History is what played before the current entry, oldest first
+func (queue *Queue) History() []*Entry {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	return append([]*Entry(nil), queue.previous...)
+}
+
+// Enqueue fetches a song and lines it up to play after the current entry,
+// behind anything else enqueued but ahead of the rest of the queue
+func (queue *Queue) Enqueue(song sonic.Song) error {
+	if queue.Live {
+		return errors.New("songs can't be added to the radio")
 	}
-	return queue.Playing
+
+	queue.lock.Lock()
+	entry := &Entry{
+		Meta:      song,
+		Starred:   queue.starred[song.ID],
+		Requested: true,
+	}
+	if queue.wantsBookmark(song) {
+		entry.Bookmark = true
+		entry.Resume = queue.resume[song.ID]
+	}
+	queue.lock.Unlock()
+
+	if err := queue.Fetch(entry); err != nil {
+		return err
+	}
+
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+
+	at := 0
+	for at < len(queue.upNext) && queue.upNext[at].Requested {
+		at++
+	}
+	queue.upNext = append(queue.upNext[:at], append(entryList{entry}, queue.upNext[at:]...)...)
+	return nil
 }
 
 func (queue *Queue) StarToggle() {
-	song := queue.Playing
-	if song == nil {
+	song := queue.Current()
+	if song == nil || song.Live {
 		return
 	}
 
 	queue.UpdateStarred()
-	if queue.starred[song.Meta.ID] {
-		queue.Client.UnStar(song.Meta)
-	} else {
-		queue.Client.Star(song.Meta)
+	star := !queue.Starred(song.Meta.ID)
+
+	var err error
+	switch {
+	case queue.Offline:
+		err = queue.Library.QueueStar(song.Meta, star)
+	case star:
+		err = queue.Client.Star(song.Meta)
+	default:
+		err = queue.Client.UnStar(song.Meta)
+	}
+	if err != nil {
+		slog.Warn("starring failed", "song", song.Meta.ID, "star", star, "err", err)
 	}
 
 	queue.UpdateStarred()
 }
 
+// Starred is whether a song was starred as of the last UpdateStarred
+func (queue *Queue) Starred(id string) bool {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	return queue.starred[id]
+}
+
+// IsStarred is Starred for an entry, which it corrects to match. Entries
+// belong to the play loop, so anything else should ask Starred.
 func (queue *Queue) IsStarred(entry *Entry) bool {
 	// If you're asking for this, you doubt the entry. So let's fix it
-	if queue.starred[entry.Meta.ID] {
-		entry.Starred = true
-	} else {
-		entry.Starred = false
-	}
+	entry.Starred = queue.Starred(entry.Meta.ID)
 	return entry.Starred
 }
+
+// lineUp moves songs into upNext, fetching them in the background, until
+// the queue is Depth deep
+func (queue *Queue) lineUp() {
+	for len(queue.songs) > 0 {
+		if song := queue.songs[0]; queue.missing(song) {
+			slog.Warn("skipping a song that isn't in the library", "song", song.ID, "title", song.Title)
+			queue.songs = queue.songs[1:]
+			continue
+		}
+
+		queue.lock.Lock()
+		if len(queue.upNext) >= queue.Depth {
+			queue.lock.Unlock()
+			break
+		}
+
+		nextQueued := &Entry{Meta: queue.songs[0]}
+		queue.remember(nextQueued.Meta)
+		if queue.starred[nextQueued.Meta.ID] {
+			nextQueued.Starred = true
+		}
+		if queue.Live {
+			nextQueued.Live = true
+		}
+		if queue.wantsBookmark(nextQueued.Meta) {
+			nextQueued.Bookmark = true
+			nextQueued.Resume = queue.resume[nextQueued.Meta.ID]
+		}
+
+		first := queue.Playing == nil
+		if first {
+			queue.Playing = nextQueued
+		} else {
+			// Marked before anyone can see it, so nobody fetches it twice
+			nextQueued.Downloading = true
+			queue.upNext = append(queue.upNext, nextQueued)
+		}
+		queue.lock.Unlock()
+
+		if first {
+			if err := queue.Fetch(nextQueued); err != nil {
+				panic(err)
+			}
+		} else {
+			go func() {
+				if err := queue.Fetch(nextQueued); err != nil {
+					panic(err)
+				}
+			}()
+		}
+		queue.songs = queue.songs[1:]
+	}
+}
@@ -0,0 +1,94 @@
+package queue
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/library"
+	"git.sr.ht/~sungo/hedgehog/pkg/sonic"
+)
+
+// A song that fails to sync, or goes missing from the library afterwards,
+// is left out rather than stopping everything
+func TestOfflineMissingSongs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.URL.Path {
+		case "/rest/getStarred":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"subsonic-response":{"status":"ok","version":"1.16.1","starred":{}}}`))
+		case "/rest/download":
+			if r.Form.Get("id") == "s2" {
+				http.Error(w, "no", http.StatusInternalServerError)
+				return
+			}
+			w.Write([]byte("not really audio"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	client := sonic.New(sonic.Auth{}, server.URL)
+
+	lib, err := library.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	playlist := sonic.Playlist{ID: "p1", Name: "mix"}
+	for _, id := range []string{"s1", "s2", "s3", "s4"} {
+		playlist.Songs = append(playlist.Songs, sonic.Song{ID: id, Title: id, Suffix: "mp3"})
+	}
+
+	failed, err := lib.Sync(&client, playlist, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed != 1 {
+		t.Errorf("%d failed, want 1", failed)
+	}
+	synced, err := lib.Playlist("mix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, song := range synced.Songs {
+		got = append(got, song.ID)
+	}
+	if !reflect.DeepEqual(got, []string{"s1", "s3", "s4"}) {
+		t.Fatalf("synced %v", got)
+	}
+
+	// Gone from the disk since
+	path, _ := lib.File("s3")
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+
+	q := New()
+	q.Library = lib
+	q.Offline = true
+	q.Depth = 2
+	q.TempDir = t.TempDir()
+	q.Playlist.Name = "mix"
+	if err := q.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.CleanUp()
+
+	var played []string
+	for entry := q.WhatsNext(); entry != nil; entry = q.WhatsNext() {
+		played = append(played, entry.Meta.ID)
+		if len(played) > 4 {
+			break
+		}
+	}
+	if !reflect.DeepEqual(played, []string{"s1", "s4"}) {
+		t.Errorf("played %v, want [s1 s4]", played)
+	}
+}
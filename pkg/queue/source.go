@@ -0,0 +1,145 @@
+package queue
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/sonic"
+)
+
+// Source supplies something other than a server playlist for the queue to
+// play. It's loaded whenever the queue would refetch its playlist.
+type Source interface {
+	Load() (sonic.Playlist, error)
+}
+
+// Podcasts plays episodes the server has downloaded, newest first. With a
+// Channel, it plays that channel's episodes. Otherwise, it plays the Newest
+// episodes across every channel.
+type Podcasts struct {
+	Client  *sonic.Sonic
+	Channel string
+	Newest  int
+}
+
+func (src Podcasts) Load() (sonic.Playlist, error) {
+	channels, err := src.Client.GetPodcasts(src.Channel != "")
+	if err != nil {
+		return sonic.Playlist{}, err
+	}
+
+	var (
+		episodes sonic.Episodes
+		titles   = make(map[string]string, len(channels))
+		name     = "Newest Podcasts"
+	)
+	for _, channel := range channels {
+		titles[channel.ID] = channel.Title
+		if src.Channel != "" && channel.Title == src.Channel {
+			episodes = channel.Episodes
+			name = channel.Title
+		}
+	}
+
+	if src.Channel == "" {
+		if episodes, err = src.Client.GetNewestPodcasts(src.Newest); err != nil {
+			return sonic.Playlist{}, err
+		}
+	} else if name != src.Channel {
+		return sonic.Playlist{}, fmt.Errorf("unable to find podcast '%s'", src.Channel)
+	}
+
+	sort.SliceStable(episodes, func(i int, j int) bool {
+		return episodes[i].Published().After(episodes[j].Published())
+	})
+
+	playlist := sonic.Playlist{Name: name}
+	for _, episode := range episodes {
+		if !episode.Downloaded() {
+			continue
+		}
+
+		song := episode.Song()
+		if song.Artist == "" {
+			song.Artist = titles[episode.ChannelID]
+		}
+		if song.Album == "" {
+			song.Album = titles[episode.ChannelID]
+		}
+		playlist.Songs = append(playlist.Songs, song)
+		playlist.Duration += song.Duration
+	}
+	playlist.SongCount = len(playlist.Songs)
+
+	if len(playlist.Songs) == 0 {
+		return playlist, fmt.Errorf("no downloaded episodes in '%s'. Try 'hedgehog podcasts download'", name)
+	}
+	return playlist, nil
+}
+
+// Radio plays one of the server's internet radio stations, by name
+type Radio struct {
+	Client  *sonic.Sonic
+	Station string
+}
+
+func (src Radio) Load() (sonic.Playlist, error) {
+	stations, err := src.Client.GetInternetRadioStations()
+	if err != nil {
+		return sonic.Playlist{}, err
+	}
+
+	names := make([]string, 0, len(stations))
+	for _, station := range stations {
+		if station.Name == src.Station {
+			return sonic.Playlist{
+				ID:        station.ID,
+				Name:      station.Name,
+				SongCount: 1,
+				Songs:     sonic.Songs{station.Song()},
+			}, nil
+		}
+		names = append(names, fmt.Sprintf("'%s'", station.Name))
+	}
+
+	if len(names) == 0 {
+		return sonic.Playlist{}, errors.New("the server doesn't have any internet radio stations")
+	}
+	return sonic.Playlist{}, fmt.Errorf("unable to find station '%s'. Try one of %s", src.Station, strings.Join(names, ", "))
+}
+
+// Albums plays whole albums from one of the server's album lists, like the
+// newest ones, an album at a time in the order the list has them
+type Albums struct {
+	Client *sonic.Sonic
+	List   sonic.AlbumList
+	Count  int
+}
+
+func (src Albums) Load() (sonic.Playlist, error) {
+	albums, err := src.Client.AlbumListPager(src.List).All(src.Count)
+	if err != nil {
+		return sonic.Playlist{}, err
+	}
+	if len(albums) == 0 {
+		return sonic.Playlist{}, fmt.Errorf("no %s on the server", src.List)
+	}
+
+	playlist := sonic.Playlist{Name: src.List.String()}
+	for _, listed := range albums {
+		album, err := src.Client.GetAlbum(listed.ID)
+		if err != nil {
+			return sonic.Playlist{}, fmt.Errorf("album '%s': %w", listed.Name, err)
+		}
+		playlist.Songs = append(playlist.Songs, album.Songs...)
+		playlist.Duration += album.Duration
+	}
+	playlist.SongCount = len(playlist.Songs)
+
+	return playlist, nil
+}
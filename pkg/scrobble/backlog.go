@@ -0,0 +1,144 @@
+package scrobble
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// The backlog keeps at most this many entries, dropping the oldest first.
+// Last.fm refuses scrobbles more than two weeks old anyway.
+const maxBacklog = 10000
+
+type (
+	BacklogEntry struct {
+		Service string `json:"service"`
+		Listen  Listen `json:"listen"`
+	}
+
+	// Backlog is an on-disk list of scrobbles that failed, one JSON object
+	// per line, so they can be retried later or by the next run
+	Backlog struct {
+		path    string
+		entries []BacklogEntry
+		lock    sync.Mutex
+	}
+)
+
+func OpenBacklog(path string) (*Backlog, error) {
+	backlog := Backlog{path: path}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &backlog, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry BacklogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// A half written line from a crash. Skip it rather than lose
+			// everything else.
+			continue
+		}
+		backlog.entries = append(backlog.entries, entry)
+	}
+
+	return &backlog, scanner.Err()
+}
+
+func (backlog *Backlog) Len() int {
+	backlog.lock.Lock()
+	defer backlog.lock.Unlock()
+	return len(backlog.entries)
+}
+
+func (backlog *Backlog) Add(service string, listen Listen) error {
+	backlog.lock.Lock()
+	defer backlog.lock.Unlock()
+
+	backlog.entries = append(backlog.entries, BacklogEntry{Service: service, Listen: listen})
+	if len(backlog.entries) > maxBacklog {
+		backlog.entries = backlog.entries[len(backlog.entries)-maxBacklog:]
+	}
+	return backlog.save()
+}
+
+// Retry hands each entry to submit, in the order they were added, keeping
+// the ones it isn't done with. The backlog isn't locked while submit runs,
+// so listens that fail in the meantime can still be added.
+func (backlog *Backlog) Retry(submit func(BacklogEntry) (done bool)) error {
+	backlog.lock.Lock()
+	pending := backlog.entries
+	backlog.entries = nil
+	backlog.lock.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	remaining := make([]BacklogEntry, 0)
+	for _, entry := range pending {
+		if !submit(entry) {
+			remaining = append(remaining, entry)
+		}
+	}
+
+	backlog.lock.Lock()
+	defer backlog.lock.Unlock()
+	backlog.entries = append(remaining, backlog.entries...)
+	if len(backlog.entries) > maxBacklog {
+		backlog.entries = backlog.entries[len(backlog.entries)-maxBacklog:]
+	}
+	return backlog.save()
+}
+
+// save rewrites the whole file. The backlog is small and this way a crash
+// mid-write leaves the old file in place.
+func (backlog *Backlog) save() error {
+	if len(backlog.entries) == 0 {
+		err := os.Remove(backlog.path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(backlog.path), 0o700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(backlog.path), ".scrobbles-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	for _, entry := range backlog.entries {
+		if err := encoder.Encode(entry); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), backlog.path)
+}
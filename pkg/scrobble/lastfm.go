@@ -0,0 +1,193 @@
+package scrobble
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dghubble/sling"
+)
+
+const (
+	lastFMURL     = "https://ws.audioscrobbler.com/2.0/"
+	lastFMAuthURL = "https://www.last.fm/api/auth/"
+)
+
+// LastFM scrobbles through the Last.fm 2.0 API. APIKey and Secret come from
+// https://www.last.fm/api/account/create and SessionKey from the desktop
+// auth flow (see Authorize and Session). URL defaults to the public API.
+type LastFM struct {
+	APIKey     string
+	Secret     string
+	SessionKey string
+	URL        string
+}
+
+type (
+	lastFMError struct {
+		Code    int    `json:"error"`
+		Message string `json:"message"`
+	}
+
+	lastFMToken struct {
+		lastFMError
+		Token string `json:"token"`
+	}
+
+	lastFMSession struct {
+		lastFMError
+		Session struct {
+			Name string `json:"name"`
+			Key  string `json:"key"`
+		} `json:"session"`
+	}
+)
+
+func (err lastFMError) Error() string {
+	return fmt.Sprintf("last.fm error %d: %s", err.Code, err.Message)
+}
+
+func (err lastFMError) err() error {
+	if err.Code != 0 {
+		return err
+	}
+	return nil
+}
+
+func (scrobbler LastFM) Name() string {
+	return "lastfm"
+}
+
+func (scrobbler LastFM) NowPlaying(listen Listen) error {
+	params := scrobbler.trackParams(listen)
+	params.Set("method", "track.updateNowPlaying")
+
+	var resp lastFMError
+	if err := scrobbler.call(params, &resp); err != nil {
+		return err
+	}
+	return resp.err()
+}
+
+func (scrobbler LastFM) Submit(listen Listen) error {
+	params := scrobbler.trackParams(listen)
+	params.Set("method", "track.scrobble")
+	params.Set("timestamp", strconv.FormatInt(listen.StartedAt.Unix(), 10))
+
+	var resp lastFMError
+	if err := scrobbler.call(params, &resp); err != nil {
+		return err
+	}
+	return resp.err()
+}
+
+func (scrobbler LastFM) trackParams(listen Listen) url.Values {
+	params := url.Values{}
+	params.Set("sk", scrobbler.SessionKey)
+	params.Set("artist", listen.Song.Artist)
+	params.Set("track", listen.Song.Title)
+	if listen.Song.Album != "" {
+		params.Set("album", listen.Song.Album)
+	}
+	if listen.Song.Track > 0 {
+		params.Set("trackNumber", strconv.Itoa(listen.Song.Track))
+	}
+	if listen.Song.MusicBrainzID != "" {
+		params.Set("mbid", listen.Song.MusicBrainzID)
+	}
+	if listen.Length > 0 {
+		params.Set("duration", strconv.Itoa(int(listen.Length.Seconds())))
+	}
+	return params
+}
+
+// Authorize starts the desktop auth flow. The user needs to visit the
+// returned url and approve hedgehog before Session is called with the token.
+func (scrobbler LastFM) Authorize() (token string, authURL string, err error) {
+	params := url.Values{}
+	params.Set("method", "auth.getToken")
+
+	var resp lastFMToken
+	if err := scrobbler.call(params, &resp); err != nil {
+		return "", "", err
+	}
+	if err := resp.err(); err != nil {
+		return "", "", err
+	}
+
+	authURL = fmt.Sprintf("%s?api_key=%s&token=%s",
+		lastFMAuthURL,
+		url.QueryEscape(scrobbler.APIKey),
+		url.QueryEscape(resp.Token),
+	)
+	return resp.Token, authURL, nil
+}
+
+// Session trades an approved token for a session key, which doesn't expire
+func (scrobbler LastFM) Session(token string) (user string, key string, err error) {
+	params := url.Values{}
+	params.Set("method", "auth.getSession")
+	params.Set("token", token)
+
+	var resp lastFMSession
+	if err := scrobbler.call(params, &resp); err != nil {
+		return "", "", err
+	}
+	if err := resp.err(); err != nil {
+		return "", "", err
+	}
+	return resp.Session.Name, resp.Session.Key, nil
+}
+
+// call signs and posts a request. Last.fm reports most failures as a 200
+// (or 4xx) with an error body, so the body is decoded either way.
+func (scrobbler LastFM) call(params url.Values, resp interface{}) error {
+	base := scrobbler.URL
+	if base == "" {
+		base = lastFMURL
+	}
+
+	params.Set("api_key", scrobbler.APIKey)
+	params.Set("api_sig", scrobbler.sign(params))
+	params.Set("format", "json")
+
+	_, err := sling.New().
+		Client(httpClient).
+		Post(base).
+		Set("User-Agent", fmt.Sprintf("%s/%s", clientName, version)).
+		Set("Content-Type", "application/x-www-form-urlencoded").
+		Body(strings.NewReader(params.Encode())).
+		Receive(resp, resp)
+	return err
+}
+
+// sign implements https://www.last.fm/api/authspec#_8-signing-calls: every
+// parameter except format and callback, sorted by name, concatenated as
+// name then value, followed by the shared secret, md5'd
+func (scrobbler LastFM) sign(params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		if key == "format" || key == "callback" || key == "api_sig" {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var builder strings.Builder
+	for _, key := range keys {
+		builder.WriteString(key)
+		builder.WriteString(params.Get(key))
+	}
+	builder.WriteString(scrobbler.Secret)
+
+	sum := md5.Sum([]byte(builder.String()))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,93 @@
+package scrobble
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/sonic"
+)
+
+var testListen = Listen{
+	Song: sonic.Song{
+		ID:     "1204",
+		Artist: "Aphex Twin",
+		Title:  "Windowlicker",
+		Album:  "Windowlicker",
+		Track:  1,
+	},
+	StartedAt: time.Date(2024, 5, 17, 23, 12, 9, 0, time.UTC),
+	Played:    6 * time.Minute,
+	Length:    367 * time.Second,
+}
+
+func TestLastFMSubmit(t *testing.T) {
+	scrobbler := LastFM{APIKey: "key", Secret: "secret", SessionKey: "session"}
+
+	var got url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		got = r.PostForm
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"scrobbles":{"@attr":{"accepted":1,"ignored":0}}}`))
+	}))
+	defer server.Close()
+	scrobbler.URL = server.URL
+
+	if err := scrobbler.Submit(testListen); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"method":      "track.scrobble",
+		"api_key":     "key",
+		"sk":          "session",
+		"artist":      "Aphex Twin",
+		"track":       "Windowlicker",
+		"album":       "Windowlicker",
+		"trackNumber": "1",
+		"duration":    "367",
+		"timestamp":   strconv.FormatInt(testListen.StartedAt.Unix(), 10),
+		"format":      "json",
+	}
+	for key, value := range want {
+		if got.Get(key) != value {
+			t.Errorf("%s is '%s', want '%s'", key, got.Get(key), value)
+		}
+	}
+	if sig := scrobbler.sign(got); got.Get("api_sig") != sig {
+		t.Errorf("api_sig is '%s', want '%s'", got.Get("api_sig"), sig)
+	}
+}
+
+// Last.fm reports failures in the body, sometimes with a 200
+func TestLastFMError(t *testing.T) {
+	for _, status := range []int{http.StatusOK, http.StatusForbidden} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(status)
+				w.Write([]byte(`{"error":9,"message":"Invalid session key"}`))
+			}))
+			defer server.Close()
+
+			scrobbler := LastFM{APIKey: "key", Secret: "secret", SessionKey: "session", URL: server.URL}
+			err := scrobbler.Submit(testListen)
+			failure, ok := err.(lastFMError)
+			if !ok {
+				t.Fatalf("got %v, want a last.fm error", err)
+			}
+			if failure.Code != 9 {
+				t.Errorf("error code is %d, want 9", failure.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package scrobble
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dghubble/sling"
+)
+
+const listenBrainzURL = "https://api.listenbrainz.org"
+
+// ListenBrainz submits listens with a user token from
+// https://listenbrainz.org/settings/. URL can point at another instance
+// (or a stand-in for testing) and defaults to the public one.
+type ListenBrainz struct {
+	Token string
+	URL   string
+}
+
+type (
+	listenBrainzSubmission struct {
+		ListenType string               `json:"listen_type"`
+		Payload    []listenBrainzListen `json:"payload"`
+	}
+
+	listenBrainzListen struct {
+		ListenedAt int64                 `json:"listened_at,omitempty"`
+		Track      listenBrainzTrackMeta `json:"track_metadata"`
+	}
+
+	listenBrainzTrackMeta struct {
+		Artist     string                 `json:"artist_name"`
+		Track      string                 `json:"track_name"`
+		Release    string                 `json:"release_name,omitempty"`
+		Additional map[string]interface{} `json:"additional_info,omitempty"`
+	}
+
+	listenBrainzError struct {
+		Code    int    `json:"code"`
+		Message string `json:"error"`
+	}
+)
+
+func (err listenBrainzError) Error() string {
+	return fmt.Sprintf("listenbrainz error %d: %s", err.Code, err.Message)
+}
+
+func (scrobbler ListenBrainz) Name() string {
+	return "listenbrainz"
+}
+
+func (scrobbler ListenBrainz) NowPlaying(listen Listen) error {
+	return scrobbler.submit("playing_now", listenBrainzListen{
+		Track: scrobbler.meta(listen),
+	})
+}
+
+func (scrobbler ListenBrainz) Submit(listen Listen) error {
+	return scrobbler.submit("single", listenBrainzListen{
+		ListenedAt: listen.StartedAt.Unix(),
+		Track:      scrobbler.meta(listen),
+	})
+}
+
+func (scrobbler ListenBrainz) meta(listen Listen) listenBrainzTrackMeta {
+	additional := map[string]interface{}{
+		"submission_client":         clientName,
+		"submission_client_version": version,
+	}
+	if listen.Song.Track > 0 {
+		additional["tracknumber"] = listen.Song.Track
+	}
+	if listen.Song.DiscNumber > 0 {
+		additional["discnumber"] = listen.Song.DiscNumber
+	}
+	if listen.Song.MusicBrainzID != "" {
+		additional["recording_mbid"] = listen.Song.MusicBrainzID
+	}
+	if len(listen.Song.Artists) > 1 {
+		names := make([]string, 0, len(listen.Song.Artists))
+		for _, artist := range listen.Song.Artists {
+			names = append(names, artist.Name)
+		}
+		additional["artist_names"] = names
+	}
+	if listen.Length > 0 {
+		additional["duration_ms"] = listen.Length.Milliseconds()
+	}
+
+	return listenBrainzTrackMeta{
+		Artist:     listen.Song.Artist,
+		Track:      listen.Song.Title,
+		Release:    listen.Song.Album,
+		Additional: additional,
+	}
+}
+
+func (scrobbler ListenBrainz) submit(listenType string, listen listenBrainzListen) error {
+	base := scrobbler.URL
+	if base == "" {
+		base = listenBrainzURL
+	}
+
+	var failure listenBrainzError
+	resp, err := sling.New().
+		Client(httpClient).
+		Post(strings.TrimSuffix(base, "/")+"/1/submit-listens").
+		Set("Authorization", "Token "+scrobbler.Token).
+		Set("User-Agent", fmt.Sprintf("%s/%s", clientName, version)).
+		BodyJSON(listenBrainzSubmission{
+			ListenType: listenType,
+			Payload:    []listenBrainzListen{listen},
+		}).
+		Receive(nil, &failure)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		if failure.Code == 0 {
+			failure.Code = resp.StatusCode
+			failure.Message = resp.Status
+		}
+		return failure
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package scrobble
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestListenBrainzSubmit(t *testing.T) {
+	var (
+		auth string
+		got  listenBrainzSubmission
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/1/submit-listens" {
+			t.Errorf("posted to %s", r.URL.Path)
+		}
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer server.Close()
+
+	scrobbler := ListenBrainz{Token: "token", URL: server.URL + "/"}
+	if err := scrobbler.Submit(testListen); err != nil {
+		t.Fatal(err)
+	}
+
+	if auth != "Token token" {
+		t.Errorf("authorization is '%s'", auth)
+	}
+	if got.ListenType != "single" || len(got.Payload) != 1 {
+		t.Fatalf("got %+v, want a single listen", got)
+	}
+	listen := got.Payload[0]
+	if listen.ListenedAt != testListen.StartedAt.Unix() {
+		t.Errorf("listened_at is %d, want %d", listen.ListenedAt, testListen.StartedAt.Unix())
+	}
+	if listen.Track.Artist != "Aphex Twin" || listen.Track.Track != "Windowlicker" || listen.Track.Release != "Windowlicker" {
+		t.Errorf("track is %+v", listen.Track)
+	}
+	if ms := listen.Track.Additional["duration_ms"]; ms != float64(367000) {
+		t.Errorf("duration_ms is %v, want 367000", ms)
+	}
+}
+
+func TestListenBrainzNowPlaying(t *testing.T) {
+	var got listenBrainzSubmission
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer server.Close()
+
+	scrobbler := ListenBrainz{Token: "token", URL: server.URL}
+	if err := scrobbler.NowPlaying(testListen); err != nil {
+		t.Fatal(err)
+	}
+	if got.ListenType != "playing_now" || len(got.Payload) != 1 || got.Payload[0].ListenedAt != 0 {
+		t.Errorf("got %+v, want playing_now without a listen time", got)
+	}
+}
+
+func TestListenBrainzError(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{name: "with a body", body: `{"code":401,"error":"Invalid authorization token."}`, code: 401},
+		{name: "without one", code: 401},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(test.body))
+			}))
+			defer server.Close()
+
+			err := ListenBrainz{Token: "bad", URL: server.URL}.Submit(testListen)
+			failure, ok := err.(listenBrainzError)
+			if !ok {
+				t.Fatalf("got %v, want a listenbrainz error", err)
+			}
+			if failure.Code != test.code {
+				t.Errorf("error code is %d, want %d", failure.Code, test.code)
+			}
+		})
+	}
+}
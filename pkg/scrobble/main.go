@@ -0,0 +1,335 @@
+package scrobble
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/sonic"
+)
+
+const (
+	version    = "0.0.1"
+	clientName = "hedgehog"
+
+	// requestTimeout bounds each call to last.fm or ListenBrainz, so a
+	// service that stops answering can't hold up quitting
+	requestTimeout = 30 * time.Second
+)
+
+var httpClient = &http.Client{Timeout: requestTimeout}
+
+type (
+	// Listen is one play of a song. StartedAt is what services record as
+	// the listen time. Played is how far into the track we got.
+	Listen struct {
+		Song      sonic.Song    `json:"song"`
+		StartedAt time.Time     `json:"startedAt"`
+		Played    time.Duration `json:"played"`
+		Length    time.Duration `json:"length"`
+	}
+
+	Scrobbler interface {
+		Name() string
+		NowPlaying(listen Listen) error
+		Submit(listen Listen) error
+	}
+
+	// Rule decides whether a listen counts. The usual rule, shared by
+	// Last.fm and ListenBrainz, is that the track is longer than 30 seconds
+	// and was played for half its length or four minutes, whichever comes
+	// first.
+	Rule struct {
+		Percent  float64
+		Cap      time.Duration
+		MinTrack time.Duration
+	}
+
+	Config struct {
+		// Services lists which scrobblers to use: subsonic, listenbrainz
+		// and lastfm
+		Services []string
+
+		// Threshold is the percentage of a track that has to be played for
+		// it to count. Zero means the standard 50%.
+		Threshold float64
+
+		ListenBrainz ListenBrainz
+		LastFM       LastFM
+
+		// BacklogFile holds scrobbles that failed and will be retried.
+		// Empty means the default in the state dir.
+		BacklogFile string
+	}
+
+	Manager struct {
+		Rule Rule
+
+		// While Offline, listens go straight to the backlog
+		Offline bool
+
+		scrobblers []Scrobbler
+		backlog    *Backlog
+		lock       sync.Mutex
+		flushing   sync.Mutex
+
+		// running counts submissions and retries still in flight. Once
+		// closed, new listens go straight to the backlog and retries stop.
+		running sync.WaitGroup
+		closing sync.Mutex
+		closed  bool
+		stop    chan struct{}
+	}
+)
+
+var DefaultRule = Rule{
+	Percent:  50,
+	Cap:      4 * time.Minute,
+	MinTrack: 30 * time.Second,
+}
+
+func (listen Listen) String() string {
+	return fmt.Sprintf("%s - %s @ %s", listen.Song.Artist, listen.Song.Title, listen.StartedAt.Format(time.RFC3339))
+}
+
+// Eligible applies the rule to a listen. If we don't know how long the
+// track is, we fall back to what the server told us.
+func (rule Rule) Eligible(listen Listen) bool {
+	length := listen.Length
+	if length <= 0 {
+		length = time.Duration(listen.Song.Duration) * time.Second
+	}
+	if length <= 0 || length < rule.MinTrack {
+		return false
+	}
+
+	needed := time.Duration(float64(length) * rule.Percent / 100)
+	if rule.Cap > 0 && needed > rule.Cap {
+		needed = rule.Cap
+	}
+	return listen.Played >= needed
+}
+
+// New builds a manager for the configured services. The subsonic client is
+// only used if "subsonic" is one of them.
+func New(config Config, client *sonic.Sonic, stateDir string) (*Manager, error) {
+	manager := Manager{Rule: DefaultRule, stop: make(chan struct{})}
+	if config.Threshold > 0 {
+		manager.Rule.Percent = config.Threshold
+	}
+
+	for _, name := range config.Services {
+		switch name {
+		case "subsonic":
+			manager.scrobblers = append(manager.scrobblers, Subsonic{Client: client})
+		case "listenbrainz":
+			if config.ListenBrainz.Token == "" {
+				return nil, fmt.Errorf("listenbrainz scrobbling needs a user token")
+			}
+			manager.scrobblers = append(manager.scrobblers, config.ListenBrainz)
+		case "lastfm":
+			if config.LastFM.APIKey == "" || config.LastFM.Secret == "" || config.LastFM.SessionKey == "" {
+				return nil, fmt.Errorf("last.fm scrobbling needs an api key, secret and session key")
+			}
+			manager.scrobblers = append(manager.scrobblers, config.LastFM)
+		case "":
+		default:
+			return nil, fmt.Errorf("unknown scrobbler '%s'", name)
+		}
+	}
+
+	path := config.BacklogFile
+	if path == "" {
+		path = filepath.Join(stateDir, "scrobbles.jsonl")
+	}
+	backlog, err := OpenBacklog(path)
+	if err != nil {
+		return nil, err
+	}
+	manager.backlog = backlog
+
+	return &manager, nil
+}
+
+func (manager *Manager) scrobbler(name string) Scrobbler {
+	for _, scrobbler := range manager.scrobblers {
+		if scrobbler.Name() == name {
+			return scrobbler
+		}
+	}
+	return nil
+}
+
+// NowPlaying tells every service what just started. Failures aren't
+// queued since a stale now playing notice is worse than none.
+func (manager *Manager) NowPlaying(listen Listen) {
+	if manager.Offline {
+		return
+	}
+	for _, scrobbler := range manager.scrobblers {
+		go func(scrobbler Scrobbler) {
+			if err := scrobbler.NowPlaying(listen); err != nil {
+				slog.Warn("now playing failed", "service", scrobbler.Name(), "err", err)
+			}
+		}(scrobbler)
+	}
+}
+
+// Submit records the listen with every service if it passes the rule.
+// Anything that fails goes in the backlog, and a success is taken as a
+// sign that it's worth retrying the backlog.
+func (manager *Manager) Submit(listen Listen) {
+	if !manager.Rule.Eligible(listen) {
+		return
+	}
+
+	started := !manager.Offline && manager.background(func() {
+		if manager.submit(listen) {
+			manager.flush()
+		}
+	})
+	if started {
+		return
+	}
+	for _, scrobbler := range manager.scrobblers {
+		if err := manager.backlog.Add(scrobbler.Name(), listen); err != nil {
+			slog.Error("saving scrobble failed", "service", scrobbler.Name(), "err", err)
+		}
+	}
+}
+
+// submit sends a listen to every service, returning whether any took it
+func (manager *Manager) submit(listen Listen) bool {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+
+	succeeded := false
+	for _, scrobbler := range manager.scrobblers {
+		err := scrobbler.Submit(listen)
+		switch {
+		case err == nil:
+			slog.Info("scrobbled", "service", scrobbler.Name(), "title", listen.Song.Title)
+			succeeded = true
+		case permanent(err):
+			slog.Warn("scrobble refused", "service", scrobbler.Name(), "title", listen.Song.Title, "err", err)
+		default:
+			slog.Warn("scrobble failed, saving for later", "service", scrobbler.Name(), "title", listen.Song.Title, "err", err)
+			if err := manager.backlog.Add(scrobbler.Name(), listen); err != nil {
+				slog.Error("saving scrobble failed", "service", scrobbler.Name(), "err", err)
+			}
+		}
+	}
+	return succeeded
+}
+
+// background runs fn in a goroutine that Close waits for, unless the
+// manager is already closed
+func (manager *Manager) background(fn func()) bool {
+	manager.closing.Lock()
+	defer manager.closing.Unlock()
+	if manager.closed {
+		return false
+	}
+
+	manager.running.Add(1)
+	go func() {
+		defer manager.running.Done()
+		fn()
+	}()
+	return true
+}
+
+// Close stops retrying the backlog and waits for submissions that are
+// still going, so a listen that ends as the player quits is either sent
+// or saved in the backlog. Anything submitted after Close goes to the
+// backlog.
+func (manager *Manager) Close() {
+	manager.closing.Lock()
+	if !manager.closed {
+		manager.closed = true
+		close(manager.stop)
+	}
+	manager.closing.Unlock()
+	manager.running.Wait()
+}
+
+// Flush retries the backlog in the background
+func (manager *Manager) Flush() {
+	manager.background(manager.flush)
+}
+
+// flush retries the backlog, unless that's already underway. A service
+// that can't be reached (or won't let us in) isn't tried again until the
+// next flush, and listens a service refuses outright are dropped.
+func (manager *Manager) flush() {
+	if !manager.flushing.TryLock() {
+		return
+	}
+	defer manager.flushing.Unlock()
+
+	down := make(map[string]bool)
+	err := manager.backlog.Retry(func(entry BacklogEntry) bool {
+		select {
+		case <-manager.stop:
+			return false
+		default:
+		}
+
+		scrobbler := manager.scrobbler(entry.Service)
+		if scrobbler == nil || down[entry.Service] {
+			// A service that isn't configured this time around keeps its
+			// entries for a run where it is
+			return false
+		}
+
+		err := scrobbler.Submit(entry.Listen)
+		switch {
+		case err == nil:
+			return true
+		case permanent(err):
+			slog.Warn("dropping scrobble the service refused", "service", entry.Service, "title", entry.Listen.Song.Title, "err", err)
+			return true
+		default:
+			slog.Debug("retrying scrobbles failed, leaving the rest for later", "service", entry.Service, "title", entry.Listen.Song.Title, "err", err)
+			down[entry.Service] = true
+			return false
+		}
+	})
+	if err != nil {
+		slog.Error("saving scrobble backlog failed", "err", err)
+	}
+}
+
+// permanent tells a service refusing a listen, which retrying won't
+// change, apart from the service being down, busy or not letting us in
+func permanent(err error) bool {
+	var (
+		lastfm lastFMError
+		brainz listenBrainzError
+		server sonic.ErrorResponse
+	)
+	switch {
+	case errors.As(err, &lastfm):
+		// Invalid parameters and invalid resource, see
+		// https://www.last.fm/api/errorcodes
+		return lastfm.Code == 6 || lastfm.Code == 7
+	case errors.As(err, &brainz):
+		return brainz.Code == http.StatusBadRequest || brainz.Code == http.StatusRequestEntityTooLarge
+	case errors.As(err, &server):
+		// Missing parameter and not found
+		return server.Code == 10 || server.Code == 70
+	}
+	return false
+}
+
+// Pending is the number of scrobbles waiting to be retried
+func (manager *Manager) Pending() int {
+	return manager.backlog.Len()
+}
@@ -0,0 +1,299 @@
+package scrobble
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/sonic"
+)
+
+// flaky stands in for ListenBrainz, refusing listens with status until
+// it's told to take them
+type flaky struct {
+	lock     sync.Mutex
+	up       bool
+	status   int
+	delay    time.Duration
+	requests int
+	accepted int
+}
+
+func (service *flaky) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	service.lock.Lock()
+	service.requests++
+	up, status, delay := service.up, service.status, service.delay
+	if up {
+		service.accepted++
+	}
+	service.lock.Unlock()
+
+	time.Sleep(delay)
+	if !up {
+		if status == 0 {
+			status = http.StatusServiceUnavailable
+		}
+		w.WriteHeader(status)
+		return
+	}
+	w.Write([]byte(`{"status":"ok"}`))
+}
+
+func (service *flaky) set(up bool) {
+	service.lock.Lock()
+	defer service.lock.Unlock()
+	service.up = up
+}
+
+func (service *flaky) count() int {
+	service.lock.Lock()
+	defer service.lock.Unlock()
+	return service.accepted
+}
+
+func (service *flaky) tries() int {
+	service.lock.Lock()
+	defer service.lock.Unlock()
+	return service.requests
+}
+
+func newManager(t *testing.T, url string, path string) *Manager {
+	t.Helper()
+	manager, err := New(Config{
+		Services:     []string{"listenbrainz"},
+		ListenBrainz: ListenBrainz{Token: "token", URL: url},
+		BacklogFile:  path,
+	}, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return manager
+}
+
+// backlogOf saves count listens for ListenBrainz to retry
+func backlogOf(t *testing.T, count int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "scrobbles.jsonl")
+	backlog, err := OpenBacklog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for idx := 0; idx < count; idx++ {
+		if err := backlog.Add("listenbrainz", testListen); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestBacklogRetry(t *testing.T) {
+	service := &flaky{}
+	server := httptest.NewServer(service)
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "scrobbles.jsonl")
+	manager := newManager(t, server.URL, path)
+
+	// Failures are saved for later
+	manager.Submit(testListen)
+	manager.Submit(testListen)
+	manager.Close()
+	if manager.Pending() != 2 {
+		t.Fatalf("%d pending, want 2", manager.Pending())
+	}
+
+	// The next run picks them up, and a success sends them along with it
+	manager = newManager(t, server.URL, path)
+	if manager.Pending() != 2 {
+		t.Fatalf("%d saved, want 2", manager.Pending())
+	}
+	service.set(true)
+	manager.Submit(testListen)
+	manager.running.Wait()
+	manager.Close()
+	if manager.Pending() != 0 {
+		t.Errorf("%d still pending", manager.Pending())
+	}
+	if service.count() != 3 {
+		t.Errorf("%d accepted, want 3", service.count())
+	}
+}
+
+// A service that's down is tried once per flush, not once per listen
+func TestFlushGivesUpOnAServiceThatIsDown(t *testing.T) {
+	service := &flaky{}
+	server := httptest.NewServer(service)
+	defer server.Close()
+
+	manager := newManager(t, server.URL, backlogOf(t, 50))
+	manager.Flush()
+	manager.running.Wait()
+	if service.tries() != 1 {
+		t.Errorf("tried %d times, want 1", service.tries())
+	}
+	if manager.Pending() != 50 {
+		t.Errorf("%d pending, want 50", manager.Pending())
+	}
+
+	// Or one that won't let us in
+	service.lock.Lock()
+	service.status = http.StatusUnauthorized
+	service.lock.Unlock()
+	manager.Flush()
+	manager.running.Wait()
+	manager.Close()
+	if service.tries() != 2 || manager.Pending() != 50 {
+		t.Errorf("tried %d times with %d pending, want 2 and 50", service.tries(), manager.Pending())
+	}
+}
+
+func TestFlushDropsRefusedListens(t *testing.T) {
+	service := &flaky{status: http.StatusBadRequest}
+	server := httptest.NewServer(service)
+	defer server.Close()
+
+	manager := newManager(t, server.URL, backlogOf(t, 3))
+	manager.Flush()
+	manager.running.Wait()
+	manager.Close()
+	if service.tries() != 3 {
+		t.Errorf("tried %d times, want 3", service.tries())
+	}
+	if manager.Pending() != 0 {
+		t.Errorf("%d pending, want none", manager.Pending())
+	}
+
+	// Nor are new ones kept
+	manager = newManager(t, server.URL, filepath.Join(t.TempDir(), "scrobbles.jsonl"))
+	manager.Submit(testListen)
+	manager.Close()
+	if manager.Pending() != 0 {
+		t.Errorf("%d pending, want none", manager.Pending())
+	}
+}
+
+// Quitting doesn't wait for a slow service to work through the backlog,
+// and nothing is lost by stopping partway
+func TestCloseStopsFlush(t *testing.T) {
+	service := &flaky{up: true, delay: 20 * time.Millisecond}
+	server := httptest.NewServer(service)
+	defer server.Close()
+
+	path := backlogOf(t, 100)
+	manager := newManager(t, server.URL, path)
+	manager.Flush()
+	time.Sleep(50 * time.Millisecond)
+
+	started := time.Now()
+	manager.Close()
+	if waited := time.Since(started); waited > time.Second {
+		t.Errorf("Close took %s", waited)
+	}
+
+	sent := service.count()
+	if sent == 0 || sent == 100 {
+		t.Fatalf("%d sent, want some but not all", sent)
+	}
+	if manager.Pending()+sent != 100 {
+		t.Errorf("%d sent and %d pending, want 100 between them", sent, manager.Pending())
+	}
+
+	reopened, err := OpenBacklog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.Len() != manager.Pending() {
+		t.Errorf("%d saved, want %d", reopened.Len(), manager.Pending())
+	}
+}
+
+// Listens that fail while the backlog is being retried aren't lost
+func TestRetryKeepsNewEntries(t *testing.T) {
+	backlog, err := OpenBacklog(backlogOf(t, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = backlog.Retry(func(entry BacklogEntry) bool {
+		if err := backlog.Add("lastfm", testListen); err != nil {
+			t.Fatal(err)
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backlog.Len() != 2 {
+		t.Errorf("%d left, want 2", backlog.Len())
+	}
+	for _, entry := range backlog.entries {
+		if entry.Service != "lastfm" {
+			t.Errorf("kept a %s entry", entry.Service)
+		}
+	}
+}
+
+func TestPermanent(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("connection refused"), false},
+		{lastFMError{Code: 6, Message: "Invalid parameters"}, true},
+		{lastFMError{Code: 9, Message: "Invalid session key"}, false},
+		{lastFMError{Code: 11, Message: "Service Offline"}, false},
+		{lastFMError{Code: 29, Message: "Rate Limit Exceeded"}, false},
+		{listenBrainzError{Code: 400, Message: "bad listen"}, true},
+		{listenBrainzError{Code: 401, Message: "bad token"}, false},
+		{listenBrainzError{Code: 429, Message: "slow down"}, false},
+		{listenBrainzError{Code: 503, Message: "down"}, false},
+		{sonic.ErrorResponse{Code: 70, Message: "not found"}, true},
+		{sonic.ErrorResponse{Code: 40, Message: "wrong password"}, false},
+		{fmt.Errorf("wrapped: %w", listenBrainzError{Code: 400}), true},
+	}
+	for _, test := range tests {
+		if got := permanent(test.err); got != test.want {
+			t.Errorf("%s: permanent is %v", test.err, got)
+		}
+	}
+}
+
+func TestOfflineAndClosed(t *testing.T) {
+	service := &flaky{up: true}
+	server := httptest.NewServer(service)
+	defer server.Close()
+
+	manager := newManager(t, server.URL, filepath.Join(t.TempDir(), "scrobbles.jsonl"))
+	manager.Offline = true
+	manager.Submit(testListen)
+	if manager.Pending() != 1 {
+		t.Errorf("%d pending while offline, want 1", manager.Pending())
+	}
+
+	manager.Offline = false
+	manager.Close()
+	manager.Submit(testListen)
+	manager.Flush()
+	if manager.Pending() != 2 {
+		t.Errorf("%d pending after close, want 2", manager.Pending())
+	}
+	if service.count() != 0 {
+		t.Errorf("%d sent, want none", service.count())
+	}
+
+	// Too short a listen isn't kept at all
+	short := testListen
+	short.Played = 0
+	manager.Submit(short)
+	if manager.Pending() != 2 {
+		t.Errorf("%d pending after a short listen, want 2", manager.Pending())
+	}
+}
@@ -0,0 +1,26 @@
+package scrobble
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"git.sr.ht/~sungo/hedgehog/pkg/sonic"
+)
+
+// Subsonic scrobbles to the server we're playing from, which in turn may
+// forward to whatever the server is configured for
+type Subsonic struct {
+	Client *sonic.Sonic
+}
+
+func (scrobbler Subsonic) Name() string {
+	return "subsonic"
+}
+
+func (scrobbler Subsonic) NowPlaying(listen Listen) error {
+	return scrobbler.Client.ScrobbleNowPlaying(listen.Song)
+}
+
+func (scrobbler Subsonic) Submit(listen Listen) error {
+	return scrobbler.Client.ScrobbleSubmit(listen.Song, listen.StartedAt)
+}
@@ -0,0 +1,265 @@
+package sonic
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// The kinds of album lists getAlbumList2 knows
+const (
+	AlbumListRandom       = "random"
+	AlbumListNewest       = "newest"
+	AlbumListHighest      = "highest"
+	AlbumListFrequent     = "frequent"
+	AlbumListRecent       = "recent"
+	AlbumListAlphabetical = "alphabeticalByName"
+	AlbumListByArtist     = "alphabeticalByArtist"
+	AlbumListByYear       = "byYear"
+	AlbumListByGenre      = "byGenre"
+	AlbumListStarred      = "starred"
+)
+
+var AlbumListTypes = []string{
+	AlbumListRandom,
+	AlbumListNewest,
+	AlbumListHighest,
+	AlbumListFrequent,
+	AlbumListRecent,
+	AlbumListAlphabetical,
+	AlbumListByArtist,
+	AlbumListByYear,
+	AlbumListByGenre,
+	AlbumListStarred,
+}
+
+type (
+	// AlbumList picks which albums getAlbumList2 returns. FromYear and
+	// ToYear are for byYear, where a ToYear before FromYear lists the
+	// newest first. Genre is for byGenre.
+	AlbumList struct {
+		Type     string
+		FromYear int
+		ToYear   int
+		Genre    string
+	}
+
+	Album struct {
+		ID        string `json:"id"`
+		Name      string `json:"name"`
+		Artist    string `json:"artist"`
+		ArtistID  string `json:"artistId"`
+		CoverArt  string `json:"coverArt"`
+		SongCount int    `json:"songCount"`
+		Year      int    `json:"year"`
+		Genre     string `json:"genre"`
+		Created   string `json:"created"`
+		Starred   string `json:"starred"`
+		PlayCount int    `json:"playCount"`
+
+		// Duration is in seconds
+		Duration int `json:"duration"`
+
+		// Songs are only filled in by GetAlbum
+		Songs Songs `json:"song"`
+	}
+	Albums []Album
+
+	GetAlbumResponseWrapper struct {
+		Response GetAlbumResponse `json:"subsonic-response"`
+	}
+
+	GetAlbumResponse struct {
+		StatusResponse
+		Album Album `json:"album"`
+	}
+
+	GetAlbumList2ResponseWrapper struct {
+		Response GetAlbumList2Response `json:"subsonic-response"`
+	}
+
+	GetAlbumList2Response struct {
+		StatusResponse
+		AlbumList struct {
+			Albums Albums `json:"album"`
+		} `json:"albumList2"`
+	}
+
+	GetSongsByGenreResponseWrapper struct {
+		Response GetSongsByGenreResponse `json:"subsonic-response"`
+	}
+
+	GetSongsByGenreResponse struct {
+		StatusResponse
+		SongsByGenre struct {
+			Songs Songs `json:"song"`
+		} `json:"songsByGenre"`
+	}
+)
+
+// AlbumListType finds a list type by name, ignoring case
+func AlbumListType(name string) (string, bool) {
+	for _, listType := range AlbumListTypes {
+		if strings.EqualFold(listType, name) {
+			return listType, true
+		}
+	}
+	return "", false
+}
+
+func (list AlbumList) Validate() error {
+	if _, ok := AlbumListType(list.Type); !ok {
+		return fmt.Errorf("unknown album list '%s'. Try one of %s", list.Type, strings.Join(AlbumListTypes, ", "))
+	}
+
+	switch {
+	case strings.EqualFold(list.Type, AlbumListByYear) && (list.FromYear == 0 || list.ToYear == 0):
+		return errors.New("albums by year need a range of years, like 1990-1999")
+	case strings.EqualFold(list.Type, AlbumListByGenre) && list.Genre == "":
+		return errors.New("albums by genre need a genre")
+	}
+	return nil
+}
+
+func (list AlbumList) String() string {
+	listType, _ := AlbumListType(list.Type)
+	switch listType {
+	case AlbumListByYear:
+		return fmt.Sprintf("albums from %d to %d", list.FromYear, list.ToYear)
+	case AlbumListByGenre:
+		return fmt.Sprintf("%s albums", list.Genre)
+	case AlbumListAlphabetical:
+		return "albums by name"
+	case AlbumListByArtist:
+		return "albums by artist"
+	}
+	return fmt.Sprintf("%s albums", listType)
+}
+
+// GetAlbumList2 lists albums, organized by id3 tags
+func (client Sonic) GetAlbumList2(list AlbumList, size int, offset int) (Albums, error) {
+	if err := list.Validate(); err != nil {
+		return nil, err
+	}
+	listType, _ := AlbumListType(list.Type)
+
+	var resp GetAlbumList2ResponseWrapper
+
+	params := struct {
+		Format   string `url:"f"`
+		User     string `url:"u"`
+		Password string `url:"p"`
+		ClientID string `url:"c"`
+		Type     string `url:"type"`
+		Size     int    `url:"size"`
+		Offset   int    `url:"offset"`
+		FromYear int    `url:"fromYear,omitempty"`
+		ToYear   int    `url:"toYear,omitempty"`
+		Genre    string `url:"genre,omitempty"`
+	}{
+		client.format(), client.auth.User, client.auth.Password, clientID,
+		listType, size, offset, list.FromYear, list.ToYear, list.Genre,
+	}
+
+	_, err := client.sling().New().
+		Post(client.url("rest/getAlbumList2")).
+		BodyForm(params).
+		ReceiveSuccess(&resp)
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.Response.Err(); err != nil {
+		return nil, err
+	}
+
+	return resp.Response.AlbumList.Albums, nil
+}
+
+// GetAlbum fetches an album and its songs
+func (client Sonic) GetAlbum(id string) (Album, error) {
+	var resp GetAlbumResponseWrapper
+
+	params := struct {
+		Format   string `url:"f"`
+		User     string `url:"u"`
+		Password string `url:"p"`
+		ClientID string `url:"c"`
+		ID       string `url:"id"`
+	}{client.format(), client.auth.User, client.auth.Password, clientID, id}
+
+	_, err := client.sling().New().
+		Post(client.url("rest/getAlbum")).
+		BodyForm(params).
+		ReceiveSuccess(&resp)
+	if err != nil {
+		return Album{}, err
+	}
+	if err := resp.Response.Err(); err != nil {
+		return Album{}, err
+	}
+
+	return resp.Response.Album, nil
+}
+
+// GetSongsByGenre lists songs tagged with a genre
+func (client Sonic) GetSongsByGenre(genre string, count int, offset int) (Songs, error) {
+	var resp GetSongsByGenreResponseWrapper
+
+	params := struct {
+		Format   string `url:"f"`
+		User     string `url:"u"`
+		Password string `url:"p"`
+		ClientID string `url:"c"`
+		Genre    string `url:"genre"`
+		Count    int    `url:"count"`
+		Offset   int    `url:"offset"`
+	}{client.format(), client.auth.User, client.auth.Password, clientID, genre, count, offset}
+
+	_, err := client.sling().New().
+		Post(client.url("rest/getSongsByGenre")).
+		BodyForm(params).
+		ReceiveSuccess(&resp)
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.Response.Err(); err != nil {
+		return nil, err
+	}
+
+	return resp.Response.SongsByGenre.Songs, nil
+}
+
+// GetCoverArt downloads artwork by the id songs and albums give for it,
+// scaled to size pixels on a side (0 for the original)
+func (client Sonic) GetCoverArt(id string, size int) ([]byte, error) {
+	params := struct {
+		Format   string `url:"f"`
+		User     string `url:"u"`
+		Password string `url:"p"`
+		ClientID string `url:"c"`
+		ID       string `url:"id"`
+		Size     int    `url:"size,omitempty"`
+	}{client.format(), client.auth.User, client.auth.Password, clientID, id, size}
+
+	req, err := client.sling().New().
+		Post(client.url("rest/getCoverArt")).
+		BodyForm(params).
+		Request()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := client.fetch(req)
+	if err != nil {
+		return nil, err
+	}
+	// Missing artwork comes back as a regular error response
+	if !strings.HasPrefix(http.DetectContentType(data), "image/") {
+		return nil, fmt.Errorf("no cover art for '%s'", id)
+	}
+	return data, nil
+}
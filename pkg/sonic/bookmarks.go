@@ -0,0 +1,112 @@
+package sonic
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"time"
+)
+
+type (
+	Bookmark struct {
+		// Position is in milliseconds
+		Position int64  `json:"position"`
+		Comment  string `json:"comment"`
+		Created  string `json:"created"`
+		Changed  string `json:"changed"`
+		Entry    Song   `json:"entry"`
+	}
+	Bookmarks []Bookmark
+
+	GetBookmarksResponseWrapper struct {
+		Response GetBookmarksResponse `json:"subsonic-response"`
+	}
+
+	GetBookmarksResponse struct {
+		StatusResponse
+		Bookmarks struct {
+			Bookmarks Bookmarks `json:"bookmark"`
+		} `json:"bookmarks"`
+	}
+)
+
+// Offset is how far into the entry the bookmark is
+func (bookmark Bookmark) Offset() time.Duration {
+	return time.Duration(bookmark.Position) * time.Millisecond
+}
+
+// GetBookmarks returns the user's bookmarks, by the id of the song or
+// episode they belong to
+func (client Sonic) GetBookmarks() (map[string]Bookmark, error) {
+	var resp GetBookmarksResponseWrapper
+
+	params := struct {
+		Format   string `url:"f"`
+		User     string `url:"u"`
+		Password string `url:"p"`
+		ClientID string `url:"c"`
+	}{client.format(), client.auth.User, client.auth.Password, clientID}
+
+	_, err := client.sling().New().
+		Post(client.url("rest/getBookmarks")).
+		BodyForm(params).
+		ReceiveSuccess(&resp)
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.Response.Err(); err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]Bookmark, len(resp.Response.Bookmarks.Bookmarks))
+	for _, bookmark := range resp.Response.Bookmarks.Bookmarks {
+		data[bookmark.Entry.ID] = bookmark
+	}
+	return data, nil
+}
+
+// CreateBookmark saves a position in a song or episode, replacing any
+// bookmark it already had
+func (client Sonic) CreateBookmark(id string, position time.Duration, comment string) error {
+	var resp StatusResponseWrapper
+
+	params := struct {
+		Format   string `url:"f"`
+		User     string `url:"u"`
+		Password string `url:"p"`
+		ClientID string `url:"c"`
+		ID       string `url:"id"`
+		Position int64  `url:"position"`
+		Comment  string `url:"comment,omitempty"`
+	}{client.format(), client.auth.User, client.auth.Password, clientID, id, position.Milliseconds(), comment}
+
+	_, err := client.sling().New().
+		Post(client.url("rest/createBookmark")).
+		BodyForm(params).
+		ReceiveSuccess(&resp)
+	if err != nil {
+		return err
+	}
+	return resp.Response.Err()
+}
+
+func (client Sonic) DeleteBookmark(id string) error {
+	var resp StatusResponseWrapper
+
+	params := struct {
+		Format   string `url:"f"`
+		User     string `url:"u"`
+		Password string `url:"p"`
+		ClientID string `url:"c"`
+		ID       string `url:"id"`
+	}{client.format(), client.auth.User, client.auth.Password, clientID, id}
+
+	_, err := client.sling().New().
+		Post(client.url("rest/deleteBookmark")).
+		BodyForm(params).
+		ReceiveSuccess(&resp)
+	if err != nil {
+		return err
+	}
+	return resp.Response.Err()
+}
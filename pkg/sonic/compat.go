@@ -0,0 +1,325 @@
+package sonic
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+// Servers don't agree on what a subsonic response looks like. Instead of
+// decoding straight into our types, responses are read into a generic tree,
+// from either JSON or XML, and then copied into our types by their json
+// tags, smoothing over the differences along the way:
+//
+//   - Older servers and some forks only speak XML, where every value is a
+//     string and lists are repeated elements
+//   - Lists with one item arrive as a bare object (original Subsonic's
+//     JSON, and always in XML)
+//   - Ids and counts show up as numbers on some servers, strings on others
+//   - Key case varies ('ID' vs 'id')
+//   - Empty lists can be an empty string
+//
+// Values that still don't fit are left at their zero value rather than
+// failing the whole response.
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// compatDecoder is a sling.ResponseDecoder that accepts JSON or XML
+type compatDecoder struct{}
+
+func (compatDecoder) Decode(resp *http.Response, v interface{}) error {
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return decodeResponse(data, resp.Header.Get("Content-Type"), v)
+}
+
+// DecodingError means the server answered with something that isn't a
+// subsonic response we can read
+type DecodingError struct {
+	Err error
+}
+
+func (err DecodingError) Error() string {
+	return fmt.Sprintf("unreadable response: %s", err.Err)
+}
+
+func (err DecodingError) Unwrap() error {
+	return err.Err
+}
+
+func decodeResponse(data []byte, contentType string, v interface{}) error {
+	var (
+		tree interface{}
+		err  error
+	)
+
+	trimmed := bytes.TrimSpace(data)
+	if strings.Contains(contentType, "xml") || bytes.HasPrefix(trimmed, []byte("<")) {
+		tree, err = xmlTree(trimmed)
+	} else {
+		decoder := json.NewDecoder(bytes.NewReader(trimmed))
+		decoder.UseNumber()
+		err = decoder.Decode(&tree)
+	}
+	if err != nil {
+		return DecodingError{err}
+	}
+
+	return assignTree(tree, v)
+}
+
+// assignTree copies a decoded response into v
+func assignTree(tree interface{}, v interface{}) error {
+	if _, ok := tree.(map[string]interface{}); !ok {
+		return DecodingError{errors.New("expected an object")}
+	}
+
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Pointer || target.IsNil() {
+		return errors.New("decode target must be a non-nil pointer")
+	}
+	assign(tree, target.Elem())
+	return nil
+}
+
+// xmlTree reads an XML document into the same shape encoding/json would
+// give us for the equivalent JSON: attributes and child elements become
+// keys, repeated elements become lists, and text becomes 'value'
+func xmlTree(data []byte) (interface{}, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+
+	type frame struct {
+		name string
+		node map[string]interface{}
+		text strings.Builder
+	}
+	var (
+		stack []*frame
+		root  = map[string]interface{}{}
+	)
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok := token.(type) {
+		case xml.StartElement:
+			node := make(map[string]interface{}, len(tok.Attr))
+			for _, attr := range tok.Attr {
+				if attr.Name.Space == "xmlns" || attr.Name.Local == "xmlns" {
+					continue
+				}
+				node[attr.Name.Local] = attr.Value
+			}
+			stack = append(stack, &frame{name: tok.Name.Local, node: node})
+
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(tok)
+			}
+
+		case xml.EndElement:
+			if len(stack) == 0 {
+				return nil, errors.New("unbalanced xml")
+			}
+			done := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			if text := strings.TrimSpace(done.text.String()); text != "" {
+				done.node["value"] = text
+			}
+
+			parent := root
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1].node
+			}
+			switch existing := parent[done.name].(type) {
+			case nil:
+				parent[done.name] = done.node
+			case []interface{}:
+				parent[done.name] = append(existing, done.node)
+			default:
+				parent[done.name] = []interface{}{existing, done.node}
+			}
+		}
+	}
+
+	return root, nil
+}
+
+var jsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// assign copies a generic tree into a value, converting as needed
+func assign(tree interface{}, value reflect.Value) {
+	if tree == nil || !value.CanSet() {
+		return
+	}
+
+	if value.Kind() != reflect.Pointer && value.Addr().Type().Implements(jsonUnmarshaler) {
+		if data, err := json.Marshal(tree); err == nil {
+			value.Addr().Interface().(json.Unmarshaler).UnmarshalJSON(data)
+		}
+		return
+	}
+
+	switch value.Kind() {
+	case reflect.Pointer:
+		elem := reflect.New(value.Type().Elem())
+		assign(tree, elem.Elem())
+		value.Set(elem)
+
+	case reflect.Interface:
+		value.Set(reflect.ValueOf(tree))
+
+	case reflect.Struct:
+		node, ok := tree.(map[string]interface{})
+		if !ok {
+			return
+		}
+		assignStruct(node, value)
+
+	case reflect.Slice:
+		items, ok := tree.([]interface{})
+		if !ok {
+			if str, isString := tree.(string); isString && str == "" && value.Type().Elem().Kind() != reflect.String {
+				return
+			}
+			items = []interface{}{tree}
+		}
+		slice := reflect.MakeSlice(value.Type(), len(items), len(items))
+		for idx, item := range items {
+			assign(item, slice.Index(idx))
+		}
+		value.Set(slice)
+
+	case reflect.Map:
+		node, ok := tree.(map[string]interface{})
+		if !ok || value.Type().Key().Kind() != reflect.String {
+			return
+		}
+		out := reflect.MakeMapWithSize(value.Type(), len(node))
+		for key, item := range node {
+			elem := reflect.New(value.Type().Elem()).Elem()
+			assign(item, elem)
+			out.SetMapIndex(reflect.ValueOf(key).Convert(value.Type().Key()), elem)
+		}
+		value.Set(out)
+
+	case reflect.String:
+		switch v := tree.(type) {
+		case string:
+			value.SetString(v)
+		case json.Number:
+			value.SetString(v.String())
+		case bool:
+			value.SetString(strconv.FormatBool(v))
+		}
+
+	case reflect.Bool:
+		switch v := tree.(type) {
+		case bool:
+			value.SetBool(v)
+		case string:
+			parsed, _ := strconv.ParseBool(v)
+			value.SetBool(parsed)
+		case json.Number:
+			value.SetBool(v.String() != "0")
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if num, ok := number(tree); ok {
+			value.SetInt(int64(num))
+		}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if num, ok := number(tree); ok && num >= 0 {
+			value.SetUint(uint64(num))
+		}
+
+	case reflect.Float32, reflect.Float64:
+		if num, ok := number(tree); ok {
+			value.SetFloat(num)
+		}
+	}
+}
+
+func assignStruct(node map[string]interface{}, value reflect.Value) {
+	kind := value.Type()
+	for idx := 0; idx < kind.NumField(); idx++ {
+		field := kind.Field(idx)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get("json")
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			continue
+		}
+
+		// Embedded structs without a name share the parent's keys, the way
+		// encoding/json treats them
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			assignStruct(node, value.Field(idx))
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		if item, ok := lookupKey(node, name); ok {
+			assign(item, value.Field(idx))
+		}
+	}
+}
+
+// lookupKey finds a key, exactly if possible and then ignoring case
+func lookupKey(node map[string]interface{}, name string) (interface{}, bool) {
+	if item, ok := node[name]; ok {
+		return item, true
+	}
+	for key, item := range node {
+		if strings.EqualFold(key, name) {
+			return item, true
+		}
+	}
+	return nil, false
+}
+
+func number(tree interface{}) (float64, bool) {
+	var text string
+	switch v := tree.(type) {
+	case json.Number:
+		text = v.String()
+	case string:
+		text = strings.TrimSpace(v)
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+
+	num, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, false
+	}
+	return num, true
+}
@@ -0,0 +1,205 @@
+package sonic
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type fixtureSong struct {
+	ID       string
+	Duration int
+}
+
+var playlistFixtures = map[string]struct {
+	id       string
+	name     string
+	duration int
+	songs    []fixtureSong
+}{
+	"airsonic/getPlaylist.json": {
+		id: "7", name: "Late Night", duration: 512,
+		songs: []fixtureSong{{"1204", 367}, {"1377", 145}},
+	},
+	"gonic/getPlaylist.json": {
+		id: "MS8xNzA5NDE0MDQ3Lm0zdQ", name: "Late Night", duration: 512,
+		songs: []fixtureSong{{"tr-1204", 367}, {"tr-1377", 145}},
+	},
+	"navidrome/getPlaylist.json": {
+		id: "4b3b2a1c-9d8e-4f7a-b6c5-d4e3f2a1b0c9", name: "Late Night", duration: 512,
+		songs: []fixtureSong{{"a1f3", 367}, {"b2e4", 145}},
+	},
+	// A single entry comes back as a bare element, not a list of one
+	"navidrome/getPlaylist.xml": {
+		id: "4b3b2a1c-9d8e-4f7a-b6c5-d4e3f2a1b0c9", name: "Late Night", duration: 367,
+		songs: []fixtureSong{{"a1f3", 367}},
+	},
+	"subsonic/getPlaylist.xml": {
+		id: "15", name: "Late Night",
+		songs: []fixtureSong{{"6c6f6e67", 367}},
+	},
+}
+
+var playlistsFixtures = map[string]ListingOfPlaylists{
+	"airsonic/getPlaylists.json": {
+		{ID: "7", Name: "Late Night", SongCount: 2, Duration: 512},
+		{ID: "9", Name: "Running", SongCount: 31, Duration: 7220},
+	},
+	"gonic/getPlaylists.json": {
+		{ID: "MS8xNzA5NDE0MDQ3Lm0zdQ", Name: "Late Night", SongCount: 2, Duration: 512},
+	},
+	// One playlist, as a bare object, with an upper case ID
+	"subsonic/getPlaylists.json": {
+		{ID: "15", Name: "Late Night", SongCount: 1, Duration: 367},
+	},
+	"subsonic/getPlaylists.xml": {
+		{ID: "15", Name: "Late Night"},
+	},
+	// No playlists at all is an empty string
+	"subsonic/emptyPlaylists.json": nil,
+}
+
+var statusFixtures = map[string]struct {
+	version string
+	code    int
+}{
+	"airsonic/ping.json":  {version: "1.15.0"},
+	"gonic/ping.json":     {version: "1.15.0"},
+	"navidrome/ping.json": {version: "1.16.1"},
+	"subsonic/ping.xml":   {version: "1.3.0"},
+	"subsonic/error.xml":  {version: "1.3.0", code: 40},
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// Every fixture should be checked by one of the tests below, so new ones
+// don't get forgotten
+func TestFixturesCovered(t *testing.T) {
+	names, err := filepath.Glob(filepath.Join("testdata", "*", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range names {
+		name, _ := filepath.Rel("testdata", path)
+		name = filepath.ToSlash(name)
+		_, playlist := playlistFixtures[name]
+		_, playlists := playlistsFixtures[name]
+		_, status := statusFixtures[name]
+		if !playlist && !playlists && !status {
+			t.Errorf("%s isn't covered by any test", name)
+		}
+	}
+}
+
+func TestStatusFixtures(t *testing.T) {
+	for name, want := range statusFixtures {
+		t.Run(name, func(t *testing.T) {
+			var resp StatusResponseWrapper
+			if err := decodeResponse(readFixture(t, name), "", &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Response.Version != want.version {
+				t.Errorf("version is '%s', want '%s'", resp.Response.Version, want.version)
+			}
+
+			err := resp.Response.Err()
+			if want.code == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+			serverErr, ok := err.(ErrorResponse)
+			if !ok {
+				t.Fatalf("got %v, want a server error", err)
+			}
+			if serverErr.Code != want.code {
+				t.Errorf("error code is %d, want %d", serverErr.Code, want.code)
+			}
+		})
+	}
+}
+
+func TestPlaylistsFixtures(t *testing.T) {
+	for name, want := range playlistsFixtures {
+		t.Run(name, func(t *testing.T) {
+			var resp GetPlaylistsResponseWrapper
+			if err := decodeResponse(readFixture(t, name), "", &resp); err != nil {
+				t.Fatal(err)
+			}
+			if err := resp.Response.Err(); err != nil {
+				t.Fatal(err)
+			}
+			got := resp.Response.Data.Playlists
+			if len(got) != len(want) {
+				t.Fatalf("got %d playlists, want %d: %+v", len(got), len(want), got)
+			}
+			for idx := range want {
+				if got[idx] != want[idx] {
+					t.Errorf("playlist %d is %+v, want %+v", idx, got[idx], want[idx])
+				}
+			}
+		})
+	}
+}
+
+// Playlists are read whole by GetPlaylist and a song at a time by
+// StreamPlaylist. Both should see the same thing.
+func TestPlaylistFixtures(t *testing.T) {
+	path := []string{"subsonic-response", "playlist", "entry"}
+
+	for name, want := range playlistFixtures {
+		t.Run(name, func(t *testing.T) {
+			data := readFixture(t, name)
+
+			var whole GetPlaylistResponseWrapper
+			if err := decodeResponse(data, "", &whole); err != nil {
+				t.Fatal(err)
+			}
+
+			var (
+				streamed GetPlaylistResponseWrapper
+				songs    Songs
+			)
+			collect := eachSong(func(song Song) error {
+				songs = append(songs, song)
+				return nil
+			})
+			if err := decodeStream(bytes.NewReader(data), "", path, &streamed, collect); err != nil {
+				t.Fatal(err)
+			}
+			streamed.Response.Playlist.Songs = songs
+
+			for how, resp := range map[string]GetPlaylistResponseWrapper{"decodeResponse": whole, "decodeStream": streamed} {
+				if err := resp.Response.Err(); err != nil {
+					t.Fatalf("%s: %s", how, err)
+				}
+				playlist := resp.Response.Playlist
+				if playlist.ID != want.id || playlist.Name != want.name || playlist.Duration != want.duration {
+					t.Errorf("%s: playlist is %s '%s' %ds, want %s '%s' %ds",
+						how, playlist.ID, playlist.Name, playlist.Duration, want.id, want.name, want.duration)
+				}
+
+				var got []fixtureSong
+				for _, song := range playlist.Songs {
+					got = append(got, fixtureSong{song.ID, song.Duration})
+				}
+				if !reflect.DeepEqual(got, want.songs) {
+					t.Errorf("%s: songs are %+v, want %+v", how, got, want.songs)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package sonic
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/logging"
+)
+
+// HTTPConfig describes how to reach the server. The zero value behaves like
+// http.DefaultClient, minus the hanging forever.
+type HTTPConfig struct {
+	// Timeout bounds api calls, connecting and waiting for a response.
+	// Downloads can take as long as they need, but give up if the server
+	// stops sending for this long. Zero uses DefaultTimeout.
+	Timeout time.Duration
+
+	// CABundle is a PEM file of certificates to trust on top of the
+	// system's, for self signed servers
+	CABundle string
+
+	// ClientCert and ClientKey are PEM files, for servers that want a
+	// client certificate
+	ClientCert string
+	ClientKey  string
+
+	// Insecure skips verifying the server's certificate
+	Insecure bool
+
+	// Proxy is an http, https or socks5 url. The usual HTTP_PROXY and
+	// HTTPS_PROXY environment variables are used when it's empty.
+	Proxy string
+
+	// Headers are added to every request, like the Authorization header an
+	// auth proxy in front of the server wants
+	Headers http.Header
+}
+
+const DefaultTimeout = 30 * time.Second
+
+// ParseHeaders reads headers written like 'Name: value'
+func ParseHeaders(lines []string) (http.Header, error) {
+	headers := make(http.Header, len(lines))
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("header '%s' should look like 'Name: value'", line)
+		}
+		headers.Add(name, strings.TrimSpace(value))
+	}
+	return headers, nil
+}
+
+// Configure sets up the client's connection to the server
+func (client *Sonic) Configure(config HTTPConfig) error {
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.Insecure}
+
+	if config.CABundle != "" {
+		pem, err := os.ReadFile(config.CABundle)
+		if err != nil {
+			return err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in %s", config.CABundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if config.ClientCert != "" || config.ClientKey != "" {
+		if config.ClientCert == "" || config.ClientKey == "" {
+			return errors.New("a client certificate needs both a cert and a key")
+		}
+		cert, err := tls.LoadX509KeyPair(config.ClientCert, config.ClientKey)
+		if err != nil {
+			return err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if config.Proxy != "" {
+		proxyURL, err := url.Parse(config.Proxy)
+		if err != nil {
+			return fmt.Errorf("bad proxy url: %w", err)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	dialer := &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}
+	client.HTTP = &http.Client{
+		Transport: logTransport{&http.Transport{
+			Proxy:                 proxy,
+			DialContext:           dialer.DialContext,
+			TLSClientConfig:       tlsConfig,
+			TLSHandshakeTimeout:   timeout,
+			ResponseHeaderTimeout: timeout,
+			IdleConnTimeout:       90 * time.Second,
+			MaxIdleConns:          10,
+			ForceAttemptHTTP2:     true,
+		}},
+	}
+	client.Headers = config.Headers
+	client.Timeout = timeout
+	return nil
+}
+
+// httpClient is the client for api calls, which shouldn't take long
+func (client Sonic) httpClient() *http.Client {
+	base := client.HTTP
+	if base == nil {
+		base = http.DefaultClient
+	}
+	api := *base
+	api.Timeout = client.timeout()
+	return &api
+}
+
+func (client Sonic) timeout() time.Duration {
+	if client.Timeout > 0 {
+		return client.Timeout
+	}
+	return DefaultTimeout
+}
+
+// logTransport logs every request to the server and what came back, with
+// the credentials that ride along on every call blanked out
+type logTransport struct {
+	next http.RoundTripper
+}
+
+func (transport logTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if slog.Default().Enabled(ctx, slog.LevelDebug) {
+		attrs := []any{
+			slog.String("method", req.Method),
+			slog.String("url", logging.RedactURL(req.URL.String())),
+		}
+		if form := requestForm(req); form != nil {
+			attrs = append(attrs, slog.String("form", logging.RedactForm(form).Encode()))
+		}
+		if len(req.Header) > 0 {
+			attrs = append(attrs, slog.Any("headers", logging.RedactHeaders(req.Header)))
+		}
+		slog.DebugContext(ctx, "request", attrs...)
+	}
+
+	started := time.Now()
+	resp, err := transport.next.RoundTrip(req)
+	took := time.Since(started).Round(time.Millisecond)
+	endpoint := req.URL.Path
+
+	switch {
+	case err != nil:
+		slog.WarnContext(ctx, "request failed", "endpoint", endpoint, "took", took, "err", err)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		slog.WarnContext(ctx, "response", "endpoint", endpoint, "status", resp.StatusCode, "took", took)
+	default:
+		slog.DebugContext(ctx, "response",
+			"endpoint", endpoint,
+			"status", resp.StatusCode,
+			"type", resp.Header.Get("Content-Type"),
+			"length", resp.ContentLength,
+			"took", took,
+		)
+	}
+	return resp, err
+}
+
+// requestForm reads a form body without using it up, or nil if there isn't
+// one
+func requestForm(req *http.Request) url.Values {
+	if req.GetBody == nil || !strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		return nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil
+	}
+	form, err := url.ParseQuery(string(data))
+	if err != nil {
+		return nil
+	}
+	return form
+}
+
+// stallReader cancels a download when nothing has been read for a while
+type stallReader struct {
+	io.Reader
+	timer   *time.Timer
+	timeout time.Duration
+}
+
+func (reader stallReader) Read(p []byte) (int, error) {
+	n, err := reader.Reader.Read(p)
+	if n > 0 {
+		reader.timer.Reset(reader.timeout)
+	}
+	return n, err
+}
@@ -0,0 +1,109 @@
+package sonic
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+type (
+	// Lyrics is the plain text the original subsonic api returns
+	Lyrics struct {
+		Artist string `json:"artist"`
+		Title  string `json:"title"`
+		Value  string `json:"value"`
+	}
+
+	// StructuredLyrics come from the OpenSubsonic songLyrics extension.
+	// When Synced, each line has a start time.
+	StructuredLyrics struct {
+		DisplayArtist string       `json:"displayArtist"`
+		DisplayTitle  string       `json:"displayTitle"`
+		Lang          string       `json:"lang"`
+		Synced        bool         `json:"synced"`
+		Lines         []LyricsLine `json:"line"`
+
+		// Offset, in milliseconds, shows every line that much sooner when
+		// it's positive
+		Offset int64 `json:"offset"`
+	}
+
+	LyricsLine struct {
+		// Start is in milliseconds
+		Start int64  `json:"start"`
+		Value string `json:"value"`
+	}
+
+	GetLyricsResponseWrapper struct {
+		Response GetLyricsResponse `json:"subsonic-response"`
+	}
+
+	GetLyricsResponse struct {
+		StatusResponse
+		Lyrics Lyrics `json:"lyrics"`
+	}
+
+	GetLyricsBySongIDResponseWrapper struct {
+		Response GetLyricsBySongIDResponse `json:"subsonic-response"`
+	}
+
+	GetLyricsBySongIDResponse struct {
+		StatusResponse
+		LyricsList struct {
+			StructuredLyrics []StructuredLyrics `json:"structuredLyrics"`
+		} `json:"lyricsList"`
+	}
+)
+
+// GetLyrics looks lyrics up by artist and title. Servers answer with empty
+// lyrics, rather than an error, when they have none.
+func (client Sonic) GetLyrics(artist string, title string) (Lyrics, error) {
+	var resp GetLyricsResponseWrapper
+
+	params := struct {
+		Format   string `url:"f"`
+		User     string `url:"u"`
+		Password string `url:"p"`
+		ClientID string `url:"c"`
+		Artist   string `url:"artist,omitempty"`
+		Title    string `url:"title,omitempty"`
+	}{client.format(), client.auth.User, client.auth.Password, clientID, artist, title}
+
+	_, err := client.sling().New().
+		Post(client.url("rest/getLyrics")).
+		BodyForm(params).
+		ReceiveSuccess(&resp)
+	if err != nil {
+		return Lyrics{}, err
+	}
+	if err := resp.Response.Err(); err != nil {
+		return Lyrics{}, err
+	}
+
+	return resp.Response.Lyrics, nil
+}
+
+// GetLyricsBySongID returns every set of lyrics the server has for a song,
+// often one per language, some of them synced. Only servers with the
+// OpenSubsonic songLyrics extension support it.
+func (client Sonic) GetLyricsBySongID(id string) ([]StructuredLyrics, error) {
+	var resp GetLyricsBySongIDResponseWrapper
+
+	params := struct {
+		Format   string `url:"f"`
+		User     string `url:"u"`
+		Password string `url:"p"`
+		ClientID string `url:"c"`
+		ID       string `url:"id"`
+	}{client.format(), client.auth.User, client.auth.Password, clientID, id}
+
+	_, err := client.sling().New().
+		Post(client.url("rest/getLyricsBySongId")).
+		BodyForm(params).
+		ReceiveSuccess(&resp)
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.Response.Err(); err != nil {
+		return nil, err
+	}
+
+	return resp.Response.LyricsList.StructuredLyrics, nil
+}
@@ -6,11 +6,18 @@ package sonic
 // FIXME: tokenize auth
 
 import (
+	"context"
+	"crypto/md5"
+	crand "crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"math/rand"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/dghubble/sling"
 )
@@ -18,6 +25,9 @@ import (
 const (
 	version    = "0.0.1"
 	clientName = "hedgehog"
+
+	// Token auth, t and s instead of p, arrived in api version 1.13.0
+	tokenAuthSince = "1.13.0"
 )
 
 var (
@@ -29,6 +39,25 @@ type (
 	Sonic struct {
 		auth Auth
 		base string
+
+		// MaxBitRate asks the server to transcode downloads to at most this
+		// many kbps. Zero fetches the original file.
+		MaxBitRate int
+
+		// Server, OpenSubsonic and Extensions describe the server and what
+		// it supports beyond the subsonic api. They're filled in by Probe.
+		Server       ServerInfo
+		OpenSubsonic bool
+		Extensions   Extensions
+
+		// HTTP, Headers and Timeout are set up by Configure. Without it,
+		// http.DefaultClient is used with DefaultTimeout.
+		HTTP    *http.Client
+		Headers http.Header
+		Timeout time.Duration
+
+		// xml is set for servers that can't answer in JSON
+		xml bool
 	}
 
 	Auth struct {
@@ -45,20 +74,92 @@ type (
 		Title   string `json:"title"`
 		IsVideo bool   `json:"isVideo"`
 		Suffix  string `json:"suffix"`
+
+		// Duration is in seconds
+		Duration int `json:"duration"`
+
+		AlbumID     string `json:"albumId"`
+		ArtistID    string `json:"artistId"`
+		CoverArt    string `json:"coverArt"`
+		Genre       string `json:"genre"`
+		Year        int    `json:"year"`
+		DiscNumber  int    `json:"discNumber"`
+		ContentType string `json:"contentType"`
+		Size        int64  `json:"size"`
+		PlayCount   int64  `json:"playCount"`
+		Type        string `json:"type"`
+
+		// BitRate is in kbps, as stored on the server
+		BitRate int `json:"bitRate"`
+
+		// The rest come from OpenSubsonic servers. Played is when the song
+		// was last played.
+		MusicBrainzID string      `json:"musicBrainzId"`
+		BPM           int         `json:"bpm"`
+		Played        string      `json:"played"`
+		DisplayArtist string      `json:"displayArtist"`
+		Artists       []ArtistRef `json:"artists"`
+		Genres        []GenreRef  `json:"genres"`
 	}
 	Songs []Song
 
-	GetStarredResponseWrapper struct {
-		Status   string             `json:"status"`
-		Response GetStarredResponse `json:"subsonic-response"`
+	ArtistRef struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+
+	GenreRef struct {
+		Name string `json:"name"`
+	}
+
+	StatusResponseWrapper struct {
+		Response StatusResponse `json:"subsonic-response"`
+	}
+
+	// StatusResponse is the envelope common to every subsonic response
+	StatusResponse struct {
+		Status        string         `json:"status"`
+		Version       string         `json:"version"`
+		Type          string         `json:"type"`
+		ServerVersion string         `json:"serverVersion"`
+		OpenSubsonic  bool           `json:"openSubsonic"`
+		Error         *ErrorResponse `json:"error"`
+	}
+
+	ErrorResponse struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+
+	SearchResponseWrapper struct {
+		Response SearchResponse `json:"subsonic-response"`
+	}
+
+	SearchResponse struct {
+		StatusResponse
+		Result SearchResult `json:"searchResult3"`
+	}
+
+	SearchResult struct {
+		Songs Songs `json:"song"`
+	}
+
+	GetSongResponseWrapper struct {
+		Response GetSongResponse `json:"subsonic-response"`
+	}
+
+	GetSongResponse struct {
+		StatusResponse
+		Song Song `json:"song"`
 	}
 
-	GetStarredResponse struct {
-		Status        string           `json:"status"`
-		Version       string           `json:"version"`
-		Type          string           `json:"type"`
-		ServerVersion string           `json:"serverVersion"`
-		Starred       map[string]Songs `json:"starred"`
+	CreatePlaylistResponseWrapper struct {
+		Response CreatePlaylistResponse `json:"subsonic-response"`
+	}
+
+	CreatePlaylistResponse struct {
+		StatusResponse
+		Playlist Playlist `json:"playlist"`
 	}
 
 	GetPlaylistsResponseWrapper struct {
@@ -67,6 +168,7 @@ type (
 	}
 
 	GetPlaylistsResponse struct {
+		StatusResponse
 		Data GetPlaylistsWrapper `json:"playlists"`
 	}
 
@@ -75,10 +177,10 @@ type (
 	}
 
 	PlaylistListing struct {
-		ID        string `json:"ID"`
+		ID        string `json:"id"`
 		Name      string `json:"name"`
 		SongCount int    `json:"songCount"`
-		Duration  string `json:"duration"`
+		Duration  int    `json:"duration"`
 	}
 	ListingOfPlaylists []PlaylistListing
 
@@ -88,11 +190,12 @@ type (
 	}
 
 	GetPlaylistResponse struct {
+		StatusResponse
 		Playlist Playlist `json:"playlist"`
 	}
 
 	Playlist struct {
-		ID        string `json:"ID"`
+		ID        string `json:"id"`
 		Name      string `json:"name"`
 		SongCount int    `json:"songCount"`
 		Duration  int    `json:"duration"`
@@ -109,7 +212,63 @@ func (client Sonic) url(path string) string {
 }
 
 func (client Sonic) sling() *sling.Sling {
-	return sling.New().Set("User-Agent", userAgent)
+	api := sling.New().
+		Client(client.httpClient()).
+		Set("User-Agent", userAgent).
+		ResponseDecoder(compatDecoder{})
+	for name, values := range client.Headers {
+		api.Set(name, strings.Join(values, ", "))
+	}
+	return api
+}
+
+// format is the response format we ask the server for
+func (client Sonic) format() string {
+	if client.xml {
+		return "xml"
+	}
+	return "json"
+}
+
+func (err ErrorResponse) Error() string {
+	return fmt.Sprintf("server error %d: %s", err.Code, err.Message)
+}
+
+func (resp StatusResponse) Err() error {
+	if resp.Error != nil {
+		return *resp.Error
+	}
+	if resp.Status != "ok" {
+		return fmt.Errorf("unexpected response status '%s'", resp.Status)
+	}
+	return nil
+}
+
+// Ping checks that the server is reachable and accepts our credentials
+func (client Sonic) Ping() error {
+	_, err := client.ping()
+	return err
+}
+
+func (client Sonic) ping() (StatusResponse, error) {
+	var resp StatusResponseWrapper
+
+	params := struct {
+		Format   string `url:"f"`
+		User     string `url:"u"`
+		Password string `url:"p"`
+		ClientID string `url:"c"`
+	}{client.format(), client.auth.User, client.auth.Password, clientID}
+
+	_, err := client.sling().New().
+		Post(client.url("rest/ping")).
+		BodyForm(params).
+		ReceiveSuccess(&resp)
+	if err != nil {
+		return StatusResponse{}, err
+	}
+
+	return resp.Response, resp.Response.Err()
 }
 
 func (client Sonic) GetPlaylists() (ListingOfPlaylists, error) {
@@ -120,26 +279,44 @@ func (client Sonic) GetPlaylists() (ListingOfPlaylists, error) {
 		User     string `url:"u"`
 		Password string `url:"p"`
 		ClientID string `url:"c"`
-	}{"json", client.auth.User, client.auth.Password, clientID}
+	}{client.format(), client.auth.User, client.auth.Password, clientID}
 
-	_, err := sling.New().Post(client.url("rest/getPlaylists")).
-		Set("User-Agent", userAgent).
+	_, err := client.sling().New().
+		Post(client.url("rest/getPlaylists")).
 		BodyForm(params).
 		ReceiveSuccess(&resp)
 	if err != nil {
 		return ListingOfPlaylists{}, err
 	}
+	if err := resp.Response.Err(); err != nil {
+		return ListingOfPlaylists{}, err
+	}
 
 	return resp.Response.Data.Playlists, nil
 }
 
 func (client Sonic) GetPlaylist(id string) (Playlist, error) {
+	var songs Songs
+	playlist, err := client.StreamPlaylist(id, func(song Song) error {
+		songs = append(songs, song)
+		return nil
+	})
+	if err != nil {
+		return Playlist{}, err
+	}
+
+	playlist.Songs = songs
+	return playlist, nil
+}
+
+// StreamPlaylist fetches a playlist, handing each song over as it arrives.
+// The playlist returned has everything but the songs.
+func (client Sonic) StreamPlaylist(id string, each func(Song) error) (Playlist, error) {
 	if id == "" {
 		return Playlist{}, errors.New("provide an id")
 	}
 
 	var resp GetPlaylistResponseWrapper
-	// var resp interface{}
 
 	params := struct {
 		Format     string `url:"f"`
@@ -147,45 +324,184 @@ func (client Sonic) GetPlaylist(id string) (Playlist, error) {
 		Password   string `url:"p"`
 		ClientID   string `url:"c"`
 		PlaylistID string `url:"id"`
-	}{"json", client.auth.User, client.auth.Password, clientID, id}
+	}{client.format(), client.auth.User, client.auth.Password, clientID, id}
 
-	_, err := client.sling().New().
-		Post(client.url("rest/getPlaylist")).
-		BodyForm(params).
-		ReceiveSuccess(&resp)
-	if err != nil {
+	path := []string{"subsonic-response", "playlist", "entry"}
+	if err := client.stream("rest/getPlaylist", params, path, &resp, eachSong(each)); err != nil {
+		return Playlist{}, err
+	}
+	if err := resp.Response.Err(); err != nil {
 		return Playlist{}, err
 	}
 
 	return resp.Response.Playlist, nil
 }
 
-func (client Sonic) GetStarred() (map[string]bool, error) {
-	var (
-		resp GetStarredResponseWrapper
-		data = make(map[string]bool)
-	)
+// GetPlaylistByName finds a playlist by its name and fetches it
+func (client Sonic) GetPlaylistByName(name string) (Playlist, error) {
+	playlists, err := client.GetPlaylists()
+	if err != nil {
+		return Playlist{}, err
+	}
+
+	for idx := range playlists {
+		if playlists[idx].Name == name {
+			return client.GetPlaylist(playlists[idx].ID)
+		}
+	}
+
+	return Playlist{}, fmt.Errorf("unable to find playlist '%s'", name)
+}
+
+func (client Sonic) GetSong(id string) (Song, error) {
+	var resp GetSongResponseWrapper
 
 	params := struct {
 		Format   string `url:"f"`
 		User     string `url:"u"`
 		Password string `url:"p"`
 		ClientID string `url:"c"`
-	}{"json", client.auth.User, client.auth.Password, clientID}
+		ID       string `url:"id"`
+	}{client.format(), client.auth.User, client.auth.Password, clientID, id}
 
 	_, err := client.sling().New().
-		Post(client.url("rest/getStarred")).
+		Post(client.url("rest/getSong")).
 		BodyForm(params).
 		ReceiveSuccess(&resp)
 	if err != nil {
-		return data, err
+		return Song{}, err
 	}
-	for idx := range resp.Response.Starred["song"] {
-		song := resp.Response.Starred["song"][idx]
-		data[song.ID] = true
+	if err := resp.Response.Err(); err != nil {
+		return Song{}, err
 	}
 
-	return data, nil
+	return resp.Response.Song, nil
+}
+
+// SearchSongs runs a search3 query for songs only
+func (client Sonic) SearchSongs(query string, count int, offset int) (Songs, error) {
+	var resp SearchResponseWrapper
+
+	params := struct {
+		Format      string `url:"f"`
+		User        string `url:"u"`
+		Password    string `url:"p"`
+		ClientID    string `url:"c"`
+		Query       string `url:"query"`
+		ArtistCount int    `url:"artistCount"`
+		AlbumCount  int    `url:"albumCount"`
+		SongCount   int    `url:"songCount"`
+		SongOffset  int    `url:"songOffset"`
+	}{client.format(), client.auth.User, client.auth.Password, clientID, query, 0, 0, count, offset}
+
+	_, err := client.sling().New().
+		Post(client.url("rest/search3")).
+		BodyForm(params).
+		ReceiveSuccess(&resp)
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.Response.Err(); err != nil {
+		return nil, err
+	}
+
+	return resp.Response.Result.Songs, nil
+}
+
+// CreatePlaylist makes a new playlist with the given songs. If id is set,
+// that playlist's songs are replaced instead.
+func (client Sonic) CreatePlaylist(id string, name string, songIDs []string) (Playlist, error) {
+	var resp CreatePlaylistResponseWrapper
+
+	params := struct {
+		Format     string   `url:"f"`
+		User       string   `url:"u"`
+		Password   string   `url:"p"`
+		ClientID   string   `url:"c"`
+		PlaylistID string   `url:"playlistId,omitempty"`
+		Name       string   `url:"name,omitempty"`
+		SongIDs    []string `url:"songId"`
+	}{client.format(), client.auth.User, client.auth.Password, clientID, id, name, songIDs}
+
+	if id != "" {
+		// The spec takes either an id or a name, not both
+		params.Name = ""
+	}
+
+	_, err := client.sling().New().
+		Post(client.url("rest/createPlaylist")).
+		BodyForm(params).
+		ReceiveSuccess(&resp)
+	if err != nil {
+		return Playlist{}, err
+	}
+	if err := resp.Response.Err(); err != nil {
+		return Playlist{}, err
+	}
+
+	return resp.Response.Playlist, nil
+}
+
+// StreamURL is a url that plays the song directly. It's signed with a
+// salted token instead of the password, but the token works for as long as
+// the password does, so the url still needs treating like a password.
+func (client Sonic) StreamURL(song Song) (string, error) {
+	if !client.Server.AtLeast(tokenAuthSince) {
+		return "", fmt.Errorf("the server (api %s) is too old for token auth, so a stream url would carry the password", client.Server.Version)
+	}
+
+	salt := make([]byte, 8)
+	if _, err := crand.Read(salt); err != nil {
+		return "", err
+	}
+	saltHex := hex.EncodeToString(salt)
+
+	params := url.Values{}
+	params.Set("u", client.auth.User)
+	params.Set("t", authToken(client.auth.Password, saltHex))
+	params.Set("s", saltHex)
+	params.Set("v", tokenAuthSince)
+	params.Set("c", clientID)
+	params.Set("id", song.ID)
+	if client.MaxBitRate > 0 {
+		params.Set("maxBitRate", fmt.Sprint(client.MaxBitRate))
+	}
+
+	return fmt.Sprintf("%s?%s", client.url("rest/stream"), params.Encode()), nil
+}
+
+// authToken is the subsonic token for a password, md5(password + salt)
+func authToken(password string, salt string) string {
+	sum := md5.Sum([]byte(password + salt))
+	return hex.EncodeToString(sum[:])
+}
+
+// GetStarred returns the ids of every starred song
+func (client Sonic) GetStarred() (map[string]bool, error) {
+	data := make(map[string]bool)
+	err := client.StreamStarred(func(song Song) error {
+		data[song.ID] = true
+		return nil
+	})
+	return data, err
+}
+
+// StreamStarred hands over each starred song as it arrives
+func (client Sonic) StreamStarred(each func(Song) error) error {
+	var resp StatusResponseWrapper
+
+	params := struct {
+		Format   string `url:"f"`
+		User     string `url:"u"`
+		Password string `url:"p"`
+		ClientID string `url:"c"`
+	}{client.format(), client.auth.User, client.auth.Password, clientID}
+
+	path := []string{"subsonic-response", "starred", "song"}
+	if err := client.stream("rest/getStarred", params, path, &resp, eachSong(each)); err != nil {
+		return err
+	}
+	return resp.Response.Err()
 }
 
 func (playlist Playlist) Shuffle() Playlist {
@@ -199,13 +515,17 @@ func (playlist Playlist) Shuffle() Playlist {
 }
 
 func (client Sonic) DownloadSong(song Song) ([]byte, error) {
+	if client.MaxBitRate > 0 {
+		return client.streamSong(song)
+	}
+
 	params := struct {
 		Format   string `url:"f"`
 		User     string `url:"u"`
 		Password string `url:"p"`
 		ClientID string `url:"c"`
 		SongID   string `url:"id"`
-	}{"json", client.auth.User, client.auth.Password, clientID, song.ID}
+	}{client.format(), client.auth.User, client.auth.Password, clientID, song.ID}
 
 	req, err := client.sling().New().
 		Post(client.url("rest/download")).
@@ -215,16 +535,71 @@ func (client Sonic) DownloadSong(song Song) ([]byte, error) {
 		return nil, err
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	return client.fetch(req)
+}
+
+func (client Sonic) streamSong(song Song) ([]byte, error) {
+	params := struct {
+		Format     string `url:"f"`
+		User       string `url:"u"`
+		Password   string `url:"p"`
+		ClientID   string `url:"c"`
+		SongID     string `url:"id"`
+		MaxBitRate int    `url:"maxBitRate"`
+	}{client.format(), client.auth.User, client.auth.Password, clientID, song.ID, client.MaxBitRate}
+
+	req, err := client.sling().New().
+		Post(client.url("rest/stream")).
+		BodyForm(params).
+		Request()
 	if err != nil {
 		return nil, err
 	}
 
-	resBody, err := io.ReadAll(resp.Body)
-	return resBody, err
+	return client.fetch(req)
+}
+
+// fetch downloads something that can take a while, like a song. It has no
+// deadline, but gives up when the server stops sending.
+func (client Sonic) fetch(req *http.Request) ([]byte, error) {
+	httpClient := client.HTTP
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	ctx, cancel := context.WithCancel(req.Context())
+	defer cancel()
+	timer := time.AfterFunc(client.timeout(), cancel)
+	defer timer.Stop()
+
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, client.stalled(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download failed: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(stallReader{resp.Body, timer, client.timeout()})
+	if err != nil {
+		return nil, client.stalled(ctx, err)
+	}
+	return data, nil
 }
 
-func (client Sonic) ScrobbleNowPlaying(song Song) {
+// stalled explains a request cancelled because the server went quiet
+func (client Sonic) stalled(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("gave up after nothing from the server for %s", client.timeout())
+	}
+	return err
+}
+
+func (client Sonic) ScrobbleNowPlaying(song Song) error {
+	var resp StatusResponseWrapper
+
 	params := struct {
 		Format       string `url:"f"`
 		User         string `url:"u"`
@@ -232,15 +607,22 @@ func (client Sonic) ScrobbleNowPlaying(song Song) {
 		ClientID     string `url:"c"`
 		ID           string `url:"id"`
 		IsSubmission bool   `url:"submission"`
-	}{"json", client.auth.User, client.auth.Password, clientID, song.ID, false}
+	}{client.format(), client.auth.User, client.auth.Password, clientID, song.ID, false}
 
-	client.sling().New().
+	_, err := client.sling().New().
 		Post(client.url("rest/scrobble")).
 		BodyForm(params).
-		ReceiveSuccess(nil)
+		ReceiveSuccess(&resp)
+	if err != nil {
+		return err
+	}
+	return resp.Response.Err()
 }
 
-func (client Sonic) ScrobbleSubmit(song Song) {
+// ScrobbleSubmit records a play that started at the given time
+func (client Sonic) ScrobbleSubmit(song Song, at time.Time) error {
+	var resp StatusResponseWrapper
+
 	params := struct {
 		Format       string `url:"f"`
 		User         string `url:"u"`
@@ -248,40 +630,57 @@ func (client Sonic) ScrobbleSubmit(song Song) {
 		ClientID     string `url:"c"`
 		ID           string `url:"id"`
 		IsSubmission bool   `url:"submission"`
-	}{"json", client.auth.User, client.auth.Password, clientID, song.ID, true}
+		Time         int64  `url:"time"`
+	}{client.format(), client.auth.User, client.auth.Password, clientID, song.ID, true, at.UnixMilli()}
 
-	client.sling().New().
+	_, err := client.sling().New().
 		Post(client.url("rest/scrobble")).
 		BodyForm(params).
-		ReceiveSuccess(nil)
+		ReceiveSuccess(&resp)
+	if err != nil {
+		return err
+	}
+	return resp.Response.Err()
 }
 
-func (client Sonic) Star(song Song) {
+func (client Sonic) Star(song Song) error {
+	var resp StatusResponseWrapper
+
 	params := struct {
 		Format   string `url:"f"`
 		User     string `url:"u"`
 		Password string `url:"p"`
 		ClientID string `url:"c"`
 		ID       string `url:"id"`
-	}{"json", client.auth.User, client.auth.Password, clientID, song.ID}
+	}{client.format(), client.auth.User, client.auth.Password, clientID, song.ID}
 
-	client.sling().New().
+	_, err := client.sling().New().
 		Post(client.url("rest/star")).
 		BodyForm(params).
-		ReceiveSuccess(nil)
+		ReceiveSuccess(&resp)
+	if err != nil {
+		return err
+	}
+	return resp.Response.Err()
 }
 
-func (client Sonic) UnStar(song Song) {
+func (client Sonic) UnStar(song Song) error {
+	var resp StatusResponseWrapper
+
 	params := struct {
 		Format   string `url:"f"`
 		User     string `url:"u"`
 		Password string `url:"p"`
 		ClientID string `url:"c"`
 		ID       string `url:"id"`
-	}{"json", client.auth.User, client.auth.Password, clientID, song.ID}
+	}{client.format(), client.auth.User, client.auth.Password, clientID, song.ID}
 
-	client.sling().New().
+	_, err := client.sling().New().
 		Post(client.url("rest/unstar")).
 		BodyForm(params).
-		ReceiveSuccess(nil)
+		ReceiveSuccess(&resp)
+	if err != nil {
+		return err
+	}
+	return resp.Response.Err()
 }
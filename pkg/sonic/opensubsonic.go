@@ -0,0 +1,73 @@
+package sonic
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+// OpenSubsonic (https://opensubsonic.netlify.app) extends the subsonic api.
+// Servers that speak it list the extensions they support, by name and
+// version.
+
+const (
+	ExtensionSongLyrics      = "songLyrics"
+	ExtensionTranscodeOffset = "transcodeOffset"
+	ExtensionFormPost        = "formPost"
+	ExtensionAPIKeyAuth      = "apiKeyAuthentication"
+)
+
+type (
+	// Extensions maps an extension's name to the versions the server has
+	Extensions map[string][]int
+
+	OpenSubsonicExtension struct {
+		Name     string `json:"name"`
+		Versions []int  `json:"versions"`
+	}
+
+	GetOpenSubsonicExtensionsResponseWrapper struct {
+		Response GetOpenSubsonicExtensionsResponse `json:"subsonic-response"`
+	}
+
+	GetOpenSubsonicExtensionsResponse struct {
+		StatusResponse
+		Extensions []OpenSubsonicExtension `json:"openSubsonicExtensions"`
+	}
+)
+
+func (extensions Extensions) Supports(name string) bool {
+	_, ok := extensions[name]
+	return ok
+}
+
+// Supports is whether the server has an OpenSubsonic extension. Until Probe
+// is called, nothing is supported.
+func (client Sonic) Supports(extension string) bool {
+	return client.Extensions.Supports(extension)
+}
+
+func (client Sonic) GetOpenSubsonicExtensions() (Extensions, error) {
+	var resp GetOpenSubsonicExtensionsResponseWrapper
+
+	params := struct {
+		Format   string `url:"f"`
+		User     string `url:"u"`
+		Password string `url:"p"`
+		ClientID string `url:"c"`
+	}{client.format(), client.auth.User, client.auth.Password, clientID}
+
+	_, err := client.sling().New().
+		Post(client.url("rest/getOpenSubsonicExtensions")).
+		BodyForm(params).
+		ReceiveSuccess(&resp)
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.Response.Err(); err != nil {
+		return nil, err
+	}
+
+	extensions := make(Extensions, len(resp.Response.Extensions))
+	for _, extension := range resp.Response.Extensions {
+		extensions[extension.Name] = extension.Versions
+	}
+	return extensions, nil
+}
@@ -0,0 +1,124 @@
+package sonic
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import "errors"
+
+// The subsonic api caps most lists at 500 items per request
+const MaxPageSize = 500
+
+// Pager walks an endpoint that takes an offset and a count, a page at a
+// time:
+//
+//	pager := client.SearchPager("blue")
+//	for pager.Next() {
+//		for _, song := range pager.Page() {
+//			...
+//		}
+//	}
+//	if err := pager.Err(); err != nil {
+//		...
+//	}
+type Pager[T any] struct {
+	// PageSize is how many items to ask for at a time, up to MaxPageSize
+	PageSize int
+
+	fetch  func(count int, offset int) ([]T, error)
+	offset int
+	page   []T
+	done   bool
+	err    error
+
+	// endless pagers, like random albums, never run out of pages, so All
+	// needs a max
+	endless bool
+}
+
+func newPager[T any](fetch func(count int, offset int) ([]T, error)) *Pager[T] {
+	return &Pager[T]{PageSize: MaxPageSize, fetch: fetch}
+}
+
+// Next fetches the next page. It's false once there's nothing left or a
+// request failed.
+func (pager *Pager[T]) Next() bool {
+	if pager.done {
+		return false
+	}
+
+	size := pager.PageSize
+	if size <= 0 || size > MaxPageSize {
+		size = MaxPageSize
+	}
+
+	page, err := pager.fetch(size, pager.offset)
+	if err != nil {
+		pager.err = err
+		pager.done = true
+		return false
+	}
+
+	pager.offset += len(page)
+	pager.page = page
+	if len(page) < size {
+		pager.done = true
+	}
+	return len(page) > 0
+}
+
+func (pager *Pager[T]) Page() []T {
+	return pager.page
+}
+
+func (pager *Pager[T]) Err() error {
+	return pager.err
+}
+
+// All reads every remaining page, stopping early once max items are in
+// hand. A max of zero or less reads everything, which an endless pager
+// refuses to do.
+func (pager *Pager[T]) All(max int) ([]T, error) {
+	if pager.endless && max <= 0 {
+		return nil, errors.New("this list never runs out, so it needs a count above zero")
+	}
+	if max > 0 && (pager.PageSize <= 0 || max < pager.PageSize) {
+		pager.PageSize = max
+	}
+
+	var items []T
+	for pager.Next() {
+		items = append(items, pager.Page()...)
+		if max > 0 && len(items) >= max {
+			items = items[:max]
+			break
+		}
+	}
+	return items, pager.Err()
+}
+
+// SearchPager pages through every song matching a search3 query
+func (client Sonic) SearchPager(query string) *Pager[Song] {
+	return newPager(func(count int, offset int) ([]Song, error) {
+		return client.SearchSongs(query, count, offset)
+	})
+}
+
+// AlbumListPager pages through a getAlbumList2 list, like the newest
+// albums
+func (client Sonic) AlbumListPager(list AlbumList) *Pager[Album] {
+	pager := newPager(func(count int, offset int) ([]Album, error) {
+		return client.GetAlbumList2(list, count, offset)
+	})
+	// The server picks a fresh random page every time, however far in
+	// the offset is
+	listType, _ := AlbumListType(list.Type)
+	pager.endless = listType == AlbumListRandom
+	return pager
+}
+
+// GenrePager pages through every song in a genre
+func (client Sonic) GenrePager(genre string) *Pager[Song] {
+	return newPager(func(count int, offset int) ([]Song, error) {
+		return client.GetSongsByGenre(genre, count, offset)
+	})
+}
@@ -0,0 +1,81 @@
+package sonic
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import "testing"
+
+// pages hands out numbers, total of them, or forever if total is negative
+func pages(total int) func(count int, offset int) ([]int, error) {
+	return func(count int, offset int) ([]int, error) {
+		var page []int
+		for idx := offset; idx < offset+count && (total < 0 || idx < total); idx++ {
+			page = append(page, idx)
+		}
+		return page, nil
+	}
+}
+
+func TestPagerAll(t *testing.T) {
+	tests := []struct {
+		name    string
+		total   int
+		endless bool
+		max     int
+		size    int
+		want    int
+		wantErr bool
+	}{
+		{name: "everything", total: 1234, want: 1234},
+		{name: "up to max", total: 1234, max: 600, want: 600},
+		{name: "small max", total: 1234, max: 7, want: 7},
+		{name: "exactly a page", total: 500, want: 500},
+		{name: "empty", total: 0, want: 0},
+		{name: "small pages", total: 25, size: 10, want: 25},
+		{name: "endless with a max", total: -1, endless: true, max: 1200, want: 1200},
+		{name: "endless without one", total: -1, endless: true, wantErr: true},
+		{name: "endless with a negative one", total: -1, endless: true, max: -1, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pager := newPager(pages(test.total))
+			pager.endless = test.endless
+			if test.size > 0 {
+				pager.PageSize = test.size
+			}
+
+			items, err := pager.All(test.max)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("got %d items, want an error", len(items))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(items) != test.want {
+				t.Fatalf("got %d items, want %d", len(items), test.want)
+			}
+			for idx, item := range items {
+				if item != idx {
+					t.Fatalf("item %d is %d", idx, item)
+				}
+			}
+		})
+	}
+}
+
+func TestRandomAlbumsAreEndless(t *testing.T) {
+	client := New(Auth{}, "http://localhost")
+	for _, listType := range AlbumListTypes {
+		want := listType == AlbumListRandom
+		if got := client.AlbumListPager(AlbumList{Type: listType}).endless; got != want {
+			t.Errorf("%s: endless is %v, want %v", listType, got, want)
+		}
+	}
+	if !client.AlbumListPager(AlbumList{Type: "Random"}).endless {
+		t.Error("Random isn't endless")
+	}
+}
@@ -0,0 +1,174 @@
+package sonic
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"time"
+)
+
+// Episodes can only be streamed once the server has downloaded them
+const EpisodeCompleted = "completed"
+
+type (
+	Channel struct {
+		ID           string   `json:"id"`
+		URL          string   `json:"url"`
+		Title        string   `json:"title"`
+		Description  string   `json:"description"`
+		Status       string   `json:"status"`
+		ErrorMessage string   `json:"errorMessage"`
+		Episodes     Episodes `json:"episode"`
+	}
+	Channels []Channel
+
+	Episode struct {
+		ID          string `json:"id"`
+		StreamID    string `json:"streamId"`
+		ChannelID   string `json:"channelId"`
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		PublishDate string `json:"publishDate"`
+		Status      string `json:"status"`
+		Artist      string `json:"artist"`
+		Album       string `json:"album"`
+		Suffix      string `json:"suffix"`
+		Path        string `json:"path"`
+
+		// Duration is in seconds
+		Duration int `json:"duration"`
+	}
+	Episodes []Episode
+
+	GetPodcastsResponseWrapper struct {
+		Response GetPodcastsResponse `json:"subsonic-response"`
+	}
+
+	GetPodcastsResponse struct {
+		StatusResponse
+		Podcasts struct {
+			Channels Channels `json:"channel"`
+		} `json:"podcasts"`
+	}
+
+	GetNewestPodcastsResponseWrapper struct {
+		Response GetNewestPodcastsResponse `json:"subsonic-response"`
+	}
+
+	GetNewestPodcastsResponse struct {
+		StatusResponse
+		NewestPodcasts struct {
+			Episodes Episodes `json:"episode"`
+		} `json:"newestPodcasts"`
+	}
+)
+
+// Published parses the publish date. Servers disagree on whether to send
+// a time zone, so a few layouts are tried. The zero time means we couldn't
+// make sense of it.
+func (episode Episode) Published() time.Time {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02"} {
+		if at, err := time.Parse(layout, episode.PublishDate); err == nil {
+			return at
+		}
+	}
+	return time.Time{}
+}
+
+// Downloaded is whether the server has the episode and can stream it
+func (episode Episode) Downloaded() bool {
+	return episode.Status == EpisodeCompleted && episode.StreamID != ""
+}
+
+// Song describes the episode the way the queue and player expect. Its ID is
+// the stream id, which is what stream, download and the bookmark calls want.
+func (episode Episode) Song() Song {
+	return Song{
+		ID:       episode.StreamID,
+		Album:    episode.Album,
+		Artist:   episode.Artist,
+		Path:     episode.Path,
+		Title:    episode.Title,
+		Suffix:   episode.Suffix,
+		Duration: episode.Duration,
+		Type:     "podcast",
+	}
+}
+
+// GetPodcasts lists the podcast channels the server follows, with their
+// episodes if includeEpisodes is set
+func (client Sonic) GetPodcasts(includeEpisodes bool) (Channels, error) {
+	var resp GetPodcastsResponseWrapper
+
+	params := struct {
+		Format          string `url:"f"`
+		User            string `url:"u"`
+		Password        string `url:"p"`
+		ClientID        string `url:"c"`
+		IncludeEpisodes bool   `url:"includeEpisodes"`
+	}{client.format(), client.auth.User, client.auth.Password, clientID, includeEpisodes}
+
+	_, err := client.sling().New().
+		Post(client.url("rest/getPodcasts")).
+		BodyForm(params).
+		ReceiveSuccess(&resp)
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.Response.Err(); err != nil {
+		return nil, err
+	}
+
+	return resp.Response.Podcasts.Channels, nil
+}
+
+// GetNewestPodcasts returns the most recently published episodes across
+// every channel
+func (client Sonic) GetNewestPodcasts(count int) (Episodes, error) {
+	var resp GetNewestPodcastsResponseWrapper
+
+	params := struct {
+		Format   string `url:"f"`
+		User     string `url:"u"`
+		Password string `url:"p"`
+		ClientID string `url:"c"`
+		Count    int    `url:"count"`
+	}{client.format(), client.auth.User, client.auth.Password, clientID, count}
+
+	_, err := client.sling().New().
+		Post(client.url("rest/getNewestPodcasts")).
+		BodyForm(params).
+		ReceiveSuccess(&resp)
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.Response.Err(); err != nil {
+		return nil, err
+	}
+
+	return resp.Response.NewestPodcasts.Episodes, nil
+}
+
+// DownloadPodcastEpisode asks the server to fetch an episode from the
+// podcast's feed. It returns right away; the episode's status changes to
+// completed once the server has it.
+func (client Sonic) DownloadPodcastEpisode(id string) error {
+	var resp StatusResponseWrapper
+
+	params := struct {
+		Format   string `url:"f"`
+		User     string `url:"u"`
+		Password string `url:"p"`
+		ClientID string `url:"c"`
+		ID       string `url:"id"`
+	}{client.format(), client.auth.User, client.auth.Password, clientID, id}
+
+	_, err := client.sling().New().
+		Post(client.url("rest/downloadPodcastEpisode")).
+		BodyForm(params).
+		ReceiveSuccess(&resp)
+	if err != nil {
+		return err
+	}
+	return resp.Response.Err()
+}
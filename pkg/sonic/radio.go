@@ -0,0 +1,60 @@
+package sonic
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+type (
+	InternetRadioStation struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		StreamURL   string `json:"streamUrl"`
+		HomePageURL string `json:"homePageUrl"`
+	}
+	InternetRadioStations []InternetRadioStation
+
+	GetInternetRadioStationsResponseWrapper struct {
+		Response GetInternetRadioStationsResponse `json:"subsonic-response"`
+	}
+
+	GetInternetRadioStationsResponse struct {
+		StatusResponse
+		Stations struct {
+			Stations InternetRadioStations `json:"internetRadioStation"`
+		} `json:"internetRadioStations"`
+	}
+)
+
+// Song describes the station the way the queue and player expect. The
+// station's stream url is kept in Path.
+func (station InternetRadioStation) Song() Song {
+	return Song{
+		ID:     station.ID,
+		Album:  station.Name,
+		Artist: station.Name,
+		Path:   station.StreamURL,
+	}
+}
+
+func (client Sonic) GetInternetRadioStations() (InternetRadioStations, error) {
+	var resp GetInternetRadioStationsResponseWrapper
+
+	params := struct {
+		Format   string `url:"f"`
+		User     string `url:"u"`
+		Password string `url:"p"`
+		ClientID string `url:"c"`
+	}{client.format(), client.auth.User, client.auth.Password, clientID}
+
+	_, err := client.sling().New().
+		Post(client.url("rest/getInternetRadioStations")).
+		BodyForm(params).
+		ReceiveSuccess(&resp)
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.Response.Err(); err != nil {
+		return nil, err
+	}
+
+	return resp.Response.Stations.Stations, nil
+}
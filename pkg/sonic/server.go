@@ -0,0 +1,125 @@
+package sonic
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// JSON responses arrived in api version 1.4.0
+const jsonSince = "1.4.0"
+
+// ServerInfo is what the server says about itself. Type and ServerVersion
+// are only sent by newer servers, like navidrome or gonic.
+type ServerInfo struct {
+	// Version is the subsonic api version, like 1.16.1
+	Version       string
+	Type          string
+	ServerVersion string
+	OpenSubsonic  bool
+
+	// XML is set when the server can't be talked to in JSON
+	XML bool
+}
+
+func (info ServerInfo) String() string {
+	name := info.Type
+	if name == "" {
+		name = "subsonic"
+	}
+	if info.ServerVersion != "" {
+		name = fmt.Sprintf("%s %s", name, info.ServerVersion)
+	}
+
+	details := []string{fmt.Sprintf("api %s", info.Version)}
+	if info.OpenSubsonic {
+		details = append(details, "OpenSubsonic")
+	}
+	if info.XML {
+		details = append(details, "xml")
+	}
+	return fmt.Sprintf("%s (%s)", name, strings.Join(details, ", "))
+}
+
+// AtLeast is whether the server's api version is version or newer. An
+// unknown version is assumed to be new enough.
+func (info ServerInfo) AtLeast(version string) bool {
+	if info.Version == "" {
+		return true
+	}
+	return compareVersions(info.Version, version) >= 0
+}
+
+func compareVersions(a string, b string) int {
+	partsA := strings.Split(a, ".")
+	partsB := strings.Split(b, ".")
+	for idx := 0; idx < len(partsA) || idx < len(partsB); idx++ {
+		var numA, numB int
+		if idx < len(partsA) {
+			numA, _ = strconv.Atoi(partsA[idx])
+		}
+		if idx < len(partsB) {
+			numB, _ = strconv.Atoi(partsB[idx])
+		}
+		if numA != numB {
+			if numA < numB {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// Probe pings the server to find out what it is and what it supports. A
+// server whose JSON we can't read is switched over to XML. OpenSubsonic
+// servers are asked for their extensions; anything else ends up with none.
+func (client *Sonic) Probe() error {
+	status, err := client.ping()
+
+	var decodeErr DecodingError
+	if errors.As(err, &decodeErr) && !client.xml {
+		client.xml = true
+		if status, err = client.ping(); err != nil {
+			client.xml = false
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	client.Server = ServerInfo{
+		Version:       status.Version,
+		Type:          status.Type,
+		ServerVersion: status.ServerVersion,
+		OpenSubsonic:  status.OpenSubsonic,
+	}
+	if !client.Server.AtLeast(jsonSince) {
+		client.xml = true
+	}
+	client.Server.XML = client.xml
+
+	client.OpenSubsonic = status.OpenSubsonic
+	client.Extensions = Extensions{}
+	if !status.OpenSubsonic {
+		return nil
+	}
+
+	extensions, err := client.GetOpenSubsonicExtensions()
+	if err != nil {
+		// Claiming OpenSubsonic without the extensions call isn't worth
+		// failing over, unless the server went away
+		var netErr net.Error
+		if errors.As(err, &netErr) {
+			return err
+		}
+		return nil
+	}
+	client.Extensions = extensions
+	return nil
+}
@@ -0,0 +1,84 @@
+package sonic
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+type (
+	GetSimilarSongs2ResponseWrapper struct {
+		Response GetSimilarSongs2Response `json:"subsonic-response"`
+	}
+
+	GetSimilarSongs2Response struct {
+		StatusResponse
+		SimilarSongs struct {
+			Songs Songs `json:"song"`
+		} `json:"similarSongs2"`
+	}
+
+	GetTopSongsResponseWrapper struct {
+		Response GetTopSongsResponse `json:"subsonic-response"`
+	}
+
+	GetTopSongsResponse struct {
+		StatusResponse
+		TopSongs struct {
+			Songs Songs `json:"song"`
+		} `json:"topSongs"`
+	}
+)
+
+// GetSimilarSongs2 returns a random mix of songs by an artist and by
+// similar artists. The server gets "similar" from last.fm, so it may come
+// back empty if the server isn't set up for that.
+func (client Sonic) GetSimilarSongs2(artistID string, count int) (Songs, error) {
+	var resp GetSimilarSongs2ResponseWrapper
+
+	params := struct {
+		Format   string `url:"f"`
+		User     string `url:"u"`
+		Password string `url:"p"`
+		ClientID string `url:"c"`
+		ID       string `url:"id"`
+		Count    int    `url:"count"`
+	}{client.format(), client.auth.User, client.auth.Password, clientID, artistID, count}
+
+	_, err := client.sling().New().
+		Post(client.url("rest/getSimilarSongs2")).
+		BodyForm(params).
+		ReceiveSuccess(&resp)
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.Response.Err(); err != nil {
+		return nil, err
+	}
+
+	return resp.Response.SimilarSongs.Songs, nil
+}
+
+// GetTopSongs returns an artist's most popular songs, by name
+func (client Sonic) GetTopSongs(artist string, count int) (Songs, error) {
+	var resp GetTopSongsResponseWrapper
+
+	params := struct {
+		Format   string `url:"f"`
+		User     string `url:"u"`
+		Password string `url:"p"`
+		ClientID string `url:"c"`
+		Artist   string `url:"artist"`
+		Count    int    `url:"count"`
+	}{client.format(), client.auth.User, client.auth.Password, clientID, artist, count}
+
+	_, err := client.sling().New().
+		Post(client.url("rest/getTopSongs")).
+		BodyForm(params).
+		ReceiveSuccess(&resp)
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.Response.Err(); err != nil {
+		return nil, err
+	}
+
+	return resp.Response.TopSongs.Songs, nil
+}
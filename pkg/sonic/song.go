@@ -0,0 +1,91 @@
+package sonic
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ArtistName is the best name to show for the song's artist. OpenSubsonic
+// servers can list several artists, which are joined up if the server
+// didn't say how to display them.
+func (song Song) ArtistName() string {
+	if song.DisplayArtist != "" {
+		return song.DisplayArtist
+	}
+	if song.Artist != "" || len(song.Artists) == 0 {
+		return song.Artist
+	}
+
+	names := make([]string, 0, len(song.Artists))
+	for _, artist := range song.Artists {
+		names = append(names, artist.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// GenreNames lists every genre the song is tagged with
+func (song Song) GenreNames() []string {
+	if len(song.Genres) == 0 {
+		if song.Genre == "" {
+			return nil
+		}
+		return []string{song.Genre}
+	}
+
+	names := make([]string, 0, len(song.Genres))
+	for _, genre := range song.Genres {
+		names = append(names, genre.Name)
+	}
+	return names
+}
+
+// LastPlayed is when the server last saw the song played, or the zero time
+// if it doesn't know
+func (song Song) LastPlayed() time.Time {
+	at, err := time.Parse(time.RFC3339Nano, song.Played)
+	if err != nil {
+		return time.Time{}
+	}
+	return at
+}
+
+// Details is a one line summary of everything about the song besides its
+// artist and title, like "Album (1997) · disc 2 · rock · 320kbps flac"
+func (song Song) Details() string {
+	parts := make([]string, 0, 5)
+
+	if song.Album != "" {
+		album := song.Album
+		if song.Year > 0 {
+			album = fmt.Sprintf("%s (%d)", album, song.Year)
+		}
+		parts = append(parts, album)
+	}
+	if song.DiscNumber > 1 {
+		parts = append(parts, fmt.Sprintf("disc %d", song.DiscNumber))
+	}
+	if genres := song.GenreNames(); len(genres) > 0 {
+		parts = append(parts, strings.Join(genres, "/"))
+	}
+	if song.BPM > 0 {
+		parts = append(parts, fmt.Sprintf("%d bpm", song.BPM))
+	}
+
+	format := strings.TrimSpace(fmt.Sprintf("%s %s", bitRate(song.BitRate), song.Suffix))
+	if format != "" {
+		parts = append(parts, format)
+	}
+
+	return strings.Join(parts, " · ")
+}
+
+func bitRate(kbps int) string {
+	if kbps <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%dkbps", kbps)
+}
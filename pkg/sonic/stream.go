@@ -0,0 +1,251 @@
+package sonic
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+// Big responses, like a playlist with twenty thousand songs, are decoded a
+// token at a time. Items in the list we're after are handed over one by one
+// as they arrive, and only the rest of the response is kept around. XML
+// responses are rare enough, and only come from small old servers, that
+// they're decoded in one go and then walked the same way.
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// eachSong decodes each streamed item as a song
+func eachSong(each func(Song) error) func(item interface{}) error {
+	return func(item interface{}) error {
+		var song Song
+		assign(item, reflect.ValueOf(&song).Elem())
+		return each(song)
+	}
+}
+
+// stream posts to an endpoint and calls each for every item of the list
+// found by following path into the response. Everything else in the
+// response is decoded into header.
+func (client Sonic) stream(endpoint string, params interface{}, path []string, header interface{}, each func(item interface{}) error) error {
+	req, err := client.sling().New().
+		Post(client.url(endpoint)).
+		BodyForm(params).
+		Request()
+	if err != nil {
+		return err
+	}
+
+	httpClient := client.HTTP
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	// Like fetch, there's no deadline, only a limit on how long the server
+	// can go quiet
+	ctx, cancel := context.WithCancel(req.Context())
+	defer cancel()
+	timer := time.AfterFunc(client.timeout(), cancel)
+	defer timer.Stop()
+
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return client.stalled(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s failed: %s", endpoint, resp.Status)
+	}
+
+	body := stallReader{resp.Body, timer, client.timeout()}
+	if err := decodeStream(body, resp.Header.Get("Content-Type"), path, header, each); err != nil {
+		return client.stalled(ctx, err)
+	}
+	return nil
+}
+
+func decodeStream(body io.Reader, contentType string, path []string, header interface{}, each func(item interface{}) error) error {
+	reader := bufio.NewReader(body)
+	if strings.Contains(contentType, "xml") || startsWithXML(reader) {
+		return decodeStreamXML(reader, path, header, each)
+	}
+
+	decoder := json.NewDecoder(reader)
+	decoder.UseNumber()
+
+	tok, err := decoder.Token()
+	if err != nil {
+		return DecodingError{err}
+	}
+	if tok != json.Delim('{') {
+		return DecodingError{errors.New("expected an object")}
+	}
+
+	tree, err := walkJSON(decoder, path, each)
+	if err != nil {
+		return err
+	}
+	return assignTree(tree, header)
+}
+
+func startsWithXML(reader *bufio.Reader) bool {
+	for {
+		b, err := reader.Peek(1)
+		if err != nil {
+			return false
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			reader.ReadByte()
+		default:
+			return b[0] == '<'
+		}
+	}
+}
+
+// walkJSON reads the rest of an object whose opening brace has been read.
+// The list at the end of path goes to each instead of into the tree.
+func walkJSON(decoder *json.Decoder, path []string, each func(item interface{}) error) (map[string]interface{}, error) {
+	node := make(map[string]interface{})
+	for decoder.More() {
+		tok, err := decoder.Token()
+		if err != nil {
+			return nil, DecodingError{err}
+		}
+		key, _ := tok.(string)
+
+		if tok, err = decoder.Token(); err != nil {
+			return nil, DecodingError{err}
+		}
+
+		wanted := len(path) > 0 && strings.EqualFold(key, path[0])
+		switch {
+		case wanted && len(path) > 1 && tok == json.Delim('{'):
+			child, err := walkJSON(decoder, path[1:], each)
+			if err != nil {
+				return nil, err
+			}
+			node[key] = child
+
+		case wanted && len(path) == 1 && tok == json.Delim('['):
+			for decoder.More() {
+				var item interface{}
+				if err := decoder.Decode(&item); err != nil {
+					return nil, DecodingError{err}
+				}
+				if err := each(item); err != nil {
+					return nil, err
+				}
+			}
+			if _, err := decoder.Token(); err != nil {
+				return nil, DecodingError{err}
+			}
+
+		case wanted && len(path) == 1:
+			// A list of one, sent as a bare object, or an empty list sent
+			// as an empty string or null
+			item, err := readJSON(decoder, tok)
+			if err != nil {
+				return nil, err
+			}
+			if text, ok := item.(string); item == nil || (ok && text == "") {
+				continue
+			}
+			if err := each(item); err != nil {
+				return nil, err
+			}
+
+		default:
+			value, err := readJSON(decoder, tok)
+			if err != nil {
+				return nil, err
+			}
+			node[key] = value
+		}
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return nil, DecodingError{err}
+	}
+	return node, nil
+}
+
+// readJSON reads a whole value, given its first token
+func readJSON(decoder *json.Decoder, tok json.Token) (interface{}, error) {
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return tok, nil
+	}
+
+	switch delim {
+	case '{':
+		return walkJSON(decoder, nil, nil)
+	case '[':
+		items := make([]interface{}, 0)
+		for decoder.More() {
+			var item interface{}
+			if err := decoder.Decode(&item); err != nil {
+				return nil, DecodingError{err}
+			}
+			items = append(items, item)
+		}
+		if _, err := decoder.Token(); err != nil {
+			return nil, DecodingError{err}
+		}
+		return items, nil
+	}
+	return nil, DecodingError{fmt.Errorf("unexpected %s", delim)}
+}
+
+func decodeStreamXML(body io.Reader, path []string, header interface{}, each func(item interface{}) error) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	tree, err := xmlTree(bytes.TrimSpace(data))
+	if err != nil {
+		return DecodingError{err}
+	}
+
+	node, _ := tree.(map[string]interface{})
+	for idx, key := range path {
+		if node == nil {
+			break
+		}
+		found, ok := lookupKey(node, key)
+		if !ok {
+			break
+		}
+
+		if idx < len(path)-1 {
+			node, _ = found.(map[string]interface{})
+			continue
+		}
+
+		for k := range node {
+			if strings.EqualFold(k, key) {
+				delete(node, k)
+			}
+		}
+		items, isList := found.([]interface{})
+		if !isList {
+			items = []interface{}{found}
+		}
+		for _, item := range items {
+			if err := each(item); err != nil {
+				return err
+			}
+		}
+	}
+
+	return assignTree(tree, header)
+}
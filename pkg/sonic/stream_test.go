@@ -0,0 +1,94 @@
+package sonic
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeStream(t *testing.T) {
+	path := []string{"subsonic-response", "playlist", "entry"}
+	wrap := func(entry string) string {
+		return `{"subsonic-response":{"status":"ok","version":"1.16.1","playlist":{"id":"7","name":"Late Night",` + entry + `}}}`
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		want    []string
+		wantErr bool
+	}{
+		{name: "list", body: wrap(`"entry":[{"id":"a"},{"id":"b"},{"id":"c"}]`), want: []string{"a", "b", "c"}},
+		{name: "single object", body: wrap(`"entry":{"id":"a"}`), want: []string{"a"}},
+		{name: "empty string", body: wrap(`"entry":""`)},
+		{name: "null", body: wrap(`"entry":null`)},
+		{name: "empty list", body: wrap(`"entry":[]`)},
+		{name: "missing", body: wrap(`"songCount":0`)},
+		{name: "upper case key", body: wrap(`"Entry":[{"id":"a"}]`), want: []string{"a"}},
+		{
+			name:    "cut off part way",
+			body:    `{"subsonic-response":{"status":"ok","playlist":{"id":"7","entry":[{"id":"a"},{"id":"b"},{"id":"c`,
+			want:    []string{"a", "b"},
+			wantErr: true,
+		},
+		{
+			name:    "cut off between songs",
+			body:    `{"subsonic-response":{"status":"ok","playlist":{"id":"7","entry":[{"id":"a"},`,
+			want:    []string{"a"},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				header GetPlaylistResponseWrapper
+				got    []string
+			)
+			err := decodeStream(strings.NewReader(test.body), "application/json", path, &header, eachSong(func(song Song) error {
+				got = append(got, song.ID)
+				return nil
+			}))
+
+			if test.wantErr {
+				var decodingErr DecodingError
+				if !errors.As(err, &decodingErr) {
+					t.Errorf("got %v, want a decoding error", err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else if header.Response.Playlist.ID != "7" {
+				t.Errorf("playlist id is '%s', want 7", header.Response.Playlist.ID)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got songs %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// Whatever each returns stops the stream and comes back as is
+func TestDecodeStreamStops(t *testing.T) {
+	body := `{"subsonic-response":{"status":"ok","playlist":{"entry":[{"id":"a"},{"id":"b"},{"id":"c"}]}}}`
+	stop := errors.New("that will do")
+
+	var got []string
+	err := decodeStream(strings.NewReader(body), "", []string{"subsonic-response", "playlist", "entry"}, &GetPlaylistResponseWrapper{}, eachSong(func(song Song) error {
+		got = append(got, song.ID)
+		if len(got) == 2 {
+			return stop
+		}
+		return nil
+	}))
+	if err != stop {
+		t.Errorf("got %v, want %v", err, stop)
+	}
+	if !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("got songs %v", got)
+	}
+}
@@ -0,0 +1,477 @@
+package web
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+// A remote control over HTTP, for phones and anything else on the network
+// that can't reach the control socket. Everything under /api wants the
+// token, as "Authorization: Bearer <token>" or ?token=<token>, and answers
+// in JSON.
+//
+//	GET  /api/now              the current State, plus the volume
+//	GET  /api/queue            what's lined up next
+//	GET  /api/history          what played before, oldest first
+//	GET  /api/search?q=        songs on the server, to enqueue
+//	GET  /api/cover            artwork for the current track
+//	GET  /api/events           server-sent events, one per player event
+//	POST /api/next, previous, pause, star
+//	POST /api/seek?position=   seconds into the track, or +/- to skip
+//	POST /api/volume?volume=   0 to 100
+//	POST /api/enqueue?id=      a song to play after this one
+//
+// The page at / is a small player that uses all of the above.
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/control"
+	"git.sr.ht/~sungo/hedgehog/pkg/keymap"
+	"git.sr.ht/~sungo/hedgehog/pkg/mpv"
+	"git.sr.ht/~sungo/hedgehog/pkg/queue"
+	"git.sr.ht/~sungo/hedgehog/pkg/sonic"
+)
+
+const (
+	// How often to say something on an event stream when nothing is
+	// happening, so proxies and phones don't hang up on it
+	keepAlive = 15 * time.Second
+
+	searchResults = 25
+	coverSize     = 512
+	tokenFile     = "web-token"
+)
+
+//go:embed index.html
+var page []byte
+
+// Player is what the remote controls
+type Player struct {
+	Hub   *control.Hub
+	Queue *queue.Queue
+	Decks *mpv.Decks
+
+	// Client looks up songs and artwork. It's nil when offline.
+	Client *sonic.Sonic
+
+	// Do runs a key binding action, like pause
+	Do func(action string) error
+}
+
+type Server struct {
+	token    string
+	player   Player
+	listener net.Listener
+	http     *http.Server
+}
+
+// Track is a queue entry, as the API describes it
+type Track struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	Artist    string `json:"artist"`
+	Album     string `json:"album,omitempty"`
+	Year      int    `json:"year,omitempty"`
+	Duration  int    `json:"duration"`
+	Starred   bool   `json:"starred"`
+	Requested bool   `json:"requested,omitempty"`
+}
+
+// Token is the token from the last time, kept in dir so that bookmarked
+// links keep working, or a new one
+func Token(dir string) (string, error) {
+	path := filepath.Join(dir, tokenFile)
+	if data, err := os.ReadFile(path); err == nil {
+		if token := strings.TrimSpace(string(data)); token != "" {
+			return token, nil
+		}
+	}
+
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(raw)
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(token+"\n"), 0o600); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Listen claims addr, like ":8080", for the remote. Nothing is answered
+// until Serve is called, so the player can find out the port is taken
+// before it starts up.
+func Listen(addr string, token string) (*Server, error) {
+	if token == "" {
+		return nil, errors.New("the web remote needs a token")
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	server := &Server{token: token, listener: listener}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", server.page)
+	mux.HandleFunc("/api/now", server.get(server.now))
+	mux.HandleFunc("/api/queue", server.get(server.upcoming))
+	mux.HandleFunc("/api/history", server.get(server.history))
+	mux.HandleFunc("/api/search", server.get(server.search))
+	mux.HandleFunc("/api/cover", server.get(server.cover))
+	mux.HandleFunc("/api/events", server.get(server.events))
+	for _, action := range []keymap.Action{keymap.Next, keymap.Previous, keymap.Pause, keymap.Star} {
+		mux.HandleFunc("/api/"+string(action), server.post(server.action(action)))
+	}
+	mux.HandleFunc("/api/seek", server.post(server.seek))
+	mux.HandleFunc("/api/volume", server.post(server.volume))
+	mux.HandleFunc("/api/enqueue", server.post(server.enqueue))
+
+	server.http = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+	return server, nil
+}
+
+// Serve starts answering requests on behalf of player
+func (server *Server) Serve(player Player) {
+	server.player = player
+	go func() {
+		if err := server.http.Serve(server.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Warn("web remote stopped", "err", err)
+		}
+	}()
+	slog.Info("web remote listening", "addr", server.listener.Addr().String())
+}
+
+// URL is where to point a browser, token and all
+func (server *Server) URL() string {
+	host, port, _ := net.SplitHostPort(server.listener.Addr().String())
+	if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+		host = "localhost"
+		if name, err := os.Hostname(); err == nil {
+			host = name
+		}
+	}
+	return fmt.Sprintf("http://%s/?token=%s", net.JoinHostPort(host, port), url.QueryEscape(server.token))
+}
+
+// Close hangs up on everyone and stops listening
+func (server *Server) Close() error {
+	err := server.http.Close()
+	// The listener is only the server's to close once it's serving
+	server.listener.Close()
+	return err
+}
+
+// apiError is an error with the HTTP status to answer it with
+type apiError struct {
+	status int
+	err    error
+}
+
+func (e apiError) Error() string {
+	return e.err.Error()
+}
+
+func badRequest(format string, args ...interface{}) error {
+	return apiError{status: http.StatusBadRequest, err: fmt.Errorf(format, args...)}
+}
+
+type handler func(w http.ResponseWriter, r *http.Request) error
+
+func (server *Server) get(fn handler) http.HandlerFunc {
+	return server.api(http.MethodGet, fn)
+}
+
+func (server *Server) post(fn handler) http.HandlerFunc {
+	return server.api(http.MethodPost, fn)
+}
+
+// api checks the method and the token, then answers with whatever fn
+// wrote or the error it returned
+func (server *Server) api(method string, fn handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !server.authorized(r) {
+			slog.Warn("web remote refused a request", "path", r.URL.Path, "from", r.RemoteAddr)
+			reply(w, http.StatusUnauthorized, map[string]string{"error": "bad or missing token"})
+			return
+		}
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			reply(w, http.StatusMethodNotAllowed, map[string]string{"error": "use " + method})
+			return
+		}
+		slog.Debug("web request", "method", r.Method, "path", r.URL.Path)
+
+		if err := fn(w, r); err != nil {
+			status := http.StatusInternalServerError
+			var apiErr apiError
+			if errors.As(err, &apiErr) {
+				status = apiErr.status
+			}
+			reply(w, status, map[string]string{"error": err.Error()})
+		}
+	}
+}
+
+func (server *Server) authorized(r *http.Request) bool {
+	given := r.URL.Query().Get("token")
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		given = strings.TrimPrefix(header, "Bearer ")
+	}
+	return subtle.ConstantTimeCompare([]byte(given), []byte(server.token)) == 1
+}
+
+func reply(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func ok(w http.ResponseWriter) error {
+	reply(w, http.StatusOK, map[string]bool{"ok": true})
+	return nil
+}
+
+// page is the player itself. It has nothing in it worth a token, and gets
+// the token for the API from its own url.
+func (server *Server) page(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(page)
+}
+
+func (server *Server) now(w http.ResponseWriter, r *http.Request) error {
+	reply(w, http.StatusOK, struct {
+		control.State
+		Volume float64 `json:"volume"`
+	}{server.player.Hub.State(), server.player.Decks.Volume()})
+	return nil
+}
+
+func (server *Server) upcoming(w http.ResponseWriter, r *http.Request) error {
+	reply(w, http.StatusOK, tracks(server.player.Queue, server.player.Queue.Upcoming()))
+	return nil
+}
+
+func (server *Server) history(w http.ResponseWriter, r *http.Request) error {
+	reply(w, http.StatusOK, tracks(server.player.Queue, server.player.Queue.History()))
+	return nil
+}
+
+func tracks(q *queue.Queue, entries []*queue.Entry) []Track {
+	list := make([]Track, 0, len(entries))
+	for _, entry := range entries {
+		list = append(list, Track{
+			ID:        entry.Meta.ID,
+			Title:     entry.Meta.Title,
+			Artist:    entry.Meta.ArtistName(),
+			Album:     entry.Meta.Album,
+			Year:      entry.Meta.Year,
+			Duration:  entry.Meta.Duration,
+			Starred:   q.Starred(entry.Meta.ID),
+			Requested: entry.Requested,
+		})
+	}
+	return list
+}
+
+func (server *Server) search(w http.ResponseWriter, r *http.Request) error {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		return badRequest("search for what? (q)")
+	}
+	if server.player.Client == nil {
+		return badRequest("can't search the server while offline")
+	}
+
+	songs, err := server.player.Client.SearchSongs(query, searchResults, 0)
+	if err != nil {
+		return err
+	}
+
+	list := make([]Track, 0, len(songs))
+	for _, song := range songs {
+		list = append(list, Track{
+			ID:       song.ID,
+			Title:    song.Title,
+			Artist:   song.ArtistName(),
+			Album:    song.Album,
+			Year:     song.Year,
+			Duration: song.Duration,
+		})
+	}
+	reply(w, http.StatusOK, list)
+	return nil
+}
+
+func (server *Server) cover(w http.ResponseWriter, r *http.Request) error {
+	state := server.player.Hub.State()
+	if server.player.Client == nil || state.CoverArt == "" {
+		http.NotFound(w, r)
+		return nil
+	}
+
+	data, err := server.player.Client.GetCoverArt(state.CoverArt, coverSize)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", http.DetectContentType(data))
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	w.Write(data)
+	return nil
+}
+
+// events streams player events until the player stops or the browser
+// goes away. The first is the current state, named "status".
+func (server *Server) events(w http.ResponseWriter, r *http.Request) error {
+	flusher, canFlush := w.(http.Flusher)
+	if !canFlush {
+		return errors.New("streaming isn't possible here")
+	}
+
+	events, stop := server.player.Hub.Subscribe()
+	defer stop()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event control.Event) error {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Name, data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	if err := send(control.Event{Name: "status", State: server.player.Hub.State()}); err != nil {
+		return nil
+	}
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": still here\n\n"); err != nil {
+				return nil
+			}
+			flusher.Flush()
+		case event := <-events:
+			if err := send(event); err != nil || event.Name == control.Exit {
+				return nil
+			}
+		}
+	}
+}
+
+func (server *Server) action(action keymap.Action) handler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if err := server.player.Do(string(action)); err != nil {
+			return err
+		}
+		return ok(w)
+	}
+}
+
+func (server *Server) seek(w http.ResponseWriter, r *http.Request) error {
+	raw := strings.TrimSpace(r.FormValue("position"))
+	secs, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return badRequest("position should be seconds, like 90 or +10, not '%s'", raw)
+	}
+
+	state := server.player.Hub.State()
+	if state.Status == control.Stopped || state.Live {
+		return badRequest("nothing to seek in")
+	}
+	if strings.HasPrefix(raw, "+") || strings.HasPrefix(raw, "-") {
+		secs += state.Position
+	}
+	if secs < 0 {
+		secs = 0
+	}
+	if state.Duration > 0 && secs > state.Duration {
+		secs = state.Duration
+	}
+
+	server.player.Decks.Seek(time.Duration(secs * float64(time.Second)))
+	return ok(w)
+}
+
+func (server *Server) volume(w http.ResponseWriter, r *http.Request) error {
+	raw := strings.TrimSpace(r.FormValue("volume"))
+	vol, err := strconv.ParseFloat(raw, 64)
+	if err != nil || vol < 0 || vol > 100 {
+		return badRequest("volume should be 0 to 100, not '%s'", raw)
+	}
+
+	server.player.Decks.SetVolume(vol)
+	return ok(w)
+}
+
+func (server *Server) enqueue(w http.ResponseWriter, r *http.Request) error {
+	id := strings.TrimSpace(r.FormValue("id"))
+	if id == "" {
+		return badRequest("enqueue what? (id)")
+	}
+
+	song, err := server.lookup(id)
+	if err != nil {
+		return err
+	}
+	if err := server.player.Queue.Enqueue(song); err != nil {
+		return badRequest("%s", err)
+	}
+	slog.Info("enqueued", "song", song.ID, "title", song.Title)
+	return ok(w)
+}
+
+// lookup finds a song in the playlist, or failing that on the server
+func (server *Server) lookup(id string) (sonic.Song, error) {
+	if song, ok := server.player.Queue.Song(id); ok {
+		return song, nil
+	}
+	if server.player.Client == nil {
+		return sonic.Song{}, badRequest("'%s' isn't in the playlist, and the server is out of reach", id)
+	}
+
+	song, err := server.player.Client.GetSong(id)
+	if err != nil {
+		return sonic.Song{}, badRequest("no song '%s': %s", id, err)
+	}
+	return song, nil
+}
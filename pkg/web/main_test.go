@@ -0,0 +1,156 @@
+package web
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"git.sr.ht/~sungo/hedgehog/pkg/control"
+	"git.sr.ht/~sungo/hedgehog/pkg/queue"
+	"git.sr.ht/~sungo/hedgehog/pkg/sonic"
+)
+
+// trickle is a playlist that streams in slowly, so songs are still
+// arriving while the queue plays
+type trickle struct {
+	songs sonic.Songs
+}
+
+func (src trickle) Load() (sonic.Playlist, error) {
+	return sonic.Playlist{Name: "trickle", Songs: src.songs}, nil
+}
+
+func (src trickle) Stream(each func(sonic.Song) error) (sonic.Playlist, error) {
+	for _, song := range src.songs {
+		time.Sleep(time.Millisecond)
+		if err := each(song); err != nil {
+			return sonic.Playlist{}, err
+		}
+	}
+	return sonic.Playlist{Name: "trickle", SongCount: len(src.songs)}, nil
+}
+
+// fakeServer answers just enough of the subsonic api for the queue to play
+func fakeServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/getStarred":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"subsonic-response":{"status":"ok","version":"1.16.1","starred":{"song":[{"id":"s2"}]}}}`))
+		case "/rest/star", "/rest/unstar":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"subsonic-response":{"status":"ok","version":"1.16.1"}}`))
+		case "/rest/download":
+			w.Write([]byte("not really audio"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// The remote reads the queue while the player moves it along. Run with
+// -race to make sure it does so safely.
+func TestQueueWhilePlaying(t *testing.T) {
+	server := fakeServer(t)
+	client := sonic.New(sonic.Auth{User: "sungo", Password: "hunter2"}, server.URL)
+
+	var songs sonic.Songs
+	for idx := 1; idx <= 20; idx++ {
+		songs = append(songs, sonic.Song{ID: fmt.Sprintf("s%d", idx), Title: fmt.Sprintf("Song %d", idx), Suffix: "mp3"})
+	}
+
+	q := queue.New()
+	q.Client = &client
+	q.TempDir = t.TempDir()
+	q.Depth = 2
+	q.Source = trickle{songs: songs}
+	q.Playlist.Name = "trickle"
+	if err := q.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.CleanUp()
+
+	remote, err := Listen("127.0.0.1:0", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remote.Close()
+	remote.Serve(Player{Hub: control.NewHub(), Queue: q, Client: &client})
+	base := "http://" + remote.listener.Addr().String()
+
+	call := func(method string, path string, form url.Values) []byte {
+		req, err := http.NewRequest(method, base+path, strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer token")
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		var body json.RawMessage
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("%s %s: %s", method, path, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s %s: %s %s", method, path, resp.Status, body)
+		}
+		return body
+	}
+
+	playing := make(chan struct{})
+	go func() {
+		defer close(playing)
+		for idx := 0; idx < 12; idx++ {
+			if q.WhatsNext() == nil {
+				return
+			}
+			// Give the remote a chance to look while songs arrive
+			time.Sleep(5 * time.Millisecond)
+			switch idx % 4 {
+			case 1:
+				q.Previous()
+			case 3:
+				q.StarToggle()
+			}
+		}
+	}()
+
+	for idx := 1; ; idx++ {
+		select {
+		case <-playing:
+			var upcoming []Track
+			if err := json.Unmarshal(call(http.MethodGet, "/api/queue", nil), &upcoming); err != nil {
+				t.Fatal(err)
+			}
+			if len(upcoming) == 0 {
+				t.Error("nothing lined up")
+			}
+			for _, track := range upcoming {
+				if track.Starred != q.Starred(track.ID) {
+					t.Errorf("%s starred is %v", track.ID, track.Starred)
+				}
+			}
+			return
+		default:
+		}
+
+		call(http.MethodGet, "/api/queue", nil)
+		call(http.MethodGet, "/api/history", nil)
+		call(http.MethodPost, "/api/enqueue", url.Values{"id": {fmt.Sprintf("s%d", idx%3+1)}})
+	}
+}